@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -17,7 +19,10 @@ import (
 	_ "modernc.org/sqlite"
 
 	"tracker/internal/api"
+	"tracker/internal/internaltracker"
+	"tracker/internal/outbox"
 	"tracker/internal/store"
+	"tracker/internal/syncer"
 )
 
 func main() {
@@ -41,17 +46,49 @@ func main() {
 		os.Exit(1)
 	}
 
+	if _, err := st.FailInterruptedSyncJobs(context.Background()); err != nil {
+		logger.Error("reset interrupted sync jobs failed", "err", err)
+		os.Exit(1)
+	}
+
+	outboxCfg, err := outboxFromEnv()
+	if err != nil {
+		logger.Error("invalid outbox config", "err", err)
+		os.Exit(1)
+	}
+	var tracker *internaltracker.Client
+	if u := os.Getenv("INTERNAL_TRACKER_URL"); u != "" {
+		tracker = internaltracker.NewClient(u, os.Getenv("INTERNAL_TRACKER_TOKEN"))
+	}
+
+	sched, err := scheduleFromEnv()
+	if err != nil {
+		logger.Error("invalid sync schedule", "err", err)
+		os.Exit(1)
+	}
+	// GITCODE_REPOS / SYNC_REPO_SCHEDULE only seed the repos table on first start.
+	perRepo, err := syncer.ParseRepoSchedule(os.Getenv("SYNC_REPO_SCHEDULE"))
+	if err != nil {
+		logger.Error("invalid sync schedule", "err", fmt.Errorf("SYNC_REPO_SCHEDULE: %w", err))
+		os.Exit(1)
+	}
+	if _, err := st.SeedRepos(context.Background(), syncer.SeedRepos(syncer.ConfigFromEnv(), perRepo)); err != nil {
+		logger.Error("seed repos failed", "err", err)
+		os.Exit(1)
+	}
+
 	r := chi.NewRouter()
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{allowedOrigin},
-		AllowedMethods:   []string{"GET", "POST", "PATCH", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	api.RegisterRoutes(r, st)
+	sy := syncer.New(st)
+	api.RegisterRoutes(r, st, sy)
 
 	srv := &http.Server{
 		Addr:              addr,
@@ -67,10 +104,33 @@ func main() {
 		}
 	}()
 
+	loopCtx, stopLoop := context.WithCancel(context.Background())
+	loopDone := make(chan struct{})
+	go func() {
+		defer close(loopDone)
+		sy.Loop(loopCtx, sched)
+	}()
+	webhooksDone := make(chan struct{})
+	go func() {
+		defer close(webhooksDone)
+		sy.RunWebhooks(loopCtx)
+	}()
+	outboxDone := make(chan struct{})
+	go func() {
+		defer close(outboxDone)
+		outbox.New(st, tracker, outboxCfg).Run(loopCtx)
+	}()
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	<-sigCh
 
+	stopLoop()
+	<-loopDone
+	<-webhooksDone
+	<-outboxDone
+	sy.Close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_ = srv.Shutdown(ctx)
@@ -97,6 +157,49 @@ func newLogger(format, level string) *slog.Logger {
 	return slog.New(slog.NewTextHandler(os.Stdout, opts))
 }
 
+func scheduleFromEnv() (syncer.Schedule, error) {
+	var sched syncer.Schedule
+	var err error
+	if sched.Interval, err = time.ParseDuration(envOrDefault("SYNC_INTERVAL", "0")); err != nil {
+		return sched, fmt.Errorf("SYNC_INTERVAL: %w", err)
+	}
+	if sched.Jitter, err = time.ParseDuration(envOrDefault("SYNC_JITTER", "0")); err != nil {
+		return sched, fmt.Errorf("SYNC_JITTER: %w", err)
+	}
+	return sched, nil
+}
+
+func outboxFromEnv() (outbox.Config, error) {
+	cfg := outbox.DefaultConfig()
+	var err error
+	if v := os.Getenv("OUTBOX_POLL_INTERVAL"); v != "" {
+		if cfg.PollInterval, err = time.ParseDuration(v); err != nil || cfg.PollInterval <= 0 {
+			return cfg, fmt.Errorf("OUTBOX_POLL_INTERVAL: invalid duration %q", v)
+		}
+	}
+	if v := os.Getenv("OUTBOX_MAX_ATTEMPTS"); v != "" {
+		if cfg.MaxAttempts, err = strconv.Atoi(v); err != nil || cfg.MaxAttempts < 1 {
+			return cfg, fmt.Errorf("OUTBOX_MAX_ATTEMPTS: invalid value %q", v)
+		}
+	}
+	if v := os.Getenv("INTERNAL_STATUS_INTERVAL"); v != "" {
+		if cfg.StatusInterval, err = time.ParseDuration(v); err != nil || cfg.StatusInterval < 0 {
+			return cfg, fmt.Errorf("INTERNAL_STATUS_INTERVAL: invalid duration %q", v)
+		}
+	}
+	if v := os.Getenv("INTERNAL_STATUS_BATCH"); v != "" {
+		if cfg.StatusBatch, err = strconv.Atoi(v); err != nil || cfg.StatusBatch < 1 {
+			return cfg, fmt.Errorf("INTERNAL_STATUS_BATCH: invalid value %q", v)
+		}
+	}
+	if v := os.Getenv("OUTBOX_MAX_BACKOFF"); v != "" {
+		if cfg.Backoff.MaxDelay, err = time.ParseDuration(v); err != nil || cfg.Backoff.MaxDelay <= 0 {
+			return cfg, fmt.Errorf("OUTBOX_MAX_BACKOFF: invalid duration %q", v)
+		}
+	}
+	return cfg, nil
+}
+
 func envOrDefault(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"tracker/internal/store"
+	"tracker/internal/syncer"
+)
+
+// repoRequest is the POST /api/repos body; unset options keep their current
+// value, or the SYNC_* env default (and the gitcode provider) for a new repo.
+type repoRequest struct {
+	Provider      string  `json:"provider"`
+	Owner         string  `json:"owner"`
+	Name          string  `json:"name"`
+	Enabled       *bool   `json:"enabled"`
+	SyncComments  *bool   `json:"syncComments"`
+	SyncPRDetails *bool   `json:"syncPrDetails"`
+	Interval      *string `json:"interval"`
+}
+
+func registerRepoRoutes(r chi.Router, st *store.Store) {
+	logger := slog.Default().With("component", "api", "op", "repos")
+
+	r.Get("/api/repos", func(w http.ResponseWriter, req *http.Request) {
+		repos, err := st.ListRepos(req.Context(), false)
+		if err != nil {
+			logger.Error("list repos failed", "err", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"repos": repos})
+	})
+
+	r.Post("/api/repos", func(w http.ResponseWriter, req *http.Request) {
+		var body repoRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		body.Owner, body.Name = strings.TrimSpace(body.Owner), strings.TrimSpace(body.Name)
+		if body.Owner == "" || body.Name == "" || strings.Contains(body.Owner, "/") || strings.Contains(body.Name, "/") {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "owner and name are required"})
+			return
+		}
+		if body.Provider != "" && !syncer.KnownProvider(body.Provider) {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "unknown provider", "providers": syncer.Providers()})
+			return
+		}
+		if body.Interval != nil && *body.Interval != "" {
+			if d, err := time.ParseDuration(*body.Interval); err != nil || d < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid interval"})
+				return
+			}
+		}
+
+		repo, err := st.GetRepo(req.Context(), body.Owner, body.Name)
+		switch {
+		case store.IsNotFound(err):
+			cfg := syncer.ConfigFromEnv()
+			repo = store.Repo{Provider: syncer.DefaultProvider, Owner: body.Owner, Name: body.Name, Enabled: true, SyncComments: cfg.SyncComments, SyncPRDetails: cfg.PRDetails}
+		case err != nil:
+			logger.Error("get repo failed", "repo", body.Owner+"/"+body.Name, "err", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if body.Provider != "" {
+			repo.Provider = body.Provider
+		}
+		if body.Enabled != nil {
+			repo.Enabled = *body.Enabled
+		}
+		if body.SyncComments != nil {
+			repo.SyncComments = *body.SyncComments
+		}
+		if body.SyncPRDetails != nil {
+			repo.SyncPRDetails = *body.SyncPRDetails
+		}
+		if body.Interval != nil {
+			repo.Interval = *body.Interval
+		}
+
+		saved, created, err := st.SaveRepo(req.Context(), repo)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, saved)
+	})
+
+	r.Delete("/api/repos/{owner}/{name}", func(w http.ResponseWriter, req *http.Request) {
+		owner, name := chi.URLParam(req, "owner"), chi.URLParam(req, "name")
+		if err := st.DeleteRepo(req.Context(), owner, name); err != nil {
+			if store.IsNotFound(err) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+				return
+			}
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"tracker/internal/store"
+)
+
+func TestReposHandler(t *testing.T) {
+	srv, _ := testAPI(t)
+	t.Setenv("SYNC_COMMENTS", "false")
+
+	var repo store.Repo
+	status := sendJSON(t, http.MethodPost, srv.URL+"/api/repos", `{"owner":"o","name":"new","interval":"10m"}`, &repo)
+	if status != http.StatusCreated || repo.Provider != "gitcode" || !repo.Enabled || repo.SyncComments || repo.Interval != "10m" {
+		t.Fatalf("create = %d %+v", status, repo)
+	}
+
+	// 已有仓库：只改请求里给出的字段。
+	repo = store.Repo{}
+	status = sendJSON(t, http.MethodPost, srv.URL+"/api/repos", `{"owner":"o","name":"new","enabled":false}`, &repo)
+	if status != http.StatusOK || repo.Enabled || repo.Interval != "10m" {
+		t.Fatalf("update = %d %+v", status, repo)
+	}
+
+	for _, tc := range []struct{ name, body string }{
+		{"invalid interval", `{"owner":"o","name":"new","interval":"soon"}`},
+		{"negative interval", `{"owner":"o","name":"new","interval":"-5m"}`},
+		{"missing name", `{"owner":"o"}`},
+		{"slash in owner", `{"owner":"o/x","name":"r"}`},
+		{"unknown provider", `{"owner":"o","name":"r","provider":"svn"}`},
+		{"invalid json", `{`},
+	} {
+		if status := sendJSON(t, http.MethodPost, srv.URL+"/api/repos", tc.body, nil); status != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tc.name, status)
+		}
+	}
+
+	var list struct {
+		Repos []store.Repo `json:"repos"`
+	}
+	if status := getJSON(t, srv.URL+"/api/repos", &list); status != http.StatusOK || len(list.Repos) != 2 {
+		t.Fatalf("list = %d %+v, want the seeded repo and the new one", status, list.Repos)
+	}
+
+	if status := sendJSON(t, http.MethodDelete, srv.URL+"/api/repos/o/new", "", nil); status != http.StatusNoContent {
+		t.Errorf("delete = %d, want 204", status)
+	}
+	if status := sendJSON(t, http.MethodDelete, srv.URL+"/api/repos/o/new", "", nil); status != http.StatusNotFound {
+		t.Errorf("second delete = %d, want 404", status)
+	}
+	if status := sendJSON(t, http.MethodDelete, srv.URL+"/api/repos/o/never", "", nil); status != http.StatusNotFound {
+		t.Errorf("delete of an unknown repo = %d, want 404", status)
+	}
+}
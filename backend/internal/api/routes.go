@@ -2,19 +2,19 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
-	"os"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
-	"tracker/internal/gitcode"
 	"tracker/internal/store"
+	"tracker/internal/syncer"
 )
 
-func RegisterRoutes(r chi.Router, st *store.Store) {
+func RegisterRoutes(r chi.Router, st *store.Store, sy *syncer.Syncer) {
 	logger := slog.Default().With("component", "api")
 
 	r.Get("/api/health", func(w http.ResponseWriter, _ *http.Request) {
@@ -28,11 +28,37 @@ func RegisterRoutes(r chi.Router, st *store.Store) {
 	r.Get("/api/items", func(w http.ResponseWriter, req *http.Request) {
 		kind := req.URL.Query().Get("kind") // issue|pr|""
 		repo := req.URL.Query().Get("repo") // "owner/name" or ""
+		labels := req.URL.Query()["label"]  // repeatable; all must match
+		drift, ok := queryBool(w, req, "assigneeDrift")
+		if !ok {
+			return
+		}
+		mismatch, ok := queryBool(w, req, "statusMismatch")
+		if !ok {
+			return
+		}
+		base := req.URL.Query().Get("base") // PR target branch or ""
+		merged, ok := queryOptBool(w, req, "merged")
+		if !ok {
+			return
+		}
+		tombstoned, ok := queryBool(w, req, "includeTombstoned")
+		if !ok {
+			return
+		}
+		sort := req.URL.Query().Get("sort") // ""|lastActivity
+		if sort != "" && sort != "lastActivity" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid sort"})
+			return
+		}
 
 		start := time.Now()
-		logger.Info("list items", "kind", kind, "repo", repo)
+		logger.Info("list items", "kind", kind, "repo", repo, "labels", labels, "assigneeDrift", drift, "statusMismatch", mismatch, "base", base)
 
-		items, err := st.ListItems(req.Context(), store.ListFilter{Kind: kind, RepoFullName: repo})
+		items, err := st.ListItems(req.Context(), store.ListFilter{
+			Kind: kind, RepoFullName: repo, Labels: labels, AssigneeDrift: drift, StatusMismatch: mismatch,
+			BaseBranch: base, Merged: merged, IncludeTombstoned: tombstoned, Sort: sort,
+		})
 		if err != nil {
 			logger.Error("list items failed", "kind", kind, "repo", repo, "err", err)
 			writeError(w, http.StatusInternalServerError, err)
@@ -42,6 +68,35 @@ func RegisterRoutes(r chi.Router, st *store.Store) {
 		writeJSON(w, http.StatusOK, map[string]any{"items": items})
 	})
 
+	r.Get("/api/labels", func(w http.ResponseWriter, req *http.Request) {
+		repo := req.URL.Query().Get("repo") // "owner/name" or ""
+		labels, err := st.ListLabels(req.Context(), repo)
+		if err != nil {
+			logger.Error("list labels failed", "repo", repo, "err", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"labels": labels})
+	})
+
+	r.Get("/api/items/{kind}/{owner}/{repo}/{key}/comments", func(w http.ResponseWriter, req *http.Request) {
+		kind := chi.URLParam(req, "kind") // issue|pr
+		repoFullName := chi.URLParam(req, "owner") + "/" + chi.URLParam(req, "repo")
+		key := chi.URLParam(req, "key")
+
+		comments, err := st.ListComments(req.Context(), kind, repoFullName, key)
+		if err != nil {
+			if store.IsNotFound(err) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+				return
+			}
+			logger.Error("list comments failed", "kind", kind, "repo", repoFullName, "key", key, "err", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"comments": comments})
+	})
+
 	r.Patch("/api/items/{kind}/{owner}/{repo}/{key}", func(w http.ResponseWriter, req *http.Request) {
 		kind := chi.URLParam(req, "kind") // issue|pr
 		owner := chi.URLParam(req, "owner")
@@ -76,100 +131,86 @@ func RegisterRoutes(r chi.Router, st *store.Store) {
 	})
 
 	r.Post("/api/sync", func(w http.ResponseWriter, req *http.Request) {
-		baseURL := envOrDefault("GITCODE_BASE_URL", "https://api.gitcode.com")
-		owner := envOrDefault("GITCODE_OWNER", "openeuler")
-		reposCSV := envOrDefault("GITCODE_REPOS", "yuanrong,yuanrong-functionsystem,yuanrong-datasystem,ray-adapter,yuanrong-frontend,yuanrong-serve,spring-adapter")
-		token := os.Getenv("GITCODE_TOKEN")
-
-		start := time.Now()
-		logger.Info("sync start", "owner", owner, "baseURL", baseURL)
-
-		repos := []string{}
-		for _, r := range strings.Split(reposCSV, ",") {
-			r = strings.TrimSpace(r)
-			if r != "" {
-				repos = append(repos, r)
-			}
+		full, ok := queryBool(w, req, "full")
+		if !ok {
+			return
 		}
 
-		if token == "" {
-			logger.Warn("sync missing token")
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "missing GITCODE_TOKEN"})
+		job, err := sy.Start(req.Context(), syncer.Options{Full: full}, "api")
+		if err != nil {
+			switch {
+			case errors.Is(err, syncer.ErrBusy):
+				logger.Warn("sync rejected", "reason", "busy")
+				writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error()})
+			default:
+				logger.Error("sync start failed", "err", err)
+				writeError(w, http.StatusInternalServerError, err)
+			}
 			return
 		}
+		logger.Info("sync accepted", "job", job.ID, "full", full)
 
-		client := gitcode.NewClient(baseURL, token)
+		writeJSON(w, http.StatusAccepted, map[string]any{"jobId": job.ID, "job": job})
+	})
 
-		totalFetched := 0
-		totalUpserted := 0
-		for _, repo := range repos {
-			logger.Info("sync repo", "repo", owner+"/"+repo)
-			issues, err := client.ListIssues(req.Context(), owner, repo)
-			if err != nil {
-				logger.Error("sync list issues failed", "repo", owner+"/"+repo, "err", err)
-				writeError(w, http.StatusBadGateway, err)
-				return
-			}
-			prs, err := client.ListPulls(req.Context(), owner, repo)
-			if err != nil {
-				logger.Error("sync list pulls failed", "repo", owner+"/"+repo, "err", err)
-				writeError(w, http.StatusBadGateway, err)
+	r.Get("/api/sync/jobs/{id}", func(w http.ResponseWriter, req *http.Request) {
+		id := chi.URLParam(req, "id")
+		job, err := st.GetSyncJob(req.Context(), id)
+		if err != nil {
+			if store.IsNotFound(err) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
 				return
 			}
+			logger.Error("get sync job failed", "job", id, "err", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
 
-			core := make([]store.CoreItem, 0, len(issues)+len(prs))
-			repoFullName := owner + "/" + repo
-			for _, it := range issues {
-				core = append(core, store.CoreItem{
-					Kind:         "issue",
-					RepoFullName: repoFullName,
-					ExternalKey:  it.Key,
-					Title:        it.Title,
-					State:        it.State,
-					URL:          it.URL,
-					Author:       it.Author,
-					CreatedAt:    it.CreatedAt,
-					UpdatedAt:    it.UpdatedAt,
-				})
-			}
-			for _, it := range prs {
-				core = append(core, store.CoreItem{
-					Kind:         "pr",
-					RepoFullName: repoFullName,
-					ExternalKey:  it.Key,
-					Title:        it.Title,
-					State:        it.State,
-					URL:          it.URL,
-					Author:       it.Author,
-					CreatedAt:    it.CreatedAt,
-					UpdatedAt:    it.UpdatedAt,
-				})
-			}
-
-			totalFetched += len(core)
-			up, err := st.UpsertCore(req.Context(), core)
-			if err != nil {
-				logger.Error("sync upsert failed", "repo", owner+"/"+repo, "err", err)
-				writeError(w, http.StatusInternalServerError, err)
-				return
-			}
-			totalUpserted += up
-			logger.Info("sync repo ok", "repo", owner+"/"+repo, "issues", len(issues), "prs", len(prs), "upserted", up)
+		// 已结束的任务用状态码反映结果：部分仓库失败 207，全部失败 502；运行中为 200。
+		status := http.StatusOK
+		switch job.Status {
+		case "partial":
+			status = http.StatusMultiStatus
+		case "failed":
+			status = http.StatusBadGateway
 		}
-		logger.Info("sync done", "fetched", totalFetched, "upserted", totalUpserted, "elapsed_ms", time.Since(start).Milliseconds())
+		writeJSON(w, status, job)
+	})
 
-		writeJSON(w, http.StatusOK, map[string]any{
-			"fetched":  totalFetched,
-			"upserted": totalUpserted,
-		})
+	// 只返回令牌指纹和状态，不含令牌本身。
+	r.Get("/api/sync/tokens", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"tokens": sy.TokenHealth()})
 	})
+
+	registerRepoRoutes(r, st)
+	registerWebhookRoutes(r, st, sy)
+	registerWriteRoutes(r, st, sy)
 }
 
-func envOrDefault(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// queryBool parses an optional boolean query parameter, answering 400 itself when it is malformed.
+func queryBool(w http.ResponseWriter, req *http.Request, name string) (bool, bool) {
+	v := req.URL.Query().Get(name)
+	if v == "" {
+		return false, true
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid " + name})
+		return false, false
+	}
+	return b, true
+}
+
+// queryOptBool is queryBool for filters where "unset" differs from false.
+func queryOptBool(w http.ResponseWriter, req *http.Request, name string) (*bool, bool) {
+	if req.URL.Query().Get(name) == "" {
+		return nil, true
+	}
+	b, ok := queryBool(w, req, name)
+	if !ok {
+		return nil, false
 	}
-	return def
+	return &b, true
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
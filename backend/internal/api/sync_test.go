@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	_ "modernc.org/sqlite"
+
+	"tracker/internal/gitcode/gitcodetest"
+	"tracker/internal/store"
+	"tracker/internal/syncer"
+)
+
+func testAPI(t *testing.T) (*httptest.Server, *gitcodetest.Server) {
+	t.Helper()
+	gc := gitcodetest.NewServer()
+	t.Cleanup(gc.Close)
+	t.Setenv("GITCODE_BASE_URL", gc.URL)
+	t.Setenv("GITCODE_TOKEN", "tok")
+	t.Setenv("SYNC_DISCOVER_INCLUDE", "")
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tracker.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	st := store.New(db)
+	if err := st.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.SeedRepos(context.Background(), []store.Repo{{Provider: "gitcode", Owner: "o", Name: "r", Enabled: true, SyncComments: true, SyncPRDetails: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	sy := syncer.New(st)
+	t.Cleanup(sy.Close)
+	r := chi.NewRouter()
+	RegisterRoutes(r, st, sy)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv, gc
+}
+
+func getJSON(t *testing.T, url string, v any) int {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode
+}
+
+func TestSyncHandler(t *testing.T) {
+	srv, gc := testAPI(t)
+	gc.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "crash"})
+	gc.AddPull("o/r", gitcodetest.Pull{Number: 2, Title: "fix", Body: "fixes #1", Head: "fix", Base: "master"})
+
+	res, err := http.Post(srv.URL+"/api/sync", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var started struct {
+		JobID string `json:"jobId"`
+	}
+	_ = json.NewDecoder(res.Body).Decode(&started)
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted || started.JobID == "" {
+		t.Fatalf("POST /api/sync = %d %+v", res.StatusCode, started)
+	}
+
+	var job store.SyncJob
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status := getJSON(t, srv.URL+"/api/sync/jobs/"+started.JobID, &job)
+		if job.Status != "running" {
+			if status != http.StatusOK || job.Status != "succeeded" {
+				t.Fatalf("job = %d %+v", status, job)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sync did not finish")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	var list struct {
+		Items []store.Item `json:"items"`
+	}
+	if status := getJSON(t, srv.URL+"/api/items?kind=pr", &list); status != http.StatusOK || len(list.Items) != 1 {
+		t.Fatalf("GET /api/items = %d %+v", status, list.Items)
+	}
+	if pr := list.Items[0]; pr.Title != "fix" || len(pr.Links) != 1 || pr.Links[0].Key != "1" {
+		t.Errorf("pr = %+v", pr)
+	}
+}
+
+func TestSyncHandlerFailedJob(t *testing.T) {
+	srv, gc := testAPI(t)
+	t.Setenv("GITCODE_MAX_RETRIES", "0")
+	gc.Inject(gitcodetest.Fault{Path: "/api/v5/repos/o/r/*", Status: http.StatusServiceUnavailable, Body: "down"})
+
+	res, err := http.Post(srv.URL+"/api/sync", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var started struct {
+		JobID string `json:"jobId"`
+	}
+	_ = json.NewDecoder(res.Body).Decode(&started)
+	res.Body.Close()
+
+	var job store.SyncJob
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status := getJSON(t, srv.URL+"/api/sync/jobs/"+started.JobID, &job)
+		if job.Status != "running" {
+			if status != http.StatusBadGateway || job.Status != "failed" || job.Repos[0].Error == "" {
+				t.Fatalf("job = %d %+v", status, job)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sync did not finish")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// syncNow runs a sync through the API and waits for it to succeed.
+func syncNow(t *testing.T, srv *httptest.Server) {
+	t.Helper()
+	res, err := http.Post(srv.URL+"/api/sync", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var started struct {
+		JobID string `json:"jobId"`
+	}
+	_ = json.NewDecoder(res.Body).Decode(&started)
+	res.Body.Close()
+
+	var job store.SyncJob
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		getJSON(t, srv.URL+"/api/sync/jobs/"+started.JobID, &job)
+		if job.Status != "running" {
+			if job.Status != "succeeded" {
+				t.Fatalf("job = %+v", job)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sync did not finish")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"tracker/internal/gitcode"
+	"tracker/internal/store"
+	"tracker/internal/syncer"
+)
+
+// maxWebhookBody caps how much of a delivery is read; GitCode payloads are far smaller.
+const maxWebhookBody = 5 << 20
+
+func registerWebhookRoutes(r chi.Router, st *store.Store, sy *syncer.Syncer) {
+	logger := slog.Default().With("component", "api", "op", "webhook")
+	secret := os.Getenv("GITCODE_WEBHOOK_SECRET")
+
+	r.Post("/api/webhooks/gitcode", func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		if secret == "" {
+			logger.Warn("webhook rejected", "reason", "GITCODE_WEBHOOK_SECRET not set")
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "webhook secret not configured"})
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxWebhookBody))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "read body failed"})
+			return
+		}
+		if err := gitcode.VerifyWebhook(req.Header, body, secret); err != nil {
+			logger.Warn("webhook rejected", "reason", err.Error(), "remote", req.RemoteAddr)
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": err.Error()})
+			return
+		}
+
+		ev, err := gitcode.ParseWebhook(body)
+		if errors.Is(err, gitcode.ErrUnsupportedEvent) {
+			logger.Info("webhook ignored", "reason", "unsupported event")
+			writeJSON(w, http.StatusAccepted, map[string]any{"ignored": true})
+			return
+		}
+		if err != nil {
+			logger.Warn("webhook invalid payload", "err", err)
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+
+		// 只排队，立即应答；重新拉取条目由 syncer 的 webhook worker 在后台完成。
+		id := gitcode.DeliveryID(req.Header, body)
+		fresh, err := st.BeginDelivery(req.Context(), store.WebhookDelivery{
+			ID: id, Event: ev.Event, RepoFullName: ev.RepoFullName, Kind: ev.Kind, Key: ev.Key,
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !fresh {
+			writeJSON(w, http.StatusOK, map[string]any{"duplicate": true, "delivery": id})
+			return
+		}
+		sy.WakeWebhooks()
+
+		logger.Info("webhook queued", "delivery", id, "event", ev.Event, "repo", ev.RepoFullName, "kind", ev.Kind, "key", ev.Key,
+			"elapsed_ms", time.Since(start).Milliseconds())
+		writeJSON(w, http.StatusAccepted, map[string]any{"status": "queued", "delivery": id, "event": ev.Event, "kind": ev.Kind, "repo": ev.RepoFullName, "key": ev.Key})
+	})
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"tracker/internal/gitcode/gitcodetest"
+)
+
+func TestWebhookQueuesDelivery(t *testing.T) {
+	t.Setenv("GITCODE_WEBHOOK_SECRET", "s3cret")
+	srv, gc := testAPI(t)
+	gc.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "crash"})
+	post := func(token, body string) (int, map[string]any) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/webhooks/gitcode", strings.NewReader(body))
+		req.Header.Set("X-GitCode-Token", token)
+		req.Header.Set("X-GitCode-Delivery", "d-1")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var out map[string]any
+		_ = json.NewDecoder(res.Body).Decode(&out)
+		return res.StatusCode, out
+	}
+	body := `{"object_kind":"issue","project":{"path_with_namespace":"o/r"},"object_attributes":{"iid":1}}`
+
+	if status, _ := post("wrong", body); status != http.StatusUnauthorized {
+		t.Errorf("bad token = %d, want 401", status)
+	}
+	status, out := post("s3cret", body)
+	if status != http.StatusAccepted || out["status"] != "queued" || out["delivery"] != "d-1" {
+		t.Fatalf("delivery = %d %v, want 202 queued", status, out)
+	}
+	// 应答前不访问 GitCode；重新拉取交给后台 worker。
+	if n := len(gc.Requests()); n != 0 {
+		t.Errorf("upstream requests before the answer = %d", n)
+	}
+	if status, out := post("s3cret", body); status != http.StatusOK || out["duplicate"] != true {
+		t.Errorf("redelivery = %d %v, want duplicate", status, out)
+	}
+	if status, out := post("s3cret", `{"object_kind":"push"}`); status != http.StatusAccepted || out["ignored"] != true {
+		t.Errorf("push event = %d %v, want ignored", status, out)
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"tracker/internal/provider"
+	"tracker/internal/store"
+	"tracker/internal/syncer"
+)
+
+func registerWriteRoutes(r chi.Router, st *store.Store, sy *syncer.Syncer) {
+	logger := slog.Default().With("component", "api", "op", "write-back")
+
+	// write decodes the body into a syncer.Write, lets check validate it, and
+	// pushes it to GitCode.
+	write := func(action string, check func(*syncer.Write) string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			kind := chi.URLParam(req, "kind")
+			repoFullName := chi.URLParam(req, "owner") + "/" + chi.URLParam(req, "repo")
+			key := chi.URLParam(req, "key")
+
+			body := syncer.Write{Action: action}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+				return
+			}
+			if msg := check(&body); msg != "" {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": msg})
+				return
+			}
+
+			rec, err := sy.WriteBack(req.Context(), repoFullName, kind, key, body)
+			var upstream *syncer.UpstreamError
+			var httpErr *provider.HTTPError
+			switch {
+			case err == nil:
+				writeJSON(w, http.StatusOK, map[string]any{"write": rec})
+			case store.IsNotFound(err):
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+			case errors.Is(err, syncer.ErrUntracked), errors.Is(err, syncer.ErrWriteUnsupported):
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			case errors.Is(err, syncer.ErrMissingToken):
+				writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": err.Error()})
+			case errors.As(err, &httpErr):
+				// GitCode 拒绝了请求：把它的状态码和原因原样告诉调用方。
+				writeJSON(w, http.StatusBadGateway, map[string]any{
+					"error":           "gitcode rejected the request: " + httpErr.Message(),
+					"upstreamStatus":  httpErr.Status,
+					"upstreamMessage": httpErr.Message(),
+					"write":           rec,
+				})
+			case errors.As(err, &upstream):
+				writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error(), "write": rec})
+			default:
+				logger.Error("write-back failed", "kind", kind, "repo", repoFullName, "key", key, "action", action, "err", err)
+				writeError(w, http.StatusInternalServerError, err)
+			}
+		}
+	}
+
+	r.Post("/api/items/{kind}/{owner}/{repo}/{key}/comments", write("comment", func(b *syncer.Write) string {
+		if strings.TrimSpace(b.Body) == "" {
+			return "body is required"
+		}
+		return ""
+	}))
+
+	r.Post("/api/items/{kind}/{owner}/{repo}/{key}/labels", write("labels", func(b *syncer.Write) string {
+		b.Add, b.Remove = trimNames(b.Add), trimNames(b.Remove)
+		if len(b.Add) == 0 && len(b.Remove) == 0 {
+			return "add or remove is required"
+		}
+		return ""
+	}))
+
+	r.Put("/api/items/{kind}/{owner}/{repo}/{key}/assignees", write("assignees", func(b *syncer.Write) string {
+		// 空列表表示清空指派人。
+		b.Assignees = trimNames(b.Assignees)
+		return ""
+	}))
+
+	r.Get("/api/items/{kind}/{owner}/{repo}/{key}/writes", func(w http.ResponseWriter, req *http.Request) {
+		kind := chi.URLParam(req, "kind")
+		repoFullName := chi.URLParam(req, "owner") + "/" + chi.URLParam(req, "repo")
+		key := chi.URLParam(req, "key")
+
+		writes, err := st.ListWrites(req.Context(), kind, repoFullName, key)
+		if err != nil {
+			if store.IsNotFound(err) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": "not found"})
+				return
+			}
+			logger.Error("list writes failed", "kind", kind, "repo", repoFullName, "key", key, "err", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"writes": writes})
+	})
+}
+
+func trimNames(in []string) []string {
+	out := []string{}
+	for _, s := range in {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
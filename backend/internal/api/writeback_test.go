@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"tracker/internal/gitcode/gitcodetest"
+	"tracker/internal/store"
+)
+
+func sendJSON(t *testing.T, method, url, body string, v any) int {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res.StatusCode
+}
+
+func TestWriteBack(t *testing.T) {
+	srv, gc := testAPI(t)
+	gc.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "crash", Labels: []string{"triage"}})
+	syncNow(t, srv)
+	base := srv.URL + "/api/items/issue/o/r/1"
+
+	var out struct {
+		Write           store.ItemWrite `json:"write"`
+		UpstreamStatus  int             `json:"upstreamStatus"`
+		UpstreamMessage string          `json:"upstreamMessage"`
+		Error           string          `json:"error"`
+	}
+	if status := sendJSON(t, http.MethodPost, base+"/comments", `{"body":"looking"}`, &out); status != http.StatusOK || out.Write.Status != "ok" {
+		t.Fatalf("comment = %d %+v", status, out)
+	}
+	if is, _ := gc.Issue("o/r", 1); len(is.Comments) != 1 || is.Comments[0].Body != "looking" {
+		t.Errorf("upstream comments = %+v", is.Comments)
+	}
+
+	// 一个标签删除失败，其余照常生效，每个标签的结果都记下来。
+	out.Write = store.ItemWrite{}
+	status := sendJSON(t, http.MethodPost, base+"/labels", `{"add":["kind/bug"],"remove":["missing","triage"]}`, &out)
+	if status != http.StatusBadGateway || out.UpstreamStatus != http.StatusNotFound || out.Write.Status != "failed" {
+		t.Fatalf("labels = %d %+v", status, out)
+	}
+	var payload struct {
+		Results []struct {
+			Label, Op, Status string
+			UpstreamStatus    int
+		}
+	}
+	if err := json.Unmarshal(out.Write.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range payload.Results {
+		got = append(got, r.Op+" "+r.Label+" "+r.Status)
+	}
+	if want := []string{"add kind/bug ok", "remove missing failed", "remove triage ok"}; !slices.Equal(got, want) {
+		t.Errorf("label results = %v, want %v", got, want)
+	}
+	if is, _ := gc.Issue("o/r", 1); !slices.Equal(is.Labels, []string{"kind/bug"}) {
+		t.Errorf("upstream labels = %v", is.Labels)
+	}
+	// 部分成功也会刷新本地条目。
+	var list struct {
+		Items []store.Item `json:"items"`
+	}
+	getJSON(t, srv.URL+"/api/items?kind=issue", &list)
+	if len(list.Items) != 1 || len(list.Items[0].Labels) != 1 || list.Items[0].Labels[0].Name != "kind/bug" {
+		t.Errorf("local items = %+v", list.Items)
+	}
+
+	if status := sendJSON(t, http.MethodPut, base+"/assignees", `{"assignees":[" ann "]}`, &out); status != http.StatusOK {
+		t.Fatalf("assignees = %d %+v", status, out)
+	}
+	if is, _ := gc.Issue("o/r", 1); !slices.Equal(is.Assignees, []string{"ann"}) {
+		t.Errorf("upstream assignees = %v", is.Assignees)
+	}
+
+	var history struct {
+		Writes []store.ItemWrite `json:"writes"`
+	}
+	if status := getJSON(t, base+"/writes", &history); status != http.StatusOK || len(history.Writes) != 3 || history.Writes[0].Action != "assignees" {
+		t.Errorf("writes = %d %+v", status, history.Writes)
+	}
+}
+
+func TestWriteBackRejectsBadRequests(t *testing.T) {
+	srv, gc := testAPI(t)
+	gc.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "crash"})
+	syncNow(t, srv)
+
+	for _, tc := range []struct {
+		name, method, path, body string
+		want                     int
+	}{
+		{"empty comment", http.MethodPost, "/api/items/issue/o/r/1/comments", `{"body":"  "}`, http.StatusBadRequest},
+		{"no label change", http.MethodPost, "/api/items/issue/o/r/1/labels", `{"add":[" "]}`, http.StatusBadRequest},
+		{"invalid json", http.MethodPost, "/api/items/issue/o/r/1/comments", `{`, http.StatusBadRequest},
+		{"untracked repo", http.MethodPost, "/api/items/issue/o/other/1/comments", `{"body":"x"}`, http.StatusBadRequest},
+		{"item not synced", http.MethodPost, "/api/items/issue/o/r/9/comments", `{"body":"x"}`, http.StatusNotFound},
+	} {
+		if status := sendJSON(t, tc.method, srv.URL+tc.path, tc.body, nil); status != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, status, tc.want)
+		}
+	}
+	if n := gc.Hits("/api/v5/repos/o/r/issues/*/comments"); n != 0 {
+		t.Errorf("upstream writes = %d, want none", n)
+	}
+}
@@ -4,188 +4,253 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
+
+	"tracker/internal/provider"
 )
 
 type Client struct {
 	baseURL string
-	token   string
+	tokens  *TokenPool
 	http    *http.Client
+
+	retry *provider.Retrier
 }
 
+var _ provider.Provider = (*Client)(nil)
+
 func NewClient(baseURL, token string) *Client {
 	baseURL = strings.TrimRight(baseURL, "/")
 	return &Client{
 		baseURL: baseURL,
-		token:   token,
+		tokens:  NewTokenPool(token),
 		http: &http.Client{
 			Timeout: 20 * time.Second,
 		},
+		retry: provider.NewRetrier("gitcode", provider.DefaultRetryPolicy()),
 	}
 }
 
-type RemoteItem struct {
-	Key       string
-	Title     string
-	State     string
-	URL       string
-	Author    string
-	CreatedAt string
-	UpdatedAt string
+// WithTokens makes the client authenticate from a token pool instead of its
+// single token and returns the client. Clients may share a pool.
+func (c *Client) WithTokens(p *TokenPool) *Client {
+	c.tokens = p
+	return c
 }
 
-func (c *Client) ListIssues(ctx context.Context, owner, repo string) ([]RemoteItem, error) {
+// TokenHealth reports the state of the client's tokens without their secrets.
+func (c *Client) TokenHealth() []TokenHealth { return c.tokens.Health() }
+
+func (c *Client) ListIssues(ctx context.Context, owner, repo string, opts provider.ListOptions) (provider.ListResult, error) {
 	logger := slog.Default().With("component", "gitcode", "op", "list-issues", "repo", owner+"/"+repo)
-	logger.Debug("list issues start")
-	return c.listPaged(ctx, fmt.Sprintf("/api/v5/repos/%s/%s/issues", url.PathEscape(owner), url.PathEscape(repo)))
+	logger.Debug("list issues start", "since", opts.Since)
+	return c.listPaged(ctx, fmt.Sprintf("/api/v5/repos/%s/%s/issues", url.PathEscape(owner), url.PathEscape(repo)), opts, decodeIssues)
 }
 
-func (c *Client) ListPulls(ctx context.Context, owner, repo string) ([]RemoteItem, error) {
+func (c *Client) ListPulls(ctx context.Context, owner, repo string, opts provider.ListOptions) (provider.ListResult, error) {
 	logger := slog.Default().With("component", "gitcode", "op", "list-pulls", "repo", owner+"/"+repo)
-	logger.Debug("list pulls start")
-	return c.listPaged(ctx, fmt.Sprintf("/api/v5/repos/%s/%s/pulls", url.PathEscape(owner), url.PathEscape(repo)))
+	logger.Debug("list pulls start", "since", opts.Since)
+	return c.listPaged(ctx, fmt.Sprintf("/api/v5/repos/%s/%s/pulls", url.PathEscape(owner), url.PathEscape(repo)), opts, decodePulls)
 }
 
-func (c *Client) listPaged(ctx context.Context, path string) ([]RemoteItem, error) {
-	logger := slog.Default().With("component", "gitcode", "op", "list-paged", "path", path)
-	start := time.Now()
-	out := []RemoteItem{}
-	for page := 1; page <= 50; page++ { // safety cap
-		u, err := url.Parse(c.baseURL + path)
-		if err != nil {
-			logger.Error("parse url failed", "err", err)
-			return nil, err
-		}
-		q := u.Query()
-		q.Set("state", "all")
-		q.Set("per_page", "100")
-		q.Set("page", strconv.Itoa(page))
-		u.RawQuery = q.Encode()
+func (c *Client) Name() string { return "gitcode" }
 
-		items, err := c.getList(ctx, u.String())
-		if err != nil {
-			logger.Error("request failed", "page", page, "url", u.String(), "err", err)
-			return nil, err
-		}
-		if len(items) == 0 {
-			logger.Debug("page empty", "page", page)
-			break
-		}
-		out = append(out, items...)
-		logger.Debug("page ok", "page", page, "count", len(items))
+// GetIssue fetches a single issue.
+func (c *Client) GetIssue(ctx context.Context, owner, repo, number string) (provider.Item, error) {
+	logger := slog.Default().With("component", "gitcode", "op", "get-issue", "repo", owner+"/"+repo, "key", number)
+	fullURL := fmt.Sprintf("%s/api/v5/repos/%s/%s/issues/%s", c.baseURL, url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(number))
+	res, err := c.get(ctx, fullURL)
+	if err != nil {
+		logger.Error("request failed", "err", err)
+		return provider.Item{}, err
 	}
-	logger.Info("list paged ok", "total", len(out), "elapsed_ms", time.Since(start).Milliseconds())
-	return out, nil
+	var it Issue
+	if err := json.Unmarshal(res.Body, &it); err != nil {
+		logger.Error("decode issue failed", "err", err)
+		return provider.Item{}, fmt.Errorf("decode issue: %w", err)
+	}
+	return it.toRemote(), nil
 }
 
-func (c *Client) getList(ctx context.Context, fullURL string) ([]RemoteItem, error) {
-	logger := slog.Default().With("component", "gitcode", "op", "get-list")
-	start := time.Now()
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+// GetPull fetches a single PR, which carries size and mergeability fields the list endpoint omits.
+func (c *Client) GetPull(ctx context.Context, owner, repo, number string) (provider.Item, error) {
+	logger := slog.Default().With("component", "gitcode", "op", "get-pull", "repo", owner+"/"+repo, "key", number)
+	fullURL := fmt.Sprintf("%s/api/v5/repos/%s/%s/pulls/%s", c.baseURL, url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(number))
+	res, err := c.get(ctx, fullURL)
 	if err != nil {
-		logger.Error("build request failed", "err", err)
-		return nil, err
+		logger.Error("request failed", "err", err)
+		return provider.Item{}, err
+	}
+	var pr PullRequest
+	if err := json.Unmarshal(res.Body, &pr); err != nil {
+		logger.Error("decode pull failed", "err", err)
+		return provider.Item{}, fmt.Errorf("decode pull: %w", err)
 	}
+	return pr.toRemote(), nil
+}
 
-	// GitCode 文档支持 Authorization: Bearer 和 PRIVATE-TOKEN。
-	// 这里优先用 Bearer，同时也填 PRIVATE-TOKEN 以兼容不同部署。
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	req.Header.Set("PRIVATE-TOKEN", c.token)
+func (c *Client) listPaged(ctx context.Context, path string, opts provider.ListOptions, decode func([]byte) ([]provider.Item, error)) (provider.ListResult, error) {
+	logger := slog.Default().With("component", "gitcode", "op", "list-paged", "path", path)
+	start := time.Now()
 
-	res, err := c.http.Do(req)
-	if err != nil {
-		logger.Error("http request failed", "url", fullURL, "err", err)
-		return nil, err
+	since, hasSince := time.Time{}, false
+	if opts.Since != "" {
+		since, hasSince = provider.ParseTime(opts.Since)
+		if !hasSince {
+			logger.Warn("ignore unparsable since", "since", opts.Since)
+		}
 	}
-	defer res.Body.Close()
-
-	body, _ := io.ReadAll(io.LimitReader(res.Body, 4<<20))
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		logger.Error("non-2xx response", "url", fullURL, "status", res.StatusCode, "body", strings.TrimSpace(string(body)))
-		return nil, fmt.Errorf("gitcode %s: status=%d body=%s", fullURL, res.StatusCode, strings.TrimSpace(string(body)))
+	maxPages := opts.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
 	}
 
-	var raw []map[string]any
-	if err := json.Unmarshal(body, &raw); err != nil {
-		logger.Error("decode list failed", "url", fullURL, "err", err)
-		return nil, fmt.Errorf("decode list: %w", err)
+	u, err := url.Parse(c.baseURL + path)
+	if err != nil {
+		logger.Error("parse url failed", "err", err)
+		return provider.ListResult{}, err
 	}
+	q := u.Query()
+	q.Set("state", "all")
+	q.Set("per_page", strconv.Itoa(perPage))
+	q.Set("page", "1")
+	if hasSince {
+		// 按更新时间正序翻页，被截断时下次可以从已拉到的最新时间接着拉；
+		// pulls 接口不一定支持 since，所以本地也会再过滤一次。
+		q.Set("since", opts.Since)
+		q.Set("sort", "updated")
+		q.Set("direction", "asc")
+	}
+	u.RawQuery = q.Encode()
 
-	items := make([]RemoteItem, 0, len(raw))
-	for _, m := range raw {
-		key := firstString(m, "number", "iid", "id")
-		if key == "" {
-			continue
+	res := provider.ListResult{Items: []provider.Item{}}
+	// 只有上游确实按更新时间正序返回时，截断后才能从已拉到的最新时间接着拉。
+	order := provider.UpdateOrder{Asc: true}
+	resume := func() string {
+		if !hasSince || !order.Sorted() {
+			return ""
 		}
-		title := firstString(m, "title")
-		state := firstString(m, "state")
-		urlStr := firstString(m, "html_url", "web_url", "url")
-		createdAt := firstString(m, "created_at", "createdAt")
-		updatedAt := firstString(m, "updated_at", "updatedAt")
-
-		author := ""
-		if v, ok := m["user"].(map[string]any); ok {
-			author = firstString(v, "login", "username", "name")
+		return provider.LatestUpdatedAt(res.Items)
+	}
+	for page := 1; ; page++ {
+		if opts.BeforePage != nil {
+			if err := opts.BeforePage(ctx); err != nil {
+				return provider.ListResult{}, err
+			}
 		}
-		if author == "" {
-			if v, ok := m["author"].(map[string]any); ok {
-				author = firstString(v, "login", "username", "name")
+		var cached provider.PageValidators
+		var hdr http.Header
+		if opts.Cache != nil {
+			if v, ok := opts.Cache.Lookup(ctx, u.String()); ok {
+				cached, hdr = v, conditionalHeader(v)
 			}
 		}
-		if author == "" {
-			author = firstString(m, "author")
+		items, header, notModified, err := c.getList(ctx, u.String(), hdr, decode)
+		if err != nil {
+			logger.Error("request failed", "page", page, "url", u.String(), "err", err)
+			return provider.ListResult{}, err
 		}
+		res.Pages++
 
-		items = append(items, RemoteItem{
-			Key:       key,
-			Title:     title,
-			State:     state,
-			URL:       urlStr,
-			Author:    author,
-			CreatedAt: createdAt,
-			UpdatedAt: updatedAt,
-		})
-	}
-	logger.Debug("get list ok", "url", fullURL, "count", len(items), "elapsed_ms", time.Since(start).Milliseconds())
-	return items, nil
-}
+		if notModified {
+			res.CacheHits++
+			logger.Debug("page not modified", "page", page)
+			// 沿着上次记下的下一页继续；新的更新都排在最后。
+			if cached.Next == "" {
+				break
+			}
+			next, err := u.Parse(cached.Next)
+			if err != nil {
+				break
+			}
+			if page >= maxPages {
+				res.Truncated, res.Resume = true, resume()
+				logger.Warn("list truncated at page limit", "pages", page, "max_pages", maxPages, "total", len(res.Items), "resume", res.Resume)
+				break
+			}
+			u = next
+			continue
+		}
+		if opts.Cache != nil {
+			res.CacheMisses++
+		}
 
-func firstString(m map[string]any, keys ...string) string {
-	for _, k := range keys {
-		if v, ok := m[k]; ok {
-			s := anyToString(v)
-			if s != "" {
-				return s
+		count := len(items)
+		order.Add(items)
+		if hasSince {
+			fresh := items[:0]
+			for _, it := range items {
+				if t, ok := provider.ParseTime(it.UpdatedAt); ok && t.Before(since) {
+					continue
+				}
+				fresh = append(fresh, it)
 			}
+			logger.Debug("page ok", "page", page, "count", len(fresh), "skipped", len(items)-len(fresh))
+			items = fresh
+		} else {
+			logger.Debug("page ok", "page", page, "count", len(items))
+		}
+		res.Items = append(res.Items, items...)
+
+		next, more := nextPage(header, u, page, count)
+		if opts.Cache != nil {
+			cacheNext, cacheMore := next, more
+			if hasSince && !more && count > 0 {
+				// 正序时新的更新会追加到末尾：最后一页满了的话它本身不变，
+				// 所以记下后一页，下次 304 后还要看一眼。
+				cacheNext, cacheMore = withPage(u, page+1), true
+			}
+			if v, ok := pageValidators(header, u, cacheNext, cacheMore); ok {
+				res.Validators = append(res.Validators, v)
+			}
+		}
+		if !more {
+			break
+		}
+		if page >= maxPages {
+			res.Truncated, res.Resume = true, resume()
+			logger.Warn("list truncated at page limit", "pages", page, "max_pages", maxPages, "total", len(res.Items), "resume", res.Resume)
+			break
 		}
+		u = next
 	}
-	return ""
+	logger.Info("list paged ok", "total", len(res.Items), "pages", res.Pages, "truncated", res.Truncated,
+		"cache_hits", res.CacheHits, "cache_misses", res.CacheMisses, "elapsed_ms", time.Since(start).Milliseconds())
+	return res, nil
 }
 
-func anyToString(v any) string {
-	switch t := v.(type) {
-	case string:
-		return t
-	case float64:
-		// JSON numbers decode as float64
-		if t == float64(int64(t)) {
-			return strconv.FormatInt(int64(t), 10)
+// getList fetches and decodes one list page. A 304 to a conditional request
+// is reported as notModified without decoding anything.
+func (c *Client) getList(ctx context.Context, fullURL string, hdr http.Header, decode func([]byte) ([]provider.Item, error)) (_ []provider.Item, _ http.Header, notModified bool, _ error) {
+	logger := slog.Default().With("component", "gitcode", "op", "get-list")
+	start := time.Now()
+	res, err := c.getWith(ctx, fullURL, hdr)
+	if err != nil {
+		logger.Error("request failed", "url", fullURL, "err", err)
+		return nil, nil, false, err
+	}
+	if res.Status == http.StatusNotModified {
+		return nil, res.Header, true, nil
+	}
+
+	decoded, err := decode(res.Body)
+	if err != nil {
+		logger.Error("decode list failed", "url", fullURL, "err", err)
+		return nil, nil, false, fmt.Errorf("decode list: %w", err)
+	}
+
+	items := make([]provider.Item, 0, len(decoded))
+	for _, it := range decoded {
+		if it.Key == "" {
+			continue
 		}
-		return strconv.FormatFloat(t, 'f', -1, 64)
-	case int:
-		return strconv.Itoa(t)
-	case int64:
-		return strconv.FormatInt(t, 10)
-	case json.Number:
-		return t.String()
-	default:
-		return ""
+		items = append(items, it)
 	}
+	logger.Debug("get list ok", "url", fullURL, "count", len(items), "elapsed_ms", time.Since(start).Milliseconds())
+	return items, res.Header, false, nil
 }
@@ -0,0 +1,480 @@
+package gitcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"tracker/internal/gitcode/gitcodetest"
+	"tracker/internal/provider"
+)
+
+func testClient(t *testing.T) (*Client, *gitcodetest.Server) {
+	t.Helper()
+	srv := gitcodetest.NewServer()
+	t.Cleanup(srv.Close)
+	c := NewClient(srv.URL, "tok").WithRetry(provider.RetryPolicy{
+		MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second,
+	})
+	return c, srv
+}
+
+func TestListIssuesPaging(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		paging gitcodetest.Paging
+		pages  int
+	}{
+		{"link", gitcodetest.PagingLink, 3},
+		{"total_page", gitcodetest.PagingTotalPage, 3},
+		// 没有分页提示时要多请求一次空页才知道结束。
+		{"none", gitcodetest.PagingNone, 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, srv := testClient(t)
+			srv.SetPaging(tc.paging, 2)
+			for n := 1; n <= 5; n++ {
+				srv.AddIssue("o/r", gitcodetest.Issue{Number: n, Title: fmt.Sprintf("issue %d", n), Author: "ann"})
+			}
+
+			res, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Items) != 5 || res.Pages != tc.pages || res.Truncated {
+				t.Fatalf("items=%d pages=%d truncated=%v, want 5 items in %d pages", len(res.Items), res.Pages, res.Truncated, tc.pages)
+			}
+			if got := srv.Hits("/api/v5/repos/o/r/issues"); got != tc.pages {
+				t.Errorf("requests = %d, want %d", got, tc.pages)
+			}
+		})
+	}
+}
+
+func TestListIssuesRunsBeforePageForEveryPage(t *testing.T) {
+	c, srv := testClient(t)
+	srv.SetPaging(gitcodetest.PagingLink, 2)
+	for n := 1; n <= 5; n++ {
+		srv.AddIssue("o/r", gitcodetest.Issue{Number: n, Title: fmt.Sprintf("issue %d", n)})
+	}
+	calls := 0
+	opts := provider.ListOptions{BeforePage: func(context.Context) error {
+		if calls++; calls == 3 {
+			return context.DeadlineExceeded
+		}
+		return nil
+	}}
+
+	// 第三页之前的钩子出错：列表以该错误结束，第三页不再请求。
+	if _, err := c.ListIssues(context.Background(), "o", "r", opts); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the hook's error", err)
+	}
+	if got := srv.Hits("/api/v5/repos/o/r/issues"); calls != 3 || got != 2 {
+		t.Errorf("hook calls = %d, requests = %d, want 3 and 2", calls, got)
+	}
+}
+
+func TestListIssuesMapsFields(t *testing.T) {
+	c, srv := testClient(t)
+	srv.AddIssue("o/r", gitcodetest.Issue{
+		Number: 7, Title: "crash", State: "closed", Author: "ann", Body: "steps",
+		Labels: []string{"kind/bug"}, Assignees: []string{"bo", "cy"}, Milestone: "v1",
+		ClosedAt: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		Comments: []gitcodetest.Comment{{ID: 1, Author: "bo", Body: "ack"}},
+	})
+
+	res, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 1 {
+		t.Fatalf("items = %+v", res.Items)
+	}
+	it := res.Items[0]
+	if it.Key != "7" || it.ID != "100007" || it.State != "closed" || it.Author != "ann" || it.Milestone != "v1" || it.Comments != 1 {
+		t.Errorf("issue = %+v", it)
+	}
+	if it.URL != "https://gitcode.com/o/r/issues/7" || it.ClosedAt == "" {
+		t.Errorf("url=%q closedAt=%q", it.URL, it.ClosedAt)
+	}
+	if len(it.Labels) != 1 || it.Labels[0].Name != "kind/bug" || !slices.Equal(it.Assignees, []string{"bo", "cy"}) {
+		t.Errorf("labels=%+v assignees=%v", it.Labels, it.Assignees)
+	}
+}
+
+func TestListIssuesSince(t *testing.T) {
+	c, srv := testClient(t)
+	srv.SetPaging(gitcodetest.PagingLink, 1)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "old", UpdatedAt: day(1)})
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 2, Title: "new", UpdatedAt: day(5)})
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 3, Title: "newer", UpdatedAt: day(6)})
+
+	res, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{Since: day(4).Format(time.RFC3339)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 2 || res.Items[0].Key != "2" || res.Items[1].Key != "3" {
+		t.Fatalf("items = %+v, want 2 then 3", res.Items)
+	}
+	q := srv.Requests()[0].Query
+	if q.Get("since") == "" || q.Get("sort") != "updated" || q.Get("direction") != "asc" {
+		t.Errorf("query = %v, want since and oldest update first", q)
+	}
+}
+
+func TestListIssuesSinceResumesAfterTruncation(t *testing.T) {
+	c, srv := testClient(t)
+	srv.SetPaging(gitcodetest.PagingLink, 1)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	for n := 1; n <= 4; n++ {
+		srv.AddIssue("o/r", gitcodetest.Issue{Number: n, UpdatedAt: day(n + 1)})
+	}
+	ctx := context.Background()
+
+	res, err := c.ListIssues(ctx, "o", "r", provider.ListOptions{Since: day(1).Format(time.RFC3339), MaxPages: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated || len(res.Items) != 2 || res.Resume != res.Items[1].UpdatedAt {
+		t.Fatalf("truncated=%v items=%d resume=%q, want to resume after the second issue", res.Truncated, len(res.Items), res.Resume)
+	}
+
+	// 从 Resume 接着拉，剩下的条目都能拿到，不会每次都重拉最新的几页。
+	res, err = c.ListIssues(ctx, "o", "r", provider.ListOptions{Since: res.Resume, MaxPages: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated || len(res.Items) != 2 || res.Items[1].Key != "3" {
+		t.Fatalf("second listing = %+v", res.Items)
+	}
+	res, err = c.ListIssues(ctx, "o", "r", provider.ListOptions{Since: res.Resume, MaxPages: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Truncated || res.Resume != "" || len(res.Items) != 2 || res.Items[1].Key != "4" {
+		t.Errorf("last listing: truncated=%v resume=%q items=%+v", res.Truncated, res.Resume, res.Items)
+	}
+}
+
+func TestListIssuesIgnoredSortIsNotResumed(t *testing.T) {
+	c, srv := testClient(t)
+	srv.SetPaging(gitcodetest.PagingLink, 1)
+	srv.IgnoreSort(true)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	for n := 1; n <= 3; n++ {
+		srv.AddIssue("o/r", gitcodetest.Issue{Number: n, UpdatedAt: day(n + 1)})
+	}
+
+	// 上游没按更新时间排序：截断时拿到的是最新的几条，不能从它们接着拉，否则会跳过 #1。
+	res, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{Since: day(1).Format(time.RFC3339), MaxPages: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated || res.Resume != "" || len(res.Items) != 2 {
+		t.Errorf("truncated=%v resume=%q items=%d, want a truncated listing that cannot be resumed", res.Truncated, res.Resume, len(res.Items))
+	}
+
+	res, err = c.ListIssues(context.Background(), "o", "r", provider.ListOptions{Since: day(1).Format(time.RFC3339)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 3 {
+		t.Errorf("items = %d, want every issue", len(res.Items))
+	}
+}
+
+func TestListPullsFiltersSinceLocally(t *testing.T) {
+	c, srv := testClient(t)
+	srv.SetPaging(gitcodetest.PagingLink, 1)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	for n := 1; n <= 4; n++ {
+		srv.AddPull("o/r", gitcodetest.Pull{Number: n, Title: "pr", UpdatedAt: day(n), Head: "f", Base: "main"})
+	}
+
+	// 假服务器和部分 GitCode 部署一样忽略 pulls 的 since，客户端要自己过滤。
+	res, err := c.ListPulls(context.Background(), "o", "r", provider.ListOptions{Since: day(3).Format(time.RFC3339)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 2 || res.Items[0].Key != "3" || res.Items[1].Key != "4" {
+		t.Fatalf("items = %+v, want 3 then 4", res.Items)
+	}
+	if got := srv.Hits("/api/v5/repos/o/r/pulls"); got != 4 {
+		t.Errorf("requests = %d, want every page", got)
+	}
+	if res.Items[0].PR == nil || res.Items[0].PR.HasSize {
+		t.Errorf("list PR meta = %+v, want no size fields", res.Items[0].PR)
+	}
+}
+
+// memCache is a provider.PageCache over the validators of earlier results.
+type memCache map[string]provider.PageValidators
+
+func (m memCache) Lookup(_ context.Context, url string) (provider.PageValidators, bool) {
+	v, ok := m[url]
+	return v, ok
+}
+
+func (m memCache) save(res provider.ListResult) {
+	for _, v := range res.Validators {
+		m[v.URL] = v
+	}
+}
+
+func TestListIssuesConditional(t *testing.T) {
+	c, srv := testClient(t)
+	srv.SetPaging(gitcodetest.PagingLink, 2)
+	for n := 1; n <= 5; n++ {
+		srv.AddIssue("o/r", gitcodetest.Issue{Number: n, Title: fmt.Sprintf("issue %d", n)})
+	}
+	ctx := context.Background()
+	cache := memCache{}
+	opts := provider.ListOptions{Cache: cache}
+
+	res, err := c.ListIssues(ctx, "o", "r", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 5 || res.CacheHits != 0 || res.CacheMisses != 3 || len(res.Validators) != 3 {
+		t.Fatalf("first run: items=%d hits=%d misses=%d validators=%d", len(res.Items), res.CacheHits, res.CacheMisses, len(res.Validators))
+	}
+	cache.save(res)
+
+	// 没有 since 时，304 的页沿着缓存的下一页继续，只有变化的页才返回数据。
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "renamed"})
+	res, err = c.ListIssues(ctx, "o", "r", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.CacheHits != 2 || res.CacheMisses != 1 || res.Pages != 3 {
+		t.Fatalf("second run: hits=%d misses=%d pages=%d", res.CacheHits, res.CacheMisses, res.Pages)
+	}
+	if len(res.Items) != 1 || res.Items[0].Title != "renamed" {
+		t.Errorf("second run items = %+v", res.Items)
+	}
+	var conditional int
+	for _, r := range srv.Requests() {
+		if r.Path == "/api/v5/repos/o/r/issues" && r.Header.Get("If-None-Match") != "" {
+			conditional++
+		}
+	}
+	if conditional != 3 {
+		t.Errorf("conditional requests = %d, want 3", conditional)
+	}
+}
+
+func TestListIssuesConditionalSince(t *testing.T) {
+	c, srv := testClient(t)
+	srv.SetPaging(gitcodetest.PagingLink, 1)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, UpdatedAt: day(5)})
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 2, UpdatedAt: day(6)})
+	ctx := context.Background()
+	cache := memCache{}
+	opts := provider.ListOptions{Since: day(2).Format(time.RFC3339), Cache: cache}
+
+	res, err := c.ListIssues(ctx, "o", "r", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.save(res)
+	res, err = c.ListIssues(ctx, "o", "r", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按更新时间正序，没变的页之后还要看一眼最后一页的下一页。
+	if len(res.Items) != 0 || res.CacheHits != 2 || res.CacheMisses != 1 || res.Pages != 3 {
+		t.Fatalf("items=%d hits=%d misses=%d pages=%d, want both pages 304 and an empty page after", len(res.Items), res.CacheHits, res.CacheMisses, res.Pages)
+	}
+	cache.save(res)
+
+	// 新的更新落在已满的最后一页之后，也要能拉到。
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 3, UpdatedAt: day(7)})
+	res, err = c.ListIssues(ctx, "o", "r", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 1 || res.Items[0].Key != "3" || res.CacheHits != 2 {
+		t.Errorf("items=%+v hits=%d, want the new issue after two 304s", res.Items, res.CacheHits)
+	}
+}
+
+func TestGetPull(t *testing.T) {
+	c, srv := testClient(t)
+	srv.AddPull("o/r", gitcodetest.Pull{
+		Number: 9, Title: "fix", State: "merged", MergedBy: "ann", Head: "fix", Base: "main",
+		Additions: 12, Deletions: 3, ChangedFiles: 2, Mergeable: true, Reviewers: []string{"rev"},
+		MergedAt: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	})
+
+	pr, err := c.GetPull(context.Background(), "o", "r", "9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.State != "merged" || pr.MergedAt == "" || !slices.Equal(pr.Reviewers, []string{"rev"}) {
+		t.Errorf("pr = %+v", pr)
+	}
+	m := pr.PR
+	if m == nil || !m.HasSize || m.Additions != 12 || m.Deletions != 3 || m.ChangedFiles != 2 || m.MergedBy != "ann" ||
+		m.HeadBranch != "fix" || m.BaseBranch != "main" || m.Mergeable == nil || !*m.Mergeable {
+		t.Errorf("pr meta = %+v", m)
+	}
+
+	if _, err := c.GetIssue(context.Background(), "o", "r", "9"); provider.StatusCode(err) != http.StatusNotFound {
+		t.Errorf("GetIssue on a PR number: err = %v, want 404", err)
+	}
+}
+
+func TestListCommentsPaging(t *testing.T) {
+	c, srv := testClient(t)
+	srv.SetPaging(gitcodetest.PagingTotalPage, 2)
+	var comments []gitcodetest.Comment
+	for i := 1; i <= 5; i++ {
+		comments = append(comments, gitcodetest.Comment{ID: i, Author: "ann", Body: fmt.Sprintf("c%d", i)})
+	}
+	srv.AddPull("o/r", gitcodetest.Pull{Number: 2, Title: "pr", Comments: comments})
+
+	got, err := c.ListComments(context.Background(), "o", "r", "pr", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 5 || got[0].Body != "c1" || got[4].ID != "5" || got[0].Author != "ann" {
+		t.Errorf("comments = %+v", got)
+	}
+}
+
+func TestListCommentsReportsTruncation(t *testing.T) {
+	c, srv := testClient(t)
+	srv.SetPaging(gitcodetest.PagingTotalPage, 1)
+	var comments []gitcodetest.Comment
+	for i := 1; i <= DefaultMaxPages+1; i++ {
+		comments = append(comments, gitcodetest.Comment{ID: i, Author: "ann", Body: "c"})
+	}
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Comments: comments})
+
+	got, err := c.ListComments(context.Background(), "o", "r", "issue", "1")
+	if !errors.Is(err, provider.ErrTruncated) || len(got) != DefaultMaxPages {
+		t.Errorf("got %d comments, err = %v, want the first %d with ErrTruncated", len(got), err, DefaultMaxPages)
+	}
+}
+
+func TestListReposFallsBackToUser(t *testing.T) {
+	c, srv := testClient(t)
+	srv.AddRepo(gitcodetest.Repo{Owner: "me", Name: "alpha"})
+	srv.AddRepo(gitcodetest.Repo{Owner: "me", Name: "beta", Archived: true})
+	srv.AddRepo(gitcodetest.Repo{Owner: "other", Name: "alpha"})
+	srv.Inject(gitcodetest.Fault{Path: "/api/v5/orgs/me/repos", Status: http.StatusNotFound, Body: `{"message":"Not Found"}`})
+
+	repos, err := c.ListRepos(context.Background(), "me", provider.RepoFilter{Include: []string{"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []provider.Repo{{Owner: "me", Name: "alpha"}, {Owner: "me", Name: "beta", Archived: true}}
+	if !slices.Equal(repos, want) {
+		t.Errorf("repos = %+v, want %+v", repos, want)
+	}
+	if srv.Hits("/api/v5/users/me/repos") != 1 {
+		t.Errorf("user endpoint not tried")
+	}
+}
+
+func TestListReposReportsTruncation(t *testing.T) {
+	c, srv := testClient(t)
+	srv.SetPaging(gitcodetest.PagingTotalPage, 1)
+	for i := 1; i <= DefaultMaxPages+1; i++ {
+		srv.AddRepo(gitcodetest.Repo{Owner: "o", Name: fmt.Sprintf("r%04d", i)})
+	}
+
+	repos, err := c.ListRepos(context.Background(), "o", provider.RepoFilter{Include: []string{"*"}})
+	if !errors.Is(err, provider.ErrTruncated) || len(repos) != DefaultMaxPages {
+		t.Errorf("got %d repos, err = %v, want the first %d with ErrTruncated", len(repos), err, DefaultMaxPages)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	t.Run("429 with Retry-After", func(t *testing.T) {
+		c, srv := testClient(t)
+		srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a"})
+		srv.Inject(gitcodetest.Fault{
+			Path: "/api/v5/repos/o/r/issues", Status: http.StatusTooManyRequests, Times: 1,
+			Header: http.Header{"Retry-After": {"0"}},
+		})
+		res, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{})
+		if err != nil || len(res.Items) != 1 {
+			t.Fatalf("items=%+v err=%v", res.Items, err)
+		}
+		if got := srv.Hits("/api/v5/repos/o/r/issues"); got != 2 {
+			t.Errorf("requests = %d, want one retry", got)
+		}
+	})
+
+	t.Run("Retry-After beyond MaxDelay is not retried", func(t *testing.T) {
+		c, srv := testClient(t)
+		srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a"})
+		srv.Inject(gitcodetest.Fault{
+			Path: "/api/v5/repos/o/r/issues", Status: http.StatusTooManyRequests, Times: 1,
+			Header: http.Header{"Retry-After": {"60"}},
+		})
+		start := time.Now()
+		_, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{})
+		if provider.StatusCode(err) != http.StatusTooManyRequests {
+			t.Fatalf("err = %v, want 429", err)
+		}
+		if got := srv.Hits("/api/v5/repos/o/r/issues"); got != 1 || time.Since(start) > time.Second {
+			t.Errorf("requests = %d after %v, want one and no early retry", got, time.Since(start))
+		}
+	})
+
+	t.Run("5xx until retries run out", func(t *testing.T) {
+		c, srv := testClient(t)
+		srv.Inject(gitcodetest.Fault{Path: "/api/v5/repos/o/r/issues/*", Status: http.StatusBadGateway, Body: "bad gateway"})
+		_, err := c.GetIssue(context.Background(), "o", "r", "1")
+		if provider.StatusCode(err) != http.StatusBadGateway {
+			t.Fatalf("err = %v, want 502", err)
+		}
+		if got := srv.Hits("/api/v5/repos/o/r/issues/1"); got != 3 {
+			t.Errorf("requests = %d, want 1 + 2 retries", got)
+		}
+	})
+
+	t.Run("404 is not retried", func(t *testing.T) {
+		c, srv := testClient(t)
+		srv.AddRepo(gitcodetest.Repo{Owner: "o", Name: "r"})
+		if _, err := c.GetIssue(context.Background(), "o", "r", "1"); provider.StatusCode(err) != http.StatusNotFound {
+			t.Fatalf("err = %v, want 404", err)
+		}
+		if got := srv.Hits("/api/v5/repos/o/r/issues/1"); got != 1 {
+			t.Errorf("requests = %d, want no retry", got)
+		}
+	})
+
+	t.Run("exhausted quota waits for reset", func(t *testing.T) {
+		c, srv := testClient(t)
+		srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a"})
+		srv.SetRateLimit(100, 0, time.Now().Add(time.Second))
+		start := time.Now()
+		if _, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if time.Since(start) < 500*time.Millisecond {
+			t.Errorf("returned after %v, want to wait for the reset", time.Since(start))
+		}
+		if q := c.Quota(); !q.Known || q.Limit != 100 || q.Remaining != 99 {
+			t.Errorf("quota = %+v, want the refilled window", q)
+		}
+	})
+}
+
+func TestRequiresToken(t *testing.T) {
+	c, srv := testClient(t)
+	srv.RequireToken("other")
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a"})
+	if _, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{}); provider.StatusCode(err) != http.StatusUnauthorized {
+		t.Fatalf("err = %v, want 401", err)
+	}
+}
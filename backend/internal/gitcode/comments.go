@@ -0,0 +1,78 @@
+package gitcode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+
+	"tracker/internal/provider"
+)
+
+type Comment struct {
+	ID        flexString `json:"id"`
+	Body      string     `json:"body"`
+	User      *User      `json:"user"`
+	Author    *User      `json:"author"`
+	CreatedAt string     `json:"created_at"`
+	UpdatedAt string     `json:"updated_at"`
+}
+
+// ListComments returns every comment on an issue or PR; kind is "issue" or "pr".
+// Past DefaultMaxPages it returns what it has with provider.ErrTruncated.
+func (c *Client) ListComments(ctx context.Context, owner, repo, kind, number string) ([]provider.Comment, error) {
+	logger := slog.Default().With("component", "gitcode", "op", "list-comments", "repo", owner+"/"+repo, "kind", kind, "key", number)
+	start := time.Now()
+
+	segment := "issues"
+	if kind == "pr" {
+		segment = "pulls"
+	}
+	u, err := url.Parse(fmt.Sprintf("%s/api/v5/repos/%s/%s/%s/%s/comments",
+		c.baseURL, url.PathEscape(owner), url.PathEscape(repo), segment, url.PathEscape(number)))
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("per_page", strconv.Itoa(perPage))
+	q.Set("page", "1")
+	u.RawQuery = q.Encode()
+
+	out := []provider.Comment{}
+	for page := 1; page <= DefaultMaxPages; page++ {
+		res, err := c.get(ctx, u.String())
+		if err != nil {
+			logger.Error("request failed", "page", page, "err", err)
+			return nil, err
+		}
+		var raw []Comment
+		if err := json.Unmarshal(res.Body, &raw); err != nil {
+			logger.Error("decode comments failed", "page", page, "err", err)
+			return nil, fmt.Errorf("decode comments: %w", err)
+		}
+		for _, cm := range raw {
+			out = append(out, provider.Comment{
+				ID:        string(cm.ID),
+				Author:    firstNonEmpty(cm.User.Handle(), cm.Author.Handle()),
+				Body:      cm.Body,
+				CreatedAt: cm.CreatedAt,
+				UpdatedAt: cm.UpdatedAt,
+			})
+		}
+
+		next, more := nextPage(res.Header, u, page, len(raw))
+		if !more {
+			break
+		}
+		if page == DefaultMaxPages {
+			logger.Warn("list comments truncated at page limit", "pages", page, "count", len(out))
+			return out, fmt.Errorf("comments on %s %s: %w", kind, number, provider.ErrTruncated)
+		}
+		u = next
+	}
+	logger.Debug("list comments ok", "count", len(out), "elapsed_ms", time.Since(start).Milliseconds())
+	return out, nil
+}
@@ -0,0 +1,810 @@
+// Package gitcodetest runs an in-process fake of the GitCode v5 REST API for
+// tests. It serves seeded repos, issues, PRs and comments with the quirks the
+// real API has (string issue numbers, list endpoints without PR size fields,
+// several paging styles, ETags on list pages), and can simulate rate limiting and inject errors,
+// so gitcode.Client and the syncer can be tested offline.
+//
+//	srv := gitcodetest.NewServer()
+//	defer srv.Close()
+//	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "bug"})
+//	c := gitcode.NewClient(srv.URL, "token")
+package gitcodetest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Paging is how a list response tells the client about the next page.
+type Paging int
+
+const (
+	// PagingLink sends an RFC 8288 Link header with rel="next" and rel="last".
+	PagingLink Paging = iota
+	// PagingTotalPage sends the total_page header GitCode uses.
+	PagingTotalPage
+	// PagingNone sends no hint; the client has to stop at an empty page.
+	PagingNone
+)
+
+type Repo struct {
+	Owner    string
+	Name     string
+	Archived bool
+}
+
+type Issue struct {
+	Number    int
+	Title     string
+	State     string // open|closed|progressing|rejected; "" means open
+	Body      string
+	Author    string
+	Labels    []string
+	Assignees []string
+	Milestone string
+	// CreatedAt defaults to a fixed time derived from Number; UpdatedAt to CreatedAt.
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ClosedAt  time.Time
+	Comments  []Comment
+}
+
+type Pull struct {
+	Number    int
+	Title     string
+	State     string // open|closed|merged; "" means open
+	Body      string
+	Author    string
+	Labels    []string
+	Assignees []string
+	Reviewers []string
+	Head      string
+	Base      string
+	Draft     bool
+	MergedBy  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ClosedAt  time.Time
+	MergedAt  time.Time
+	// The size fields and Mergeable are only served by the single-PR endpoint,
+	// as on GitCode.
+	Additions    int
+	Deletions    int
+	ChangedFiles int
+	Mergeable    bool
+	Comments     []Comment
+}
+
+type Comment struct {
+	ID        int
+	Author    string
+	Body      string
+	CreatedAt time.Time
+}
+
+// Fault makes matching requests fail. Method "" matches any method; Path is
+// the URL path, e.g. "/api/v5/repos/o/r/issues", and may use path.Match
+// patterns. Times is how many requests fail before the fault is used up; 0
+// means until ClearFaults.
+type Fault struct {
+	Method string
+	Path   string
+	Status int
+	Body   string
+	Header http.Header
+	Times  int
+}
+
+// Request is one request the server received.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   string
+}
+
+type repoData struct {
+	Repo
+	issues map[int]*Issue
+	pulls  map[int]*Pull
+}
+
+type quota struct {
+	limit, remaining int
+	reset            time.Time
+}
+
+type Server struct {
+	// URL is the base URL to hand to gitcode.NewClient.
+	URL string
+
+	srv *httptest.Server
+
+	mu         sync.Mutex
+	tokens     []string
+	paging     Paging
+	perPage    int
+	ignoreSort bool
+	repos      map[string]*repoData
+	faults     []*Fault
+	quota      *quota
+	tokenQuota map[string]*quota
+	requests   []Request
+	nextID     int
+}
+
+// base is where default timestamps start; GitCode reports times in +08:00.
+var (
+	base = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cst  = time.FixedZone("CST", 8*3600)
+)
+
+// NewServer starts a server with no repos, Link paging and no auth.
+func NewServer() *Server {
+	s := &Server{repos: map[string]*repoData{}, tokenQuota: map[string]*quota{}, nextID: 1000}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	s.URL = s.srv.URL
+	return s
+}
+
+func (s *Server) Close() { s.srv.Close() }
+
+// RequireToken makes every request without one of these tokens fail with
+// 401; calling it again replaces the accepted set, e.g. to revoke a token.
+func (s *Server) RequireToken(tokens ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = tokens
+}
+
+// SetPaging picks the paging style; perPage > 0 caps the page size whatever
+// per_page the client asks for, which makes small seeds span pages.
+func (s *Server) SetPaging(p Paging, perPage int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paging, s.perPage = p, perPage
+}
+
+// IgnoreSort makes list endpoints ignore sort and direction and always
+// return the highest numbers first, as some GitCode deployments do.
+func (s *Server) IgnoreSort(ignore bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ignoreSort = ignore
+}
+
+// SetRateLimit turns on the X-RateLimit-* headers. Every request uses one
+// call; once none remain requests get 429 with Retry-After until reset, when
+// the quota refills to limit.
+func (s *Server) SetRateLimit(limit, remaining int, reset time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quota = &quota{limit: limit, remaining: remaining, reset: reset}
+}
+
+// SetTokenRateLimit gives one token its own quota, which works like
+// SetRateLimit's; tokens without one share the SetRateLimit quota.
+func (s *Server) SetTokenRateLimit(token string, limit, remaining int, reset time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenQuota[token] = &quota{limit: limit, remaining: remaining, reset: reset}
+}
+
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Requests returns every request received so far, oldest first.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// Hits counts the requests to a path (path.Match patterns allowed).
+func (s *Server) Hits(pattern string) int {
+	n := 0
+	for _, r := range s.Requests() {
+		if ok, _ := path.Match(pattern, r.Path); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) AddRepo(r Repo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repo(r.Owner + "/" + r.Name).Repo = r
+}
+
+// AddIssue adds or replaces an issue, creating the repo if needed.
+func (s *Server) AddIssue(repoFullName string, is Issue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if is.CreatedAt.IsZero() {
+		is.CreatedAt = base.Add(time.Duration(is.Number) * time.Hour)
+	}
+	if is.UpdatedAt.IsZero() {
+		is.UpdatedAt = is.CreatedAt
+	}
+	if is.State == "" {
+		is.State = "open"
+	}
+	s.repo(repoFullName).issues[is.Number] = &is
+}
+
+// AddPull adds or replaces a PR, creating the repo if needed.
+func (s *Server) AddPull(repoFullName string, pr Pull) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pr.CreatedAt.IsZero() {
+		pr.CreatedAt = base.Add(time.Duration(pr.Number) * time.Hour)
+	}
+	if pr.UpdatedAt.IsZero() {
+		pr.UpdatedAt = pr.CreatedAt
+	}
+	if pr.State == "" {
+		pr.State = "open"
+	}
+	s.repo(repoFullName).pulls[pr.Number] = &pr
+}
+
+func (s *Server) RemoveIssue(repoFullName string, number int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.repo(repoFullName).issues, number)
+}
+
+func (s *Server) RemovePull(repoFullName string, number int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.repo(repoFullName).pulls, number)
+}
+
+// Issue returns a copy of an issue as the server currently has it, e.g. to
+// check the effect of a write.
+func (s *Server) Issue(repoFullName string, number int) (Issue, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if is, ok := s.repo(repoFullName).issues[number]; ok {
+		c := *is
+		c.Labels, c.Assignees, c.Comments = slices.Clone(is.Labels), slices.Clone(is.Assignees), slices.Clone(is.Comments)
+		return c, true
+	}
+	return Issue{}, false
+}
+
+func (s *Server) Pull(repoFullName string, number int) (Pull, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pr, ok := s.repo(repoFullName).pulls[number]; ok {
+		c := *pr
+		c.Labels, c.Assignees, c.Reviewers, c.Comments = slices.Clone(pr.Labels), slices.Clone(pr.Assignees), slices.Clone(pr.Reviewers), slices.Clone(pr.Comments)
+		return c, true
+	}
+	return Pull{}, false
+}
+
+// repo returns the repo, creating it; s.mu must be held.
+func (s *Server) repo(fullName string) *repoData {
+	if r, ok := s.repos[fullName]; ok {
+		return r
+	}
+	owner, name, _ := strings.Cut(fullName, "/")
+	r := &repoData{Repo: Repo{Owner: owner, Name: name}, issues: map[int]*Issue{}, pulls: map[int]*Pull{}}
+	s.repos[fullName] = r
+	return r
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header.Clone(), Body: string(body)})
+
+	tok := requestToken(r)
+	if len(s.tokens) > 0 && !slices.Contains(s.tokens, tok) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "401 Unauthorized"})
+		return
+	}
+	q := s.tokenQuota[tok]
+	if q == nil {
+		q = s.quota
+	}
+	if q != nil {
+		now := time.Now()
+		if !q.reset.After(now) {
+			q.remaining, q.reset = q.limit, now.Add(time.Minute)
+		}
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(q.limit))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(q.reset.Unix(), 10))
+		if q.remaining <= 0 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(q.reset).Seconds()+0.999)))
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{"message": "rate limit exceeded"})
+			return
+		}
+		q.remaining--
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(q.remaining))
+	}
+	if f := s.takeFault(r); f != nil {
+		for k, vs := range f.Header {
+			w.Header()[k] = vs
+		}
+		w.WriteHeader(f.Status)
+		_, _ = io.WriteString(w, f.Body)
+		return
+	}
+
+	seg := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/api/v5"), "/"), "/")
+	for i := range seg {
+		seg[i], _ = url.PathUnescape(seg[i])
+	}
+	switch {
+	case len(seg) == 3 && (seg[0] == "orgs" || seg[0] == "users") && seg[2] == "repos" && r.Method == http.MethodGet:
+		s.listRepos(w, r, seg[1])
+	case len(seg) == 4 && seg[0] == "repos" && seg[2] == "issues" && r.Method == http.MethodPatch:
+		// GitCode 修改 issue 的接口不带仓库名，仓库放在请求体里。
+		s.patchIssue(w, seg[1], seg[3], body)
+	case len(seg) >= 4 && seg[0] == "repos":
+		rd, ok := s.repos[seg[1]+"/"+seg[2]]
+		if !ok {
+			notFound(w)
+			return
+		}
+		s.serveRepo(w, r, rd, seg[3:], body)
+	default:
+		notFound(w)
+	}
+}
+
+func (s *Server) serveRepo(w http.ResponseWriter, r *http.Request, rd *repoData, seg []string, body []byte) {
+	kind := seg[0]
+	if kind != "issues" && kind != "pulls" {
+		notFound(w)
+		return
+	}
+	if len(seg) == 1 {
+		if r.Method != http.MethodGet {
+			notFound(w)
+			return
+		}
+		if kind == "issues" {
+			s.listIssues(w, r, rd)
+		} else {
+			s.listPulls(w, r, rd)
+		}
+		return
+	}
+
+	n, err := strconv.Atoi(seg[1])
+	is, pr := rd.issues[n], rd.pulls[n]
+	if err != nil || (kind == "issues" && is == nil) || (kind == "pulls" && pr == nil) {
+		notFound(w)
+		return
+	}
+	var comments *[]Comment
+	var labels *[]string
+	var updated *time.Time
+	if is != nil && kind == "issues" {
+		comments, labels, updated = &is.Comments, &is.Labels, &is.UpdatedAt
+	} else {
+		comments, labels, updated = &pr.Comments, &pr.Labels, &pr.UpdatedAt
+	}
+
+	switch {
+	case len(seg) == 2 && r.Method == http.MethodGet:
+		if kind == "issues" {
+			writeJSON(w, http.StatusOK, issueJSON(rd, is))
+		} else {
+			writeJSON(w, http.StatusOK, pullJSON(rd, pr, true))
+		}
+	case len(seg) == 3 && seg[2] == "comments" && r.Method == http.MethodGet:
+		out := make([]any, 0, len(*comments))
+		for _, c := range *comments {
+			out = append(out, commentJSON(c))
+		}
+		s.writePage(w, r, out)
+	case len(seg) == 3 && seg[2] == "comments" && r.Method == http.MethodPost:
+		var in struct {
+			Body string `json:"body"`
+		}
+		if json.Unmarshal(body, &in) != nil || in.Body == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "body is missing"})
+			return
+		}
+		s.nextID++
+		c := Comment{ID: s.nextID, Author: "tracker-bot", Body: in.Body, CreatedAt: time.Now()}
+		*comments = append(*comments, c)
+		*updated = c.CreatedAt
+		writeJSON(w, http.StatusCreated, commentJSON(c))
+	case len(seg) == 3 && seg[2] == "labels" && r.Method == http.MethodPost:
+		var names []string
+		if json.Unmarshal(body, &names) != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "invalid labels"})
+			return
+		}
+		for _, name := range names {
+			if !slices.Contains(*labels, name) {
+				*labels = append(*labels, name)
+			}
+		}
+		*updated = time.Now()
+		writeJSON(w, http.StatusCreated, labelsJSON(*labels))
+	case len(seg) == 4 && seg[2] == "labels" && r.Method == http.MethodDelete:
+		i := slices.Index(*labels, seg[3])
+		if i < 0 {
+			notFound(w)
+			return
+		}
+		*labels = slices.Delete(*labels, i, i+1)
+		*updated = time.Now()
+		w.WriteHeader(http.StatusNoContent)
+	case len(seg) == 3 && seg[2] == "assignees" && kind == "pulls" && (r.Method == http.MethodPost || r.Method == http.MethodDelete):
+		var csv string
+		if r.Method == http.MethodPost {
+			var in struct {
+				Assignees string `json:"assignees"`
+			}
+			_ = json.Unmarshal(body, &in)
+			csv = in.Assignees
+			for _, a := range splitCSV(csv) {
+				if !slices.Contains(pr.Assignees, a) {
+					pr.Assignees = append(pr.Assignees, a)
+				}
+			}
+		} else {
+			drop := splitCSV(r.URL.Query().Get("assignees"))
+			pr.Assignees = slices.DeleteFunc(pr.Assignees, func(a string) bool { return slices.Contains(drop, a) })
+		}
+		pr.UpdatedAt = time.Now()
+		writeJSON(w, http.StatusOK, pullJSON(rd, pr, true))
+	default:
+		notFound(w)
+	}
+}
+
+func (s *Server) patchIssue(w http.ResponseWriter, owner, number string, body []byte) {
+	var in struct {
+		Repo     string  `json:"repo"`
+		Assignee *string `json:"assignee"`
+		State    string  `json:"state"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "invalid body"})
+		return
+	}
+	rd, ok := s.repos[owner+"/"+in.Repo]
+	n, _ := strconv.Atoi(number)
+	if !ok || rd.issues[n] == nil {
+		notFound(w)
+		return
+	}
+	is := rd.issues[n]
+	if in.Assignee != nil {
+		is.Assignees = splitCSV(*in.Assignee)
+	}
+	if in.State != "" {
+		is.State = in.State
+	}
+	is.UpdatedAt = time.Now()
+	writeJSON(w, http.StatusOK, issueJSON(rd, is))
+}
+
+func (s *Server) listRepos(w http.ResponseWriter, r *http.Request, owner string) {
+	var names []string
+	for name, rd := range s.repos {
+		if rd.Owner == owner {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		notFound(w)
+		return
+	}
+	sort.Strings(names)
+	out := make([]any, 0, len(names))
+	for _, name := range names {
+		rd := s.repos[name]
+		out = append(out, map[string]any{
+			"name": rd.Name, "path": rd.Name, "full_name": name,
+			"namespace": map[string]string{"path": rd.Owner}, "archived": rd.Archived,
+		})
+	}
+	s.writePage(w, r, out)
+}
+
+func (s *Server) listIssues(w http.ResponseWriter, r *http.Request, rd *repoData) {
+	issues := make([]*Issue, 0, len(rd.issues))
+	for _, is := range rd.issues {
+		issues = append(issues, is)
+	}
+	since, hasSince := parseSince(r.URL.Query().Get("since"))
+	issues = slices.DeleteFunc(issues, func(is *Issue) bool {
+		return (hasSince && is.UpdatedAt.Before(since)) || !stateMatches(r, is.State)
+	})
+	byUpdated, asc := s.sortOrder(r)
+	sort.Slice(issues, func(i, j int) bool {
+		if byUpdated && !issues[i].UpdatedAt.Equal(issues[j].UpdatedAt) {
+			return issues[i].UpdatedAt.After(issues[j].UpdatedAt) != asc
+		}
+		return issues[i].Number > issues[j].Number != asc
+	})
+	out := make([]any, 0, len(issues))
+	for _, is := range issues {
+		out = append(out, issueJSON(rd, is))
+	}
+	s.writePage(w, r, out)
+}
+
+// listPulls ignores since, like some GitCode deployments do, so clients
+// must filter locally.
+func (s *Server) listPulls(w http.ResponseWriter, r *http.Request, rd *repoData) {
+	pulls := make([]*Pull, 0, len(rd.pulls))
+	for _, pr := range rd.pulls {
+		if stateMatches(r, pr.State) {
+			pulls = append(pulls, pr)
+		}
+	}
+	byUpdated, asc := s.sortOrder(r)
+	sort.Slice(pulls, func(i, j int) bool {
+		if byUpdated && !pulls[i].UpdatedAt.Equal(pulls[j].UpdatedAt) {
+			return pulls[i].UpdatedAt.After(pulls[j].UpdatedAt) != asc
+		}
+		return pulls[i].Number > pulls[j].Number != asc
+	})
+	out := make([]any, 0, len(pulls))
+	for _, pr := range pulls {
+		out = append(out, pullJSON(rd, pr, false))
+	}
+	s.writePage(w, r, out)
+}
+
+// sortOrder reads sort/direction: newest first unless direction=asc; s.mu
+// must be held.
+func (s *Server) sortOrder(r *http.Request) (byUpdated, asc bool) {
+	if s.ignoreSort {
+		return false, false
+	}
+	q := r.URL.Query()
+	return q.Get("sort") == "updated", q.Get("direction") == "asc"
+}
+
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, all []any) {
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	page = max(page, 1)
+	per, _ := strconv.Atoi(q.Get("per_page"))
+	if per <= 0 {
+		per = 20
+	}
+	if s.perPage > 0 {
+		per = min(per, s.perPage)
+	}
+	pages := max((len(all)+per-1)/per, 1)
+	lo, hi := min((page-1)*per, len(all)), min(page*per, len(all))
+
+	pageURL := func(p int) string {
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(p))
+		return "http://" + r.Host + r.URL.Path + "?" + q.Encode()
+	}
+	switch s.paging {
+	case PagingLink:
+		links := []string{fmt.Sprintf(`<%s>; rel="last"`, pageURL(pages))}
+		if page < pages {
+			links = append([]string{fmt.Sprintf(`<%s>; rel="next"`, pageURL(page+1))}, links...)
+		}
+		w.Header().Set("Link", strings.Join(links, ", "))
+	case PagingTotalPage:
+		w.Header().Set("total_page", strconv.Itoa(pages))
+	}
+
+	// 和真实接口一样给列表页带 ETag，If-None-Match 命中时回 304。ETag 只
+	// 覆盖响应体，翻页头变了（比如后面多了一页）也照样 304。
+	body, _ := json.Marshal(all[lo:hi])
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// takeFault returns the first fault matching r and uses it up; s.mu must be held.
+func (s *Server) takeFault(r *http.Request) *Fault {
+	for i, f := range s.faults {
+		if f.Method != "" && f.Method != r.Method {
+			continue
+		}
+		if ok, _ := path.Match(f.Path, r.URL.Path); !ok {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = slices.Delete(s.faults, i, i+1)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func issueJSON(rd *repoData, is *Issue) map[string]any {
+	m := map[string]any{
+		"id":         100000 + is.Number,
+		"number":     strconv.Itoa(is.Number), // GitCode 的 issue 编号是字符串
+		"title":      is.Title,
+		"state":      is.State,
+		"html_url":   fmt.Sprintf("https://gitcode.com/%s/%s/issues/%d", rd.Owner, rd.Name, is.Number),
+		"body":       is.Body,
+		"user":       userJSON(is.Author),
+		"labels":     labelsJSON(is.Labels),
+		"assignees":  usersJSON(is.Assignees),
+		"comments":   len(is.Comments),
+		"created_at": timeJSON(is.CreatedAt),
+		"updated_at": timeJSON(is.UpdatedAt),
+		"closed_at":  timeJSON(is.ClosedAt),
+		"milestone":  nil,
+	}
+	if len(is.Assignees) > 0 {
+		m["assignee"] = userJSON(is.Assignees[0])
+	}
+	if is.Milestone != "" {
+		m["milestone"] = map[string]string{"title": is.Milestone}
+	}
+	return m
+}
+
+// pullJSON renders a PR; detail adds the fields only the single-PR endpoint has.
+func pullJSON(rd *repoData, pr *Pull, detail bool) map[string]any {
+	m := map[string]any{
+		"id":                  200000 + pr.Number,
+		"number":              pr.Number,
+		"title":               pr.Title,
+		"state":               pr.State,
+		"html_url":            fmt.Sprintf("https://gitcode.com/%s/%s/pulls/%d", rd.Owner, rd.Name, pr.Number),
+		"body":                pr.Body,
+		"user":                userJSON(pr.Author),
+		"labels":              labelsJSON(pr.Labels),
+		"assignees":           usersJSON(pr.Assignees),
+		"requested_reviewers": usersJSON(pr.Reviewers),
+		"head":                map[string]string{"ref": pr.Head},
+		"base":                map[string]string{"ref": pr.Base},
+		"draft":               pr.Draft,
+		"created_at":          timeJSON(pr.CreatedAt),
+		"updated_at":          timeJSON(pr.UpdatedAt),
+		"closed_at":           timeJSON(pr.ClosedAt),
+		"merged_at":           timeJSON(pr.MergedAt),
+		"merged_by":           nil,
+	}
+	if pr.MergedBy != "" {
+		m["merged_by"] = userJSON(pr.MergedBy)
+	}
+	if detail {
+		m["additions"] = pr.Additions
+		m["deletions"] = pr.Deletions
+		m["changed_files"] = pr.ChangedFiles
+		m["mergeable"] = pr.Mergeable
+		m["comments"] = len(pr.Comments)
+	}
+	return m
+}
+
+func commentJSON(c Comment) map[string]any {
+	return map[string]any{
+		"id":         c.ID,
+		"body":       c.Body,
+		"user":       userJSON(c.Author),
+		"created_at": timeJSON(c.CreatedAt),
+		"updated_at": timeJSON(c.CreatedAt),
+	}
+}
+
+func userJSON(login string) any {
+	if login == "" {
+		return nil
+	}
+	return map[string]string{"login": login}
+}
+
+func usersJSON(logins []string) []any {
+	out := make([]any, 0, len(logins))
+	for _, l := range logins {
+		out = append(out, userJSON(l))
+	}
+	return out
+}
+
+func labelsJSON(names []string) []any {
+	out := make([]any, 0, len(names))
+	for _, n := range names {
+		out = append(out, map[string]string{"name": n, "color": "#cccccc"})
+	}
+	return out
+}
+
+func timeJSON(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.In(cst).Format(time.RFC3339)
+}
+
+func parseSince(v string) (time.Time, bool) {
+	if v == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t, err == nil
+}
+
+func stateMatches(r *http.Request, state string) bool {
+	switch want := r.URL.Query().Get("state"); want {
+	case "", "all":
+		return true
+	case "open":
+		return state == "open" || state == "progressing"
+	default:
+		return state == want
+	}
+}
+
+// requestToken returns the token a request authenticates with, if any.
+func requestToken(r *http.Request) string {
+	if t, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && t != "" {
+		return t
+	}
+	if t := r.Header.Get("PRIVATE-TOKEN"); t != "" {
+		return t
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+func splitCSV(s string) []string {
+	out := []string{}
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func notFound(w http.ResponseWriter) {
+	writeJSON(w, http.StatusNotFound, map[string]string{"message": "404 Not Found"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
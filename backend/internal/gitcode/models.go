@@ -0,0 +1,314 @@
+package gitcode
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"tracker/internal/provider"
+)
+
+// GitCode 的部分字段（如 number、comments）有时是数字、有时是字符串，这里统一兼容。
+
+type flexString string
+
+func (f *flexString) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		*f = ""
+		return nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*f = flexString(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	*f = flexString(n.String())
+	return nil
+}
+
+type flexInt int
+
+func (f *flexInt) UnmarshalJSON(b []byte) error {
+	var s flexString
+	if err := s.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	if s == "" {
+		*f = 0
+		return nil
+	}
+	n, err := strconv.ParseFloat(string(s), 64)
+	if err != nil {
+		return err
+	}
+	*f = flexInt(n)
+	return nil
+}
+
+type User struct {
+	Login    string `json:"login"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+// UnmarshalJSON also accepts a bare login string, which some endpoints send for author.
+func (u *User) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &u.Login)
+	}
+	type plain User
+	return json.Unmarshal(b, (*plain)(u))
+}
+
+// Handle returns the best identifier for u: login, then username, then display name.
+func (u *User) Handle() string {
+	if u == nil {
+		return ""
+	}
+	for _, s := range []string{u.Login, u.Username, u.Name} {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+type Label struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+// UnmarshalJSON also accepts a bare label name.
+func (l *Label) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &l.Name)
+	}
+	type plain Label
+	return json.Unmarshal(b, (*plain)(l))
+}
+
+type Milestone struct {
+	Number flexString `json:"number"`
+	Title  string     `json:"title"`
+	State  string     `json:"state"`
+}
+
+type Issue struct {
+	Number     flexString `json:"number"`
+	IID        flexString `json:"iid"`
+	ID         flexString `json:"id"`
+	Title      string     `json:"title"`
+	State      string     `json:"state"`
+	HTMLURL    string     `json:"html_url"`
+	WebURL     string     `json:"web_url"`
+	URL        string     `json:"url"`
+	Body       string     `json:"body"`
+	User       *User      `json:"user"`
+	Author     *User      `json:"author"`
+	Labels     []Label    `json:"labels"`
+	Assignee   *User      `json:"assignee"`
+	Assignees  []User     `json:"assignees"`
+	Milestone  *Milestone `json:"milestone"`
+	CreatedAt  string     `json:"created_at"`
+	UpdatedAt  string     `json:"updated_at"`
+	ClosedAt   string     `json:"closed_at"`
+	FinishedAt string     `json:"finished_at"`
+	Comments   flexInt    `json:"comments"`
+	IssueType  string     `json:"issue_type"`
+}
+
+type PullRequest struct {
+	Number    flexString `json:"number"`
+	IID       flexString `json:"iid"`
+	ID        flexString `json:"id"`
+	Title     string     `json:"title"`
+	State     string     `json:"state"`
+	HTMLURL   string     `json:"html_url"`
+	WebURL    string     `json:"web_url"`
+	URL       string     `json:"url"`
+	Body      string     `json:"body"`
+	User      *User      `json:"user"`
+	Author    *User      `json:"author"`
+	Labels    []Label    `json:"labels"`
+	Assignee  *User      `json:"assignee"`
+	Assignees []User     `json:"assignees"`
+	// GitCode 的 PR 审查人在不同版本里分别叫 reviewers / requested_reviewers。
+	Reviewers          []User     `json:"reviewers"`
+	RequestedReviewers []User     `json:"requested_reviewers"`
+	Milestone          *Milestone `json:"milestone"`
+	CreatedAt          string     `json:"created_at"`
+	UpdatedAt          string     `json:"updated_at"`
+	ClosedAt           string     `json:"closed_at"`
+	MergedAt           string     `json:"merged_at"`
+	MergedBy           *User      `json:"merged_by"`
+	Draft              bool       `json:"draft"`
+	Head               *Branch    `json:"head"`
+	Base               *Branch    `json:"base"`
+	// GitLab 风格的实例用 source_branch / target_branch 表示分支。
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	// 列表接口通常不带代码量字段，为 nil 时需要再请求 PR 详情。
+	Additions    *flexInt `json:"additions"`
+	Deletions    *flexInt `json:"deletions"`
+	ChangedFiles *flexInt `json:"changed_files"`
+	Mergeable    *bool    `json:"mergeable"`
+	Comments     flexInt  `json:"comments"`
+}
+
+type Branch struct {
+	Ref   string `json:"ref"`
+	Label string `json:"label"`
+}
+
+// UnmarshalJSON also accepts a bare branch name.
+func (b *Branch) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &b.Ref)
+	}
+	type plain Branch
+	return json.Unmarshal(data, (*plain)(b))
+}
+
+func (b *Branch) ref() string {
+	if b == nil {
+		return ""
+	}
+	return b.Ref
+}
+
+func (it Issue) toRemote() provider.Item {
+	closedAt := it.ClosedAt
+	if closedAt == "" {
+		closedAt = it.FinishedAt
+	}
+	return provider.Item{
+		Key:       firstNonEmpty(string(it.Number), string(it.IID), string(it.ID)),
+		ID:        string(it.ID),
+		Title:     it.Title,
+		State:     it.State,
+		URL:       firstNonEmpty(it.HTMLURL, it.WebURL, it.URL),
+		Author:    firstNonEmpty(it.User.Handle(), it.Author.Handle()),
+		CreatedAt: it.CreatedAt,
+		UpdatedAt: it.UpdatedAt,
+		Body:      it.Body,
+		Labels:    toLabels(it.Labels),
+		Assignees: assigneeHandles(it.Assignee, it.Assignees),
+		Milestone: milestoneTitle(it.Milestone),
+		ClosedAt:  closedAt,
+		Comments:  int(it.Comments),
+		IssueType: it.IssueType,
+	}
+}
+
+func (pr PullRequest) toRemote() provider.Item {
+	return provider.Item{
+		Key:       firstNonEmpty(string(pr.Number), string(pr.IID), string(pr.ID)),
+		ID:        string(pr.ID),
+		Title:     pr.Title,
+		State:     pr.State,
+		URL:       firstNonEmpty(pr.HTMLURL, pr.WebURL, pr.URL),
+		Author:    firstNonEmpty(pr.User.Handle(), pr.Author.Handle()),
+		CreatedAt: pr.CreatedAt,
+		UpdatedAt: pr.UpdatedAt,
+		Body:      pr.Body,
+		Labels:    toLabels(pr.Labels),
+		Assignees: assigneeHandles(pr.Assignee, pr.Assignees),
+		Reviewers: assigneeHandles(nil, append(append([]User{}, pr.Reviewers...), pr.RequestedReviewers...)),
+		Milestone: milestoneTitle(pr.Milestone),
+		ClosedAt:  pr.ClosedAt,
+		MergedAt:  pr.MergedAt,
+		Comments:  int(pr.Comments),
+		PR: &provider.PRMeta{
+			MergedBy:     pr.MergedBy.Handle(),
+			Draft:        pr.Draft,
+			HeadBranch:   firstNonEmpty(pr.Head.ref(), pr.SourceBranch),
+			BaseBranch:   firstNonEmpty(pr.Base.ref(), pr.TargetBranch),
+			Additions:    intOf(pr.Additions),
+			Deletions:    intOf(pr.Deletions),
+			ChangedFiles: intOf(pr.ChangedFiles),
+			HasSize:      pr.Additions != nil || pr.Deletions != nil || pr.ChangedFiles != nil,
+			Mergeable:    pr.Mergeable,
+		},
+	}
+}
+
+func toLabels(in []Label) []provider.Label {
+	out := make([]provider.Label, 0, len(in))
+	for _, l := range in {
+		out = append(out, provider.Label{Name: l.Name, Color: l.Color, Description: l.Description})
+	}
+	return out
+}
+
+func intOf(v *flexInt) int {
+	if v == nil {
+		return 0
+	}
+	return int(*v)
+}
+
+func decodeIssues(body []byte) ([]provider.Item, error) {
+	var raw []Issue
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	items := make([]provider.Item, 0, len(raw))
+	for _, it := range raw {
+		items = append(items, it.toRemote())
+	}
+	return items, nil
+}
+
+func decodePulls(body []byte) ([]provider.Item, error) {
+	var raw []PullRequest
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	items := make([]provider.Item, 0, len(raw))
+	for _, pr := range raw {
+		items = append(items, pr.toRemote())
+	}
+	return items, nil
+}
+
+// assigneeHandles merges a single user field with a user list into unique handles.
+func assigneeHandles(one *User, many []User) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	add := func(h string) {
+		if h != "" && !seen[h] {
+			seen[h] = true
+			out = append(out, h)
+		}
+	}
+	add(one.Handle())
+	for i := range many {
+		add(many[i].Handle())
+	}
+	return out
+}
+
+func milestoneTitle(m *Milestone) string {
+	if m == nil {
+		return ""
+	}
+	return m.Title
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package gitcode
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"tracker/internal/provider"
+)
+
+const (
+	perPage = 100
+	// DefaultMaxPages only guards against a server that never stops paging;
+	// hitting it is reported as truncation, not silently dropped.
+	DefaultMaxPages = 1000
+)
+
+// nextPage decides whether there is another page after cur and where it is.
+// It prefers the Link header, then the total page/count headers, and only
+// falls back to "keep going until an empty page" when GitCode sends neither.
+func nextPage(h http.Header, cur *url.URL, page, count int) (*url.URL, bool) {
+	if links := h.Values("Link"); len(links) > 0 {
+		next, ok := provider.LinkRel(links, "next")
+		if !ok {
+			return nil, false
+		}
+		u, err := cur.Parse(next)
+		if err != nil {
+			return nil, false
+		}
+		return u, true
+	}
+
+	if total, ok := provider.HeaderInt(h, "total_page", "X-Total-Pages"); ok {
+		return withPage(cur, page+1), page < total
+	}
+	if total, ok := provider.HeaderInt(h, "total_count", "X-Total-Count", "X-Total"); ok {
+		return withPage(cur, page+1), page*perPage < total
+	}
+	return withPage(cur, page+1), count > 0
+}
+
+func withPage(u *url.URL, page int) *url.URL {
+	next := *u
+	q := next.Query()
+	q.Set("page", strconv.Itoa(page))
+	next.RawQuery = q.Encode()
+	return &next
+}
+
+// conditionalHeader turns saved validators into If-None-Match /
+// If-Modified-Since request headers.
+func conditionalHeader(v provider.PageValidators) http.Header {
+	h := http.Header{}
+	if v.ETag != "" {
+		h.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		h.Set("If-Modified-Since", v.LastModified)
+	}
+	if len(h) == 0 {
+		return nil
+	}
+	return h
+}
+
+// pageValidators collects what a page response lets the next run revalidate;
+// ok is false when the response had neither ETag nor Last-Modified.
+func pageValidators(h http.Header, cur, next *url.URL, more bool) (provider.PageValidators, bool) {
+	v := provider.PageValidators{URL: cur.String(), ETag: h.Get("ETag"), LastModified: h.Get("Last-Modified")}
+	if v.ETag == "" && v.LastModified == "" {
+		return v, false
+	}
+	if more && next != nil {
+		v.Next = next.String()
+	}
+	return v, true
+}
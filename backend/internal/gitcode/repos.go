@@ -0,0 +1,105 @@
+package gitcode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"tracker/internal/provider"
+)
+
+type Repository struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	FullName  string `json:"full_name"`
+	Namespace *struct {
+		Path string `json:"path"`
+	} `json:"namespace"`
+	Archived bool `json:"archived"`
+}
+
+// ListRepos returns the repos under owner that pass filter. owner may be an
+// organization or a user; the org endpoint is tried first. Past
+// DefaultMaxPages it returns the matches so far with provider.ErrTruncated.
+func (c *Client) ListRepos(ctx context.Context, owner string, filter provider.RepoFilter) ([]provider.Repo, error) {
+	logger := slog.Default().With("component", "gitcode", "op", "list-repos", "owner", owner)
+	start := time.Now()
+
+	all, err := c.listRepos(ctx, fmt.Sprintf("%s/api/v5/orgs/%s/repos", c.baseURL, url.PathEscape(owner)), owner)
+	if provider.StatusCode(err) == http.StatusNotFound {
+		all, err = c.listRepos(ctx, fmt.Sprintf("%s/api/v5/users/%s/repos", c.baseURL, url.PathEscape(owner)), owner)
+	}
+	truncated := errors.Is(err, provider.ErrTruncated)
+	if err != nil && !truncated {
+		logger.Error("list repos failed", "err", err)
+		return nil, err
+	}
+
+	out := []provider.Repo{}
+	for _, r := range all {
+		if filter.Match(r.Owner, r.Name) {
+			out = append(out, r)
+		}
+	}
+	if truncated {
+		logger.Warn("list repos truncated at page limit", "total", len(all), "matched", len(out))
+		return out, err
+	}
+	logger.Info("list repos ok", "total", len(all), "matched", len(out), "elapsed_ms", time.Since(start).Milliseconds())
+	return out, nil
+}
+
+func (c *Client) listRepos(ctx context.Context, rawURL, owner string) ([]provider.Repo, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("type", "all")
+	q.Set("per_page", strconv.Itoa(perPage))
+	q.Set("page", "1")
+	u.RawQuery = q.Encode()
+
+	out := []provider.Repo{}
+	for page := 1; page <= DefaultMaxPages; page++ {
+		res, err := c.get(ctx, u.String())
+		if err != nil {
+			return nil, err
+		}
+		var raw []Repository
+		if err := json.Unmarshal(res.Body, &raw); err != nil {
+			return nil, fmt.Errorf("decode repos: %w", err)
+		}
+		for _, r := range raw {
+			out = append(out, r.toRemote(owner))
+		}
+
+		next, more := nextPage(res.Header, u, page, len(raw))
+		if !more {
+			break
+		}
+		if page == DefaultMaxPages {
+			return out, fmt.Errorf("repos of %s: %w", owner, provider.ErrTruncated)
+		}
+		u = next
+	}
+	return out, nil
+}
+
+func (r Repository) toRemote(owner string) provider.Repo {
+	// full_name 是 "owner/path"；name 可能是展示名（含空格、大小写），path 才是地址里的仓库名。
+	if o, n, ok := strings.Cut(r.FullName, "/"); ok {
+		return provider.Repo{Owner: o, Name: n, Archived: r.Archived}
+	}
+	if r.Namespace != nil && r.Namespace.Path != "" {
+		owner = r.Namespace.Path
+	}
+	return provider.Repo{Owner: owner, Name: firstNonEmpty(r.Path, r.Name), Archived: r.Archived}
+}
@@ -0,0 +1,143 @@
+package gitcode
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"tracker/internal/provider"
+)
+
+// WithRetry replaces the client's retry policy and returns the client.
+func (c *Client) WithRetry(p provider.RetryPolicy) *Client {
+	c.retry = provider.NewRetrier("gitcode", p)
+	return c
+}
+
+// Quota is the rate-limit state of the client's token pool.
+func (c *Client) Quota() provider.Quota { return c.tokens.quota() }
+
+func parseQuota(h http.Header) (provider.Quota, bool) {
+	remaining, ok := provider.HeaderInt(h, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	if !ok {
+		return provider.Quota{}, false
+	}
+	q := provider.Quota{Known: true, Remaining: remaining}
+	q.Limit, _ = provider.HeaderInt(h, "X-RateLimit-Limit", "RateLimit-Limit")
+	if reset, ok := provider.HeaderInt(h, "X-RateLimit-Reset", "RateLimit-Reset"); ok {
+		q.Reset = provider.ResetTime(reset)
+	}
+	return q, true
+}
+
+// get issues a GET and retries 429, 5xx and transient network errors with
+// exponential backoff, honoring Retry-After and the rate-limit reset time.
+func (c *Client) get(ctx context.Context, fullURL string) (*provider.Response, error) {
+	return c.getWith(ctx, fullURL, nil)
+}
+
+// getWith is get with extra request headers; a 304 answer to a conditional
+// request is returned as a response, not an error.
+func (c *Client) getWith(ctx context.Context, fullURL string, hdr http.Header) (*provider.Response, error) {
+	res, err := c.retry.Do(ctx, fullURL, func() (*provider.Response, error) {
+		return c.do(ctx, http.MethodGet, fullURL, nil, hdr)
+	}, c.classify)
+	if err != nil {
+		return nil, err
+	}
+	if (res.Status < 200 || res.Status >= 300) && res.Status != http.StatusNotModified {
+		return nil, httpError(fullURL, res)
+	}
+	return res, nil
+}
+
+// do sends one request; body, when set, is sent as JSON, and hdr is added to
+// the request headers. A 401 or 429 is sent again right away with the next
+// usable token of the pool, if there is one.
+func (c *Client) do(ctx context.Context, method, fullURL string, body []byte, hdr http.Header) (*provider.Response, error) {
+	for tries := 1; ; tries++ {
+		tok := c.tokens.acquire()
+		res, err := c.send1(ctx, method, fullURL, body, hdr, tok)
+		if err != nil {
+			return nil, err
+		}
+		if !c.tokens.observe(tok, res.Status, res.Header) || tries >= c.tokens.Len() {
+			return res, nil
+		}
+		slog.Default().With("component", "gitcode", "op", "do").Warn("switching token", "url", fullURL, "status", res.Status, "token", tok.id)
+	}
+}
+
+func (c *Client) send1(ctx context.Context, method, fullURL string, body []byte, hdr http.Header, tok *poolToken) (*provider.Response, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fullURL, rd)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// GitCode 文档支持 Authorization: Bearer 和 PRIVATE-TOKEN。
+	// 这里优先用 Bearer，同时也填 PRIVATE-TOKEN 以兼容不同部署。
+	secret := ""
+	if tok != nil {
+		secret = tok.secret
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+secret)
+	req.Header.Set("PRIVATE-TOKEN", secret)
+	for k, vs := range hdr {
+		req.Header[k] = vs
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(io.LimitReader(res.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	return &provider.Response{Status: res.StatusCode, Header: res.Header, Body: resBody}, nil
+}
+
+func (c *Client) classify(res *provider.Response, err error, attempt int) (bool, time.Duration) {
+	policy := c.retry.Policy
+	if err != nil {
+		if provider.TransientError(err) {
+			return true, policy.Backoff(attempt)
+		}
+		return false, 0
+	}
+	switch {
+	case res.Status == http.StatusTooManyRequests:
+		// 等待时间原样交给 Retrier：超过 MaxDelay 的不重试，而不是提前重试。
+		if d, ok := provider.RetryAfter(res.Header); ok {
+			return true, d
+		}
+		if q := c.Quota(); q.Known && q.Remaining == 0 && !q.Reset.IsZero() {
+			return true, max(time.Until(q.Reset), 0)
+		}
+		return true, policy.Backoff(attempt)
+	case res.Status >= 500:
+		if d, ok := provider.RetryAfter(res.Header); ok {
+			return true, d
+		}
+		return true, policy.Backoff(attempt)
+	}
+	return false, 0
+}
+
+func httpError(fullURL string, res *provider.Response) error {
+	return &provider.HTTPError{Provider: "gitcode", URL: fullURL, Status: res.Status, Body: strings.TrimSpace(string(res.Body))}
+}
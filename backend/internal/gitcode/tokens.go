@@ -0,0 +1,418 @@
+package gitcode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tracker/internal/provider"
+)
+
+// DefaultTokenReload is how often a file-backed pool checks its source for changes.
+const DefaultTokenReload = 30 * time.Second
+
+// unauthorizedCooldown is how long a token rejected with 401 is left alone
+// before one request tries it again; tokens do come back, e.g. after an
+// expired one is renewed under the same value.
+var unauthorizedCooldown = 10 * time.Minute
+
+// TokenPool holds the GitCode tokens a client authenticates with. Requests
+// stick to one token until it is rate limited or rejected with 401, then move
+// on to the next usable one, so several people's quotas add up.
+//
+// A pool loaded from a file or directory rereads it at most every reload
+// interval, keeping the health of tokens that are still there. Tokens are
+// only ever logged or reported by fingerprint.
+type TokenPool struct {
+	source      string // file or directory; "" for a fixed pool
+	reloadEvery time.Duration
+
+	mu        sync.Mutex
+	tokens    []*poolToken
+	cur       int
+	checkedAt time.Time
+}
+
+type poolToken struct {
+	secret string
+	id     string // fingerprint, safe to log
+	origin string // file name or line the token came from
+
+	quota        provider.Quota
+	limitedUntil time.Time
+	unauthorized bool
+	probeAt      time.Time // when an unauthorized token gets tried again
+	lastStatus   int
+}
+
+// TokenHealth describes one token of the pool without its secret.
+type TokenHealth struct {
+	ID         string `json:"id"`
+	Origin     string `json:"origin"`
+	Status     string `json:"status"` // ok|rate_limited|unauthorized
+	Current    bool   `json:"current"`
+	Limit      int    `json:"limit,omitempty"`
+	Remaining  *int   `json:"remaining,omitempty"`
+	Reset      string `json:"reset,omitempty"`
+	LastStatus int    `json:"lastStatus,omitempty"`
+}
+
+// NewTokenPool builds a fixed pool; empty tokens are dropped.
+func NewTokenPool(tokens ...string) *TokenPool {
+	p := &TokenPool{}
+	var parsed []parsedToken
+	for i, t := range tokens {
+		if t = strings.TrimSpace(t); t != "" {
+			parsed = append(parsed, parsedToken{secret: t, origin: fmt.Sprintf("env#%d", i+1)})
+		}
+	}
+	p.replace(parsed)
+	return p
+}
+
+// LoadTokenPool reads tokens from path: either a file with one token per line
+// (blank lines and # comments ignored) or a directory whose files each hold
+// tokens the same way, as mounted secrets are. Dot files are skipped.
+func LoadTokenPool(path string) (*TokenPool, error) {
+	p := &TokenPool{source: path, reloadEvery: DefaultTokenReload}
+	parsed, err := readTokens(path)
+	if err != nil {
+		return nil, err
+	}
+	p.replace(parsed)
+	p.checkedAt = time.Now()
+	slog.Default().With("component", "gitcode", "op", "token-pool").Info("token pool loaded", "source", path, "tokens", len(p.tokens), "ids", p.ids())
+	return p, nil
+}
+
+// WithReloadInterval changes how often a file-backed pool rereads its source
+// and returns the pool.
+func (p *TokenPool) WithReloadInterval(d time.Duration) *TokenPool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reloadEvery = d
+	return p
+}
+
+// Source is the file or directory the pool was loaded from, "" for a fixed pool.
+func (p *TokenPool) Source() string { return p.source }
+
+// Len reports how many tokens the pool holds.
+func (p *TokenPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.tokens)
+}
+
+// Reload rereads the pool's source. On error, or when the source no longer
+// holds any token, the current tokens are kept.
+func (p *TokenPool) Reload() error {
+	if p.source == "" {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reloadLocked()
+}
+
+func (p *TokenPool) reloadLocked() error {
+	logger := slog.Default().With("component", "gitcode", "op", "token-pool", "source", p.source)
+	p.checkedAt = time.Now()
+	parsed, err := readTokens(p.source)
+	if err != nil {
+		logger.Warn("token pool reload failed, keeping current tokens", "err", err)
+		return err
+	}
+	before := p.ids()
+	p.replace(parsed)
+	if after := p.ids(); !slices.Equal(before, after) {
+		logger.Info("token pool reloaded", "tokens", len(p.tokens), "ids", after)
+	}
+	return nil
+}
+
+type parsedToken struct{ secret, origin string }
+
+func readTokens(path string) ([]parsedToken, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []parsedToken
+	if !fi.IsDir() {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		out = parseTokens(b, filepath.Base(path))
+	} else {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			// Kubernetes 的 secret 挂载里有 ..data 之类的隐藏目录和链接，跳过。
+			if strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			full := filepath.Join(path, e.Name())
+			if fi, err := os.Stat(full); err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			b, err := os.ReadFile(full)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, parseTokens(b, e.Name())...)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no tokens in " + path)
+	}
+	return out, nil
+}
+
+// parseTokens reads one token per line. The origin is the file name when the
+// file holds a single token, and name:line when it holds several.
+func parseTokens(b []byte, name string) []parsedToken {
+	var out []parsedToken
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, parsedToken{secret: line, origin: fmt.Sprintf("%s:%d", name, i+1)})
+	}
+	if len(out) == 1 {
+		out[0].origin = name
+	}
+	return out
+}
+
+// replace swaps in a new token list, keeping the state of tokens that stay; p.mu must be held.
+func (p *TokenPool) replace(parsed []parsedToken) {
+	old := map[string]*poolToken{}
+	for _, t := range p.tokens {
+		old[t.secret] = t
+	}
+	var curSecret string
+	if p.cur < len(p.tokens) {
+		curSecret = p.tokens[p.cur].secret
+	}
+	tokens := make([]*poolToken, 0, len(parsed))
+	seen := map[string]bool{}
+	p.cur = 0
+	for _, pt := range parsed {
+		if seen[pt.secret] {
+			continue
+		}
+		seen[pt.secret] = true
+		t, ok := old[pt.secret]
+		if !ok {
+			t = &poolToken{secret: pt.secret, id: fingerprint(pt.secret)}
+		}
+		t.origin = pt.origin
+		if t.secret == curSecret {
+			p.cur = len(tokens)
+		}
+		tokens = append(tokens, t)
+	}
+	p.tokens = tokens
+}
+
+func fingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "tok-" + hex.EncodeToString(sum[:4])
+}
+
+// ids lists the token fingerprints; p.mu must be held.
+func (p *TokenPool) ids() []string {
+	ids := make([]string, 0, len(p.tokens))
+	for _, t := range p.tokens {
+		ids = append(ids, t.id)
+	}
+	return ids
+}
+
+func (t *poolToken) usable(now time.Time) bool {
+	return !t.unauthorized && !now.Before(t.limitedUntil)
+}
+
+// acquire returns the token for the next request: an unauthorized token
+// whose cooldown is over, as a probe; else the current one while it is
+// usable, else the next usable one, else the one whose limit resets first.
+// It returns nil for an empty pool.
+func (p *TokenPool) acquire() *poolToken {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.source != "" && p.reloadEvery > 0 && time.Since(p.checkedAt) >= p.reloadEvery {
+		_ = p.reloadLocked()
+	}
+	if len(p.tokens) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, t := range p.tokens {
+		if t.unauthorized && !now.Before(t.probeAt) && len(p.tokens) > 1 {
+			// 只放一个探测请求出去；再次 401 时 do 会换回其他令牌重发。
+			t.probeAt = now.Add(unauthorizedCooldown)
+			return t
+		}
+	}
+	next := p.cur
+	if !p.tokens[p.cur].usable(now) {
+		next = -1
+		for i := 1; i < len(p.tokens); i++ {
+			if j := (p.cur + i) % len(p.tokens); p.tokens[j].usable(now) {
+				next = j
+				break
+			}
+		}
+		if next < 0 {
+			next = p.soonestLocked()
+		}
+	}
+	if next != p.cur {
+		slog.Default().With("component", "gitcode", "op", "token-pool").Info("token rotated", "from", p.tokens[p.cur].id, "to", p.tokens[next].id)
+		p.cur = next
+	}
+	return p.tokens[p.cur]
+}
+
+// soonestLocked picks the rate-limited token that frees up first, or the
+// current one when every token was rejected; p.mu must be held.
+func (p *TokenPool) soonestLocked() int {
+	best := -1
+	for i, t := range p.tokens {
+		if t.unauthorized {
+			continue
+		}
+		if best < 0 || t.limitedUntil.Before(p.tokens[best].limitedUntil) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return p.cur
+	}
+	return best
+}
+
+// observe records what a response says about the token that sent it and
+// reports whether another usable token is left to retry with.
+func (p *TokenPool) observe(t *poolToken, status int, h http.Header) (rotate bool) {
+	if t == nil {
+		return false
+	}
+	logger := slog.Default().With("component", "gitcode", "op", "token-pool")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	t.lastStatus = status
+	if q, ok := parseQuota(h); ok {
+		t.quota = q
+		if q.Remaining == 0 && q.Reset.After(now) {
+			t.limitedUntil = q.Reset
+		}
+	}
+	switch {
+	case status == http.StatusUnauthorized:
+		if !t.unauthorized {
+			logger.Warn("token unauthorized", "id", t.id, "origin", t.origin, "retry_at", now.Add(unauthorizedCooldown).Format(time.RFC3339))
+		}
+		t.unauthorized, t.probeAt = true, now.Add(unauthorizedCooldown)
+	case status == http.StatusTooManyRequests:
+		until := now.Add(time.Minute)
+		if d, ok := provider.RetryAfter(h); ok {
+			until = now.Add(d)
+		} else if t.quota.Known && t.quota.Reset.After(now) {
+			until = t.quota.Reset
+		}
+		t.limitedUntil = until
+		logger.Warn("token rate limited", "id", t.id, "until", until.Format(time.RFC3339))
+	case status < 400:
+		if t.unauthorized {
+			logger.Info("token recovered", "id", t.id, "origin", t.origin)
+		}
+		t.unauthorized = false
+	}
+	if status != http.StatusUnauthorized && status != http.StatusTooManyRequests {
+		return false
+	}
+	for _, o := range p.tokens {
+		if o != t && o.usable(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// quota sums the quota of the tokens still accepted, so callers pacing on it
+// only pause once the whole pool runs low; Reset is the earliest moment any
+// of them gets calls back. It is unknown while a usable token has not
+// reported a quota yet.
+func (p *TokenPool) quota() provider.Quota {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var q provider.Quota
+	for _, t := range p.tokens {
+		if t.unauthorized {
+			continue
+		}
+		usable := t.usable(now)
+		if !t.quota.Known {
+			if usable {
+				return provider.Quota{}
+			}
+			continue
+		}
+		q.Known = true
+		q.Limit += t.quota.Limit
+		reset := t.quota.Reset
+		if usable {
+			q.Remaining += t.quota.Remaining
+		} else {
+			reset = t.limitedUntil
+		}
+		if q.Reset.IsZero() || (!reset.IsZero() && reset.Before(q.Reset)) {
+			q.Reset = reset
+		}
+	}
+	return q
+}
+
+// Health reports every token's state by fingerprint.
+func (p *TokenPool) Health() []TokenHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	out := make([]TokenHealth, 0, len(p.tokens))
+	for i, t := range p.tokens {
+		h := TokenHealth{ID: t.id, Origin: t.origin, Status: "ok", Current: i == p.cur, LastStatus: t.lastStatus}
+		switch {
+		case t.unauthorized:
+			h.Status = "unauthorized"
+		case now.Before(t.limitedUntil):
+			h.Status = "rate_limited"
+			h.Reset = t.limitedUntil.UTC().Format(time.RFC3339)
+		}
+		if t.quota.Known {
+			remaining := t.quota.Remaining
+			h.Limit, h.Remaining = t.quota.Limit, &remaining
+			if h.Reset == "" && !t.quota.Reset.IsZero() {
+				h.Reset = t.quota.Reset.UTC().Format(time.RFC3339)
+			}
+		}
+		out = append(out, h)
+	}
+	return out
+}
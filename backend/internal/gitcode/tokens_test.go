@@ -0,0 +1,222 @@
+package gitcode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"tracker/internal/gitcode/gitcodetest"
+	"tracker/internal/provider"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func poolIDs(p *TokenPool) []string {
+	var ids []string
+	for _, h := range p.Health() {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func TestLoadTokenPool(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "tokens")
+	writeFile(t, file, "# team tokens\nalpha\n\n  beta  \nalpha\n")
+	p, err := LoadTokenPool(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{fingerprint("alpha"), fingerprint("beta")}; !slices.Equal(poolIDs(p), want) {
+		t.Errorf("file pool = %v, want %v", poolIDs(p), want)
+	}
+	if h := p.Health(); h[0].Origin != "tokens:2" || h[1].Origin != "tokens:4" {
+		t.Errorf("file pool origins = %+v", h)
+	}
+
+	// 目录形式：每个文件一个令牌，隐藏文件（secret 挂载的 ..data 等）跳过。
+	secrets := filepath.Join(dir, "secrets")
+	if err := os.MkdirAll(filepath.Join(secrets, "..data"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(secrets, "ann"), "gamma\n")
+	writeFile(t, filepath.Join(secrets, "bo"), "delta")
+	writeFile(t, filepath.Join(secrets, ".hidden"), "nope")
+	p, err = LoadTokenPool(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := p.Health()
+	if len(h) != 2 || h[0].Origin != "ann" || h[1].Origin != "bo" || !h[0].Current {
+		t.Errorf("dir pool = %+v", h)
+	}
+
+	if _, err := LoadTokenPool(filepath.Join(dir, "missing")); err == nil {
+		t.Error("missing source loaded")
+	}
+	writeFile(t, filepath.Join(dir, "empty"), "# nothing yet\n")
+	if _, err := LoadTokenPool(filepath.Join(dir, "empty")); err == nil {
+		t.Error("empty source loaded")
+	}
+}
+
+func TestTokenPoolReloadsChangedFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tokens")
+	writeFile(t, file, "alpha\nbeta\n")
+	p, err := LoadTokenPool(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.WithReloadInterval(time.Nanosecond)
+	p.observe(p.acquire(), http.StatusUnauthorized, nil)
+
+	writeFile(t, file, "beta\nalpha\ngamma\n")
+	if tok := p.acquire(); tok.secret == "alpha" {
+		t.Errorf("after reload acquired %s", tok.id)
+	}
+	h := p.Health()
+	if len(h) != 3 || h[1].ID != fingerprint("alpha") || h[1].Status != "unauthorized" {
+		t.Errorf("reloaded pool = %+v, want alpha's state kept", h)
+	}
+
+	// 文件被清空或删掉时保留原来的令牌。
+	writeFile(t, file, "")
+	if err := p.Reload(); err == nil || p.Len() != 3 {
+		t.Errorf("reload of empty file: err=%v len=%d", err, p.Len())
+	}
+}
+
+func TestClientRotatesTokens(t *testing.T) {
+	c, srv := testClient(t)
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a"})
+	srv.RequireToken("beta", "gamma")
+	srv.SetTokenRateLimit("beta", 10, 0, time.Now().Add(time.Hour))
+	tokens := NewTokenPool("alpha", "beta", "gamma")
+	c.WithTokens(tokens)
+
+	// alpha 被拒（401），beta 额度用完（429），最后由 gamma 拉到数据。
+	res, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 1 {
+		t.Fatalf("items = %+v", res.Items)
+	}
+	var used []string
+	for _, r := range srv.Requests() {
+		used = append(used, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	}
+	if want := []string{"alpha", "beta", "gamma"}; !slices.Equal(used, want) {
+		t.Errorf("tokens used = %v, want %v", used, want)
+	}
+
+	status := map[string]string{}
+	for _, h := range c.TokenHealth() {
+		status[h.ID] = h.Status
+	}
+	if status[fingerprint("alpha")] != "unauthorized" || status[fingerprint("beta")] != "rate_limited" || status[fingerprint("gamma")] != "ok" {
+		t.Errorf("health = %v", status)
+	}
+	b, _ := json.Marshal(c.TokenHealth())
+	for _, secret := range []string{"alpha", "beta", "gamma"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("health leaks %q: %s", secret, b)
+		}
+	}
+
+	// 之后的请求直接用 gamma，不再重试失效的令牌。
+	before := len(srv.Requests())
+	if _, err := c.GetIssue(context.Background(), "o", "r", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Requests()) - before; n != 1 {
+		t.Errorf("follow-up took %d requests", n)
+	}
+}
+
+func TestClientReprobesUnauthorizedToken(t *testing.T) {
+	cooldown := unauthorizedCooldown
+	unauthorizedCooldown = 20 * time.Millisecond
+	t.Cleanup(func() { unauthorizedCooldown = cooldown })
+
+	c, srv := testClient(t)
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a"})
+	srv.RequireToken("beta")
+	c.WithTokens(NewTokenPool("alpha", "beta"))
+	ctx := context.Background()
+	authOf := func(r gitcodetest.Request) string {
+		return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+
+	if _, err := c.GetIssue(ctx, "o", "r", "1"); err != nil {
+		t.Fatal(err)
+	}
+	// 冷却期内不碰 alpha。
+	before := len(srv.Requests())
+	if _, err := c.GetIssue(ctx, "o", "r", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if reqs := srv.Requests()[before:]; len(reqs) != 1 || authOf(reqs[0]) != "beta" {
+		t.Errorf("within cooldown used %d requests", len(reqs))
+	}
+
+	// 冷却后探测一次：仍被拒就回到 beta 重发。
+	time.Sleep(30 * time.Millisecond)
+	before = len(srv.Requests())
+	if _, err := c.GetIssue(ctx, "o", "r", "1"); err != nil {
+		t.Fatal(err)
+	}
+	var used []string
+	for _, r := range srv.Requests()[before:] {
+		used = append(used, authOf(r))
+	}
+	if want := []string{"alpha", "beta"}; !slices.Equal(used, want) {
+		t.Errorf("probe used %v, want %v", used, want)
+	}
+
+	// alpha 恢复后探测成功，状态回到 ok。
+	srv.RequireToken("alpha", "beta")
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.GetIssue(ctx, "o", "r", "1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range c.TokenHealth() {
+		if h.ID == fingerprint("alpha") && h.Status != "ok" {
+			t.Errorf("alpha after probe = %+v", h)
+		}
+	}
+}
+
+func TestClientQuotaSumsPool(t *testing.T) {
+	c, srv := testClient(t)
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a"})
+	reset := time.Now().Add(time.Hour)
+	srv.SetTokenRateLimit("alpha", 10, 1, reset)
+	srv.SetTokenRateLimit("beta", 10, 5, reset)
+	c.WithTokens(NewTokenPool("alpha", "beta"))
+	ctx := context.Background()
+
+	if q := c.Quota(); q.Known {
+		t.Errorf("quota before any response = %+v", q)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetIssue(ctx, "o", "r", "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// alpha 用掉最后一次后切到 beta；两者的剩余额度加在一起。
+	if q := c.Quota(); !q.Known || q.Limit != 20 || q.Remaining != 3 {
+		t.Errorf("quota = %+v, want 3 of 20 left", q)
+	}
+}
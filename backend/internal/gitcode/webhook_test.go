@@ -0,0 +1,101 @@
+package gitcode
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"object_kind":"issue"}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	mac = hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("1700000000000\ns3cret"))
+	giteeSign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		secret string
+		ok     bool
+	}{
+		{"hmac signature", http.Header{"X-Gitcode-Signature-256": {signature}}, "s3cret", true},
+		{"hub signature", http.Header{"X-Hub-Signature-256": {signature}}, "s3cret", true},
+		{"signature of another secret", http.Header{"X-Gitcode-Signature-256": {signature}}, "other", false},
+		{"signature wins over a right token", http.Header{"X-Gitcode-Signature-256": {"sha256=00"}, "X-Gitcode-Token": {"s3cret"}}, "s3cret", false},
+		{"plain token", http.Header{"X-Gitcode-Token": {"s3cret"}}, "s3cret", true},
+		{"gitlab token", http.Header{"X-Gitlab-Token": {"s3cret"}}, "s3cret", true},
+		{"wrong token", http.Header{"X-Gitcode-Token": {"nope"}}, "s3cret", false},
+		{"gitee timestamp signature", http.Header{"X-Gitee-Token": {giteeSign}, "X-Gitee-Timestamp": {"1700000000000"}}, "s3cret", true},
+		{"gitee signature for another time", http.Header{"X-Gitee-Token": {giteeSign}, "X-Gitee-Timestamp": {"1700000000001"}}, "s3cret", false},
+		{"no credentials", http.Header{}, "s3cret", false},
+		{"no secret configured", http.Header{"X-Gitcode-Token": {""}}, "", false},
+	} {
+		err := VerifyWebhook(tc.header, body, tc.secret)
+		if tc.ok && err != nil {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%s: err = %v, want ErrBadSignature", tc.name, err)
+		}
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body string
+		want WebhookEvent
+	}{
+		{"gitlab issue", `{"object_kind":"issue","project":{"path_with_namespace":"o/r"},"object_attributes":{"iid":7}}`,
+			WebhookEvent{Event: "issue", Kind: "issue", RepoFullName: "o/r", Key: "7"}},
+		{"gitee issue", `{"hook_name":"issue_hooks","repository":{"full_name":"o/r"},"issue":{"number":"I8X2"}}`,
+			WebhookEvent{Event: "issue", Kind: "issue", RepoFullName: "o/r", Key: "I8X2"}},
+		{"gitlab merge request", `{"object_kind":"merge_request","project":{"path_with_namespace":"o/r"},"object_attributes":{"iid":"12"}}`,
+			WebhookEvent{Event: "pr", Kind: "pr", RepoFullName: "o/r", Key: "12"}},
+		{"gitee pull request", `{"hook_name":"pull_request_hooks","repository":{"path_with_namespace":"o/r"},"pull_request":{"number":3}}`,
+			WebhookEvent{Event: "pr", Kind: "pr", RepoFullName: "o/r", Key: "3"}},
+		{"note on an issue", `{"object_kind":"note","project":{"path_with_namespace":"o/r"},"object_attributes":{"noteable_type":"Issue"},"issue":{"iid":4}}`,
+			WebhookEvent{Event: "comment", Kind: "issue", RepoFullName: "o/r", Key: "4"}},
+		{"note on a merge request", `{"object_kind":"note","project":{"path_with_namespace":"o/r"},"object_attributes":{"noteable_type":"MergeRequest"},"merge_request":{"iid":5}}`,
+			WebhookEvent{Event: "comment", Kind: "pr", RepoFullName: "o/r", Key: "5"}},
+		{"gitee note on a pull request", `{"hook_name":"note_hooks","repository":{"full_name":"o/r"},"pull_request":{"number":6}}`,
+			WebhookEvent{Event: "comment", Kind: "pr", RepoFullName: "o/r", Key: "6"}},
+	} {
+		ev, err := ParseWebhook([]byte(tc.body))
+		if err != nil || ev != tc.want {
+			t.Errorf("%s: event = %+v err = %v, want %+v", tc.name, ev, err, tc.want)
+		}
+	}
+
+	for name, body := range map[string]string{
+		"push event":       `{"object_kind":"push","project":{"path_with_namespace":"o/r"}}`,
+		"issue without id": `{"object_kind":"issue","project":{"path_with_namespace":"o/r"},"object_attributes":{}}`,
+		"no repository":    `{"object_kind":"issue","object_attributes":{"iid":1}}`,
+	} {
+		if _, err := ParseWebhook([]byte(body)); !errors.Is(err, ErrUnsupportedEvent) {
+			t.Errorf("%s: err = %v, want ErrUnsupportedEvent", name, err)
+		}
+	}
+	if _, err := ParseWebhook([]byte(`{`)); err == nil || errors.Is(err, ErrUnsupportedEvent) {
+		t.Errorf("invalid json: err = %v", err)
+	}
+}
+
+func TestDeliveryID(t *testing.T) {
+	body := []byte(`{"a":1}`)
+	if id := DeliveryID(http.Header{"X-Gitcode-Delivery": {"d-1"}}, body); id != "d-1" {
+		t.Errorf("id = %q, want the header", id)
+	}
+	// 没有投递 id 时，相同的请求体得到相同的 id。
+	a, b := DeliveryID(http.Header{}, body), DeliveryID(http.Header{}, []byte(`{"a":1}`))
+	if a != b || a == DeliveryID(http.Header{}, []byte(`{"a":2}`)) {
+		t.Errorf("body ids = %q %q", a, b)
+	}
+}
@@ -0,0 +1,124 @@
+package gitcode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"tracker/internal/provider"
+)
+
+// CreateComment posts a comment on an issue or PR; kind is "issue" or "pr".
+func (c *Client) CreateComment(ctx context.Context, owner, repo, kind, number, body string) (provider.Comment, error) {
+	logger := slog.Default().With("component", "gitcode", "op", "create-comment", "repo", owner+"/"+repo, "kind", kind, "key", number)
+	res, err := c.send(ctx, http.MethodPost, c.itemURL(owner, repo, kind, number)+"/comments", map[string]string{"body": body})
+	if err != nil {
+		logger.Error("request failed", "err", err)
+		return provider.Comment{}, err
+	}
+	var cm Comment
+	if err := json.Unmarshal(res.Body, &cm); err != nil {
+		// 评论已经发出去了，解析失败只影响返回值。
+		logger.Warn("decode comment failed", "err", err)
+	}
+	return provider.Comment{
+		ID:        string(cm.ID),
+		Author:    firstNonEmpty(cm.User.Handle(), cm.Author.Handle()),
+		Body:      cm.Body,
+		CreatedAt: cm.CreatedAt,
+		UpdatedAt: cm.UpdatedAt,
+	}, nil
+}
+
+// AddLabels adds labels to an issue or PR, creating unknown ones on the repo.
+func (c *Client) AddLabels(ctx context.Context, owner, repo, kind, number string, names []string) error {
+	_, err := c.send(ctx, http.MethodPost, c.itemURL(owner, repo, kind, number)+"/labels", names)
+	return err
+}
+
+// RemoveLabel takes one label off an issue or PR.
+func (c *Client) RemoveLabel(ctx context.Context, owner, repo, kind, number, name string) error {
+	_, err := c.send(ctx, http.MethodDelete, c.itemURL(owner, repo, kind, number)+"/labels/"+url.PathEscape(name), nil)
+	return err
+}
+
+// SetAssignees replaces the assignees of an issue or PR with logins.
+func (c *Client) SetAssignees(ctx context.Context, owner, repo, kind, number string, logins []string) error {
+	if kind != "pr" {
+		// issue 的更新接口不在仓库路径下，仓库放在请求体里。
+		u := fmt.Sprintf("%s/api/v5/repos/%s/issues/%s", c.baseURL, url.PathEscape(owner), url.PathEscape(number))
+		_, err := c.send(ctx, http.MethodPatch, u, map[string]string{"repo": repo, "assignee": strings.Join(logins, ",")})
+		return err
+	}
+
+	// PR 的指派人只能增删，先取当前值再算差异。
+	cur, err := c.GetPull(ctx, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	var add, remove []string
+	for _, l := range logins {
+		if !slices.Contains(cur.Assignees, l) {
+			add = append(add, l)
+		}
+	}
+	for _, l := range cur.Assignees {
+		if !slices.Contains(logins, l) {
+			remove = append(remove, l)
+		}
+	}
+	base := c.itemURL(owner, repo, kind, number) + "/assignees"
+	if len(add) > 0 {
+		if _, err := c.send(ctx, http.MethodPost, base, map[string]string{"assignees": strings.Join(add, ",")}); err != nil {
+			return err
+		}
+	}
+	if len(remove) > 0 {
+		if _, err := c.send(ctx, http.MethodDelete, base+"?assignees="+url.QueryEscape(strings.Join(remove, ",")), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) itemURL(owner, repo, kind, number string) string {
+	segment := "issues"
+	if kind == "pr" {
+		segment = "pulls"
+	}
+	return fmt.Sprintf("%s/api/v5/repos/%s/%s/%s/%s", c.baseURL, url.PathEscape(owner), url.PathEscape(repo), segment, url.PathEscape(number))
+}
+
+// send issues a write. Unlike get it only retries 429, which GitCode answers
+// before doing anything; a 5xx or dropped connection may already have
+// applied the write, and retrying could post a comment twice.
+func (c *Client) send(ctx context.Context, method, fullURL string, payload any) (*provider.Response, error) {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+	res, err := c.retry.Do(ctx, fullURL, func() (*provider.Response, error) {
+		return c.do(ctx, method, fullURL, body, nil)
+	}, func(res *provider.Response, err error, attempt int) (bool, time.Duration) {
+		if err != nil || res.Status != http.StatusTooManyRequests {
+			return false, 0
+		}
+		return c.classify(res, nil, attempt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Status < 200 || res.Status >= 300 {
+		return nil, httpError(fullURL, res)
+	}
+	return res, nil
+}
@@ -0,0 +1,107 @@
+package gitcode
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"tracker/internal/gitcode/gitcodetest"
+	"tracker/internal/provider"
+)
+
+func TestWrites(t *testing.T) {
+	ctx := context.Background()
+	c, srv := testClient(t)
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a", Labels: []string{"kind/bug", "triage"}, Assignees: []string{"ann"}})
+	srv.AddPull("o/r", gitcodetest.Pull{Number: 2, Title: "b", Assignees: []string{"ann", "bo"}})
+
+	cm, err := c.CreateComment(ctx, "o", "r", "issue", "1", "looking")
+	if err != nil || cm.Body != "looking" || cm.ID == "" {
+		t.Fatalf("comment=%+v err=%v", cm, err)
+	}
+	if err := c.AddLabels(ctx, "o", "r", "issue", "1", []string{"sig/core"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveLabel(ctx, "o", "r", "issue", "1", "kind/bug"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetAssignees(ctx, "o", "r", "issue", "1", []string{"cy"}); err != nil {
+		t.Fatal(err)
+	}
+	is, _ := srv.Issue("o/r", 1)
+	if len(is.Comments) != 1 || !slices.Equal(is.Labels, []string{"triage", "sig/core"}) || !slices.Equal(is.Assignees, []string{"cy"}) {
+		t.Errorf("issue after writes = %+v", is)
+	}
+
+	if err := c.SetAssignees(ctx, "o", "r", "pr", "2", []string{"bo", "cy"}); err != nil {
+		t.Fatal(err)
+	}
+	pr, _ := srv.Pull("o/r", 2)
+	if !slices.Equal(pr.Assignees, []string{"bo", "cy"}) {
+		t.Errorf("pr assignees = %v", pr.Assignees)
+	}
+
+	if err := c.RemoveLabel(ctx, "o", "r", "issue", "1", "missing"); provider.StatusCode(err) != http.StatusNotFound {
+		t.Errorf("removing a missing label: err = %v, want 404", err)
+	}
+}
+
+func TestWritesOnPulls(t *testing.T) {
+	ctx := context.Background()
+	c, srv := testClient(t)
+	srv.AddPull("o/r", gitcodetest.Pull{Number: 2, Title: "b", Labels: []string{"lgtm"}, Assignees: []string{"ann"}})
+
+	if _, err := c.CreateComment(ctx, "o", "r", "pr", "2", "ship it"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddLabels(ctx, "o", "r", "pr", "2", []string{"approved", "lgtm"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveLabel(ctx, "o", "r", "pr", "2", "lgtm"); err != nil {
+		t.Fatal(err)
+	}
+	// 清空指派人：只发删除请求。
+	if err := c.SetAssignees(ctx, "o", "r", "pr", "2", nil); err != nil {
+		t.Fatal(err)
+	}
+	pr, _ := srv.Pull("o/r", 2)
+	if len(pr.Comments) != 1 || !slices.Equal(pr.Labels, []string{"approved"}) || len(pr.Assignees) != 0 {
+		t.Errorf("pr after writes = %+v", pr)
+	}
+	if n := srv.Hits("/api/v5/repos/o/r/pulls/2/assignees"); n != 1 {
+		t.Errorf("assignee requests = %d, want one delete", n)
+	}
+}
+
+func TestWritesRetryOnlyRateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("429 is retried", func(t *testing.T) {
+		c, srv := testClient(t)
+		srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a"})
+		srv.Inject(gitcodetest.Fault{
+			Method: http.MethodPost, Path: "/api/v5/repos/o/r/issues/1/comments", Status: http.StatusTooManyRequests, Times: 1,
+			Header: http.Header{"Retry-After": {"0"}},
+		})
+		if _, err := c.CreateComment(ctx, "o", "r", "issue", "1", "once"); err != nil {
+			t.Fatal(err)
+		}
+		if is, _ := srv.Issue("o/r", 1); len(is.Comments) != 1 {
+			t.Errorf("comments = %+v, want exactly one", is.Comments)
+		}
+	})
+
+	t.Run("5xx is not retried", func(t *testing.T) {
+		c, srv := testClient(t)
+		srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a"})
+		srv.Inject(gitcodetest.Fault{Method: http.MethodPost, Path: "/api/v5/repos/o/r/issues/1/comments", Status: http.StatusBadGateway, Body: "bad gateway"})
+		_, err := c.CreateComment(ctx, "o", "r", "issue", "1", "once")
+		if provider.StatusCode(err) != http.StatusBadGateway {
+			t.Fatalf("err = %v, want 502", err)
+		}
+		if got := srv.Hits("/api/v5/repos/o/r/issues/1/comments"); got != 1 {
+			t.Errorf("requests = %d, want no retry", got)
+		}
+	})
+}
@@ -0,0 +1,335 @@
+// Package github is the GitHub REST provider. It produces the same
+// provider.Item shape as the GitCode client, so the syncer treats both alike.
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"tracker/internal/provider"
+)
+
+const (
+	perPage = 100
+	// DefaultMaxPages only guards against a server that never stops paging.
+	DefaultMaxPages = 1000
+)
+
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+
+	retry *provider.Retrier
+	quota quotaState
+}
+
+var _ provider.Provider = (*Client)(nil)
+
+// NewClient talks to baseURL (https://api.github.com, or a GitHub Enterprise
+// /api/v3 root). An empty token sends unauthenticated requests.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+		retry:   provider.NewRetrier("github", provider.DefaultRetryPolicy()),
+	}
+}
+
+func (c *Client) Name() string { return "github" }
+
+// ListIssues lists issues only; the PRs GitHub mixes into the issues endpoint are skipped.
+func (c *Client) ListIssues(ctx context.Context, owner, repo string, opts provider.ListOptions) (provider.ListResult, error) {
+	logger := slog.Default().With("component", "github", "op", "list-issues", "repo", owner+"/"+repo)
+	logger.Debug("list issues start", "since", opts.Since)
+	return c.listPaged(ctx, fmt.Sprintf("/repos/%s/%s/issues", url.PathEscape(owner), url.PathEscape(repo)), opts, true, decodeIssues)
+}
+
+func (c *Client) ListPulls(ctx context.Context, owner, repo string, opts provider.ListOptions) (provider.ListResult, error) {
+	logger := slog.Default().With("component", "github", "op", "list-pulls", "repo", owner+"/"+repo)
+	logger.Debug("list pulls start", "since", opts.Since)
+	return c.listPaged(ctx, fmt.Sprintf("/repos/%s/%s/pulls", url.PathEscape(owner), url.PathEscape(repo)), opts, false, decodePulls)
+}
+
+// GetIssue fetches a single issue. A PR number is reported as 404, since
+// there is no issue with that number.
+func (c *Client) GetIssue(ctx context.Context, owner, repo, number string) (provider.Item, error) {
+	logger := slog.Default().With("component", "github", "op", "get-issue", "repo", owner+"/"+repo, "key", number)
+	fullURL := fmt.Sprintf("%s/repos/%s/%s/issues/%s", c.baseURL, url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(number))
+	res, err := c.get(ctx, fullURL)
+	if err != nil {
+		logger.Error("request failed", "err", err)
+		return provider.Item{}, err
+	}
+	var it Issue
+	if err := json.Unmarshal(res.Body, &it); err != nil {
+		logger.Error("decode issue failed", "err", err)
+		return provider.Item{}, fmt.Errorf("decode issue: %w", err)
+	}
+	if it.isPull() {
+		return provider.Item{}, &provider.HTTPError{Provider: "github", URL: fullURL, Status: http.StatusNotFound, Body: "is a pull request"}
+	}
+	return it.toItem(), nil
+}
+
+// GetPull fetches a single PR, which carries size, mergeability and comment count the list omits.
+func (c *Client) GetPull(ctx context.Context, owner, repo, number string) (provider.Item, error) {
+	logger := slog.Default().With("component", "github", "op", "get-pull", "repo", owner+"/"+repo, "key", number)
+	fullURL := fmt.Sprintf("%s/repos/%s/%s/pulls/%s", c.baseURL, url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(number))
+	res, err := c.get(ctx, fullURL)
+	if err != nil {
+		logger.Error("request failed", "err", err)
+		return provider.Item{}, err
+	}
+	var pr PullRequest
+	if err := json.Unmarshal(res.Body, &pr); err != nil {
+		logger.Error("decode pull failed", "err", err)
+		return provider.Item{}, fmt.Errorf("decode pull: %w", err)
+	}
+	return pr.toItem(), nil
+}
+
+// ListComments returns the conversation comments of an issue or PR. GitHub
+// keeps both under the issues endpoint; PR review comments are not included.
+// Past DefaultMaxPages it returns what it has with provider.ErrTruncated.
+func (c *Client) ListComments(ctx context.Context, owner, repo, kind, number string) ([]provider.Comment, error) {
+	logger := slog.Default().With("component", "github", "op", "list-comments", "repo", owner+"/"+repo, "kind", kind, "key", number)
+	start := time.Now()
+
+	u, err := url.Parse(fmt.Sprintf("%s/repos/%s/%s/issues/%s/comments",
+		c.baseURL, url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(number)))
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("per_page", strconv.Itoa(perPage))
+	u.RawQuery = q.Encode()
+
+	out := []provider.Comment{}
+	for page := 1; page <= DefaultMaxPages; page++ {
+		res, err := c.get(ctx, u.String())
+		if err != nil {
+			logger.Error("request failed", "page", page, "err", err)
+			return nil, err
+		}
+		var raw []Comment
+		if err := json.Unmarshal(res.Body, &raw); err != nil {
+			logger.Error("decode comments failed", "page", page, "err", err)
+			return nil, fmt.Errorf("decode comments: %w", err)
+		}
+		for _, cm := range raw {
+			out = append(out, provider.Comment{
+				ID:        strconv.FormatInt(cm.ID, 10),
+				Author:    cm.User.login(),
+				Body:      cm.Body,
+				CreatedAt: cm.CreatedAt,
+				UpdatedAt: cm.UpdatedAt,
+			})
+		}
+
+		next, more := nextPage(res.Header, u)
+		if !more {
+			break
+		}
+		if page == DefaultMaxPages {
+			logger.Warn("list comments truncated at page limit", "pages", page, "count", len(out))
+			return out, fmt.Errorf("comments on %s %s: %w", kind, number, provider.ErrTruncated)
+		}
+		u = next
+	}
+	logger.Debug("list comments ok", "count", len(out), "elapsed_ms", time.Since(start).Milliseconds())
+	return out, nil
+}
+
+// ListRepos returns the repos under owner that pass filter. owner may be an
+// organization or a user; the org endpoint is tried first. Past
+// DefaultMaxPages it returns the matches so far with provider.ErrTruncated.
+func (c *Client) ListRepos(ctx context.Context, owner string, filter provider.RepoFilter) ([]provider.Repo, error) {
+	logger := slog.Default().With("component", "github", "op", "list-repos", "owner", owner)
+	start := time.Now()
+
+	all, err := c.listRepos(ctx, fmt.Sprintf("%s/orgs/%s/repos", c.baseURL, url.PathEscape(owner)), owner)
+	if provider.StatusCode(err) == http.StatusNotFound {
+		all, err = c.listRepos(ctx, fmt.Sprintf("%s/users/%s/repos", c.baseURL, url.PathEscape(owner)), owner)
+	}
+	truncated := errors.Is(err, provider.ErrTruncated)
+	if err != nil && !truncated {
+		logger.Error("list repos failed", "err", err)
+		return nil, err
+	}
+
+	out := []provider.Repo{}
+	for _, r := range all {
+		if filter.Match(r.Owner, r.Name) {
+			out = append(out, r)
+		}
+	}
+	if truncated {
+		logger.Warn("list repos truncated at page limit", "total", len(all), "matched", len(out))
+		return out, err
+	}
+	logger.Info("list repos ok", "total", len(all), "matched", len(out), "elapsed_ms", time.Since(start).Milliseconds())
+	return out, nil
+}
+
+func (c *Client) listRepos(ctx context.Context, rawURL, owner string) ([]provider.Repo, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("type", "all")
+	q.Set("per_page", strconv.Itoa(perPage))
+	u.RawQuery = q.Encode()
+
+	out := []provider.Repo{}
+	for page := 1; page <= DefaultMaxPages; page++ {
+		res, err := c.get(ctx, u.String())
+		if err != nil {
+			return nil, err
+		}
+		var raw []Repository
+		if err := json.Unmarshal(res.Body, &raw); err != nil {
+			return nil, fmt.Errorf("decode repos: %w", err)
+		}
+		for _, r := range raw {
+			out = append(out, r.toRepo(owner))
+		}
+
+		next, more := nextPage(res.Header, u)
+		if !more {
+			break
+		}
+		if page == DefaultMaxPages {
+			return out, fmt.Errorf("repos of %s: %w", owner, provider.ErrTruncated)
+		}
+		u = next
+	}
+	return out, nil
+}
+
+// listPaged pages through a list endpoint. With opts.Since, an endpoint that
+// takes since (filtersSince) is paged oldest update first so a truncated
+// listing can be resumed; one that does not is paged newest first and
+// stops at the first page reaching past since. Such a listing could not be
+// resumed, so it ignores opts.MaxPages. Both shortcuts are dropped once a
+// page comes back out of order.
+func (c *Client) listPaged(ctx context.Context, path string, opts provider.ListOptions, filtersSince bool, decode func([]byte) ([]provider.Item, error)) (provider.ListResult, error) {
+	logger := slog.Default().With("component", "github", "op", "list-paged", "path", path)
+	start := time.Now()
+
+	since, hasSince := time.Time{}, false
+	if opts.Since != "" {
+		since, hasSince = provider.ParseTime(opts.Since)
+		if !hasSince {
+			logger.Warn("ignore unparsable since", "since", opts.Since)
+		}
+	}
+	maxPages := opts.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+
+	u, err := url.Parse(c.baseURL + path)
+	if err != nil {
+		logger.Error("parse url failed", "err", err)
+		return provider.ListResult{}, err
+	}
+	q := u.Query()
+	q.Set("state", "all")
+	q.Set("per_page", strconv.Itoa(perPage))
+	if hasSince {
+		// issues 接口支持 since，按更新时间正序翻页；pulls 不支持，只能倒序翻到早于 since 为止。
+		q.Set("sort", "updated")
+		if filtersSince {
+			q.Set("since", opts.Since)
+			q.Set("direction", "asc")
+		} else {
+			q.Set("direction", "desc")
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	res := provider.ListResult{Items: []provider.Item{}}
+	// 提前停止和截断后续拉都依赖上游确实按要求的顺序返回，不是的话就翻完所有页。
+	order := provider.UpdateOrder{Asc: filtersSince}
+	for page := 1; ; page++ {
+		if opts.BeforePage != nil {
+			if err := opts.BeforePage(ctx); err != nil {
+				return provider.ListResult{}, err
+			}
+		}
+		r, err := c.get(ctx, u.String())
+		if err != nil {
+			logger.Error("request failed", "page", page, "url", u.String(), "err", err)
+			return provider.ListResult{}, err
+		}
+		items, err := decode(r.Body)
+		if err != nil {
+			logger.Error("decode list failed", "page", page, "url", u.String(), "err", err)
+			return provider.ListResult{}, fmt.Errorf("decode list: %w", err)
+		}
+		res.Pages++
+
+		sorted := order.Add(items)
+		reachedOld := false
+		if hasSince {
+			fresh := items[:0]
+			for _, it := range items {
+				if t, ok := provider.ParseTime(it.UpdatedAt); ok && t.Before(since) {
+					continue
+				}
+				fresh = append(fresh, it)
+			}
+			reachedOld = !filtersSince && sorted && len(fresh) < len(items)
+			items = fresh
+		}
+		logger.Debug("page ok", "page", page, "count", len(items))
+		res.Items = append(res.Items, items...)
+
+		next, more := nextPage(r.Header, u)
+		if reachedOld || !more {
+			break
+		}
+		if page >= maxPages && hasSince && !filtersSince && sorted && page < DefaultMaxPages {
+			// 倒序列表截断后没法接着拉，而翻到早于 since 就会停，页数本来就由 since 限定，
+			// 所以不受 MaxPages 限制，只保留 DefaultMaxPages 这道防线。
+			u = next
+			continue
+		}
+		if page >= maxPages {
+			res.Truncated = true
+			if hasSince && filtersSince && sorted {
+				res.Resume = provider.LatestUpdatedAt(res.Items)
+			}
+			logger.Warn("list truncated at page limit", "pages", page, "max_pages", maxPages, "total", len(res.Items), "resume", res.Resume)
+			break
+		}
+		u = next
+	}
+	logger.Info("list paged ok", "total", len(res.Items), "pages", res.Pages, "truncated", res.Truncated, "elapsed_ms", time.Since(start).Milliseconds())
+	return res, nil
+}
+
+// nextPage follows the Link header's rel="next"; GitHub always sends it while
+// there are more pages.
+func nextPage(h http.Header, cur *url.URL) (*url.URL, bool) {
+	next, ok := provider.LinkRel(h.Values("Link"), "next")
+	if !ok {
+		return nil, false
+	}
+	u, err := cur.Parse(next)
+	if err != nil {
+		return nil, false
+	}
+	return u, true
+}
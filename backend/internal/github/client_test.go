@@ -0,0 +1,295 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tracker/internal/provider"
+)
+
+func testClient(t *testing.T, h http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL+"/", "tok").WithRetry(provider.RetryPolicy{
+		MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 3 * time.Second,
+	})
+}
+
+func TestListIssuesSkipsPullsAndFollowsLink(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/o/r/issues" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer tok" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if r.URL.Query().Get("state") != "all" {
+			t.Errorf("state = %q, want all", r.URL.Query().Get("state"))
+		}
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/o/r/issues?state=all&per_page=100&page=2>; rel="next", <http://%s/repos/o/r/issues?page=2>; rel="last"`, r.Host, r.Host))
+			fmt.Fprint(w, `[
+				{"id":101,"number":1,"title":"bug","state":"open","html_url":"https://github.com/o/r/issues/1","user":{"login":"ann"},
+				 "labels":[{"name":"kind/bug","color":"d73a4a","description":"broken"}],"assignees":[{"login":"bo"}],
+				 "milestone":{"title":"v1"},"comments":3,"type":{"name":"Bug"},"created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-02T00:00:00Z","closed_at":null},
+				{"id":102,"number":2,"title":"a pr","state":"open","pull_request":{"url":"x"},"updated_at":"2024-01-03T00:00:00Z"}
+			]`)
+		case "2":
+			fmt.Fprint(w, `[{"id":103,"number":3,"title":"q","state":"closed","body":null,"updated_at":"2024-01-01T00:00:00Z","closed_at":"2024-01-01T00:00:00Z"}]`)
+		default:
+			t.Errorf("unexpected page %q", r.URL.Query().Get("page"))
+		}
+	})
+
+	res, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Pages != 2 || res.Truncated {
+		t.Fatalf("pages=%d truncated=%v, want 2 pages", res.Pages, res.Truncated)
+	}
+	if len(res.Items) != 2 || res.Items[0].Key != "1" || res.Items[1].Key != "3" {
+		t.Fatalf("items = %+v, want issues 1 and 3 only", res.Items)
+	}
+	it := res.Items[0]
+	if it.ID != "101" || it.Author != "ann" || it.Milestone != "v1" || it.Comments != 3 || it.IssueType != "Bug" || it.PR != nil {
+		t.Errorf("issue 1 = %+v", it)
+	}
+	if len(it.Labels) != 1 || it.Labels[0] != (provider.Label{Name: "kind/bug", Color: "#d73a4a", Description: "broken"}) {
+		t.Errorf("labels = %+v", it.Labels)
+	}
+	if len(it.Assignees) != 1 || it.Assignees[0] != "bo" {
+		t.Errorf("assignees = %v", it.Assignees)
+	}
+}
+
+func TestListIssuesSinceResumesAfterTruncation(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("since") == "" || q.Get("sort") != "updated" || q.Get("direction") != "asc" {
+			t.Errorf("query = %v, want since and oldest update first", q)
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/o/r/issues?page=2>; rel="next"`, r.Host))
+		fmt.Fprint(w, `[
+			{"id":1,"number":1,"title":"a","state":"open","updated_at":"2024-02-01T00:00:00Z"},
+			{"id":2,"number":2,"title":"b","state":"open","updated_at":"2024-02-03T00:00:00Z"}
+		]`)
+	})
+
+	res, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{Since: "2024-01-01T00:00:00Z", MaxPages: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated || res.Resume != "2024-02-03T00:00:00Z" {
+		t.Errorf("truncated=%v resume=%q, want to resume after the newest fetched", res.Truncated, res.Resume)
+	}
+}
+
+func TestListPullsStopsAtSince(t *testing.T) {
+	var pages atomic.Int32
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		pages.Add(1)
+		if q := r.URL.Query(); q.Get("sort") != "updated" || q.Get("direction") != "desc" {
+			t.Errorf("query = %v, want newest first", q)
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/o/r/pulls?page=2>; rel="next"`, r.Host))
+		fmt.Fprint(w, `[
+			{"id":7,"number":7,"title":"fix","state":"closed","merged_at":"2024-02-01T00:00:00Z","updated_at":"2024-02-02T00:00:00Z",
+			 "requested_reviewers":[{"login":"rev"}],"draft":true,"head":{"ref":"feat"},"base":{"ref":"main"}},
+			{"id":6,"number":6,"title":"old","state":"open","updated_at":"2023-12-01T00:00:00Z","head":{"ref":"x"},"base":{"ref":"main"}}
+		]`)
+	})
+
+	res, err := c.ListPulls(context.Background(), "o", "r", provider.ListOptions{Since: "2024-01-01T00:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages.Load() != 1 {
+		t.Errorf("fetched %d pages, want to stop after the first", pages.Load())
+	}
+	if len(res.Items) != 1 {
+		t.Fatalf("items = %+v, want only #7", res.Items)
+	}
+	pr := res.Items[0]
+	if pr.State != "merged" || len(pr.Reviewers) != 1 || pr.Reviewers[0] != "rev" {
+		t.Errorf("pr = %+v", pr)
+	}
+	if pr.PR == nil || !pr.PR.Draft || pr.PR.HeadBranch != "feat" || pr.PR.BaseBranch != "main" || pr.PR.HasSize {
+		t.Errorf("pr meta = %+v", pr.PR)
+	}
+}
+
+func TestListPullsSinceIgnoresMaxPages(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// 每页一个 PR，更新时间逐页变早，第 3 页早于 since。
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/o/r/pulls?page=%d>; rel="next"`, r.Host, page+1))
+		fmt.Fprintf(w, `[{"id":%d,"number":%d,"state":"open","updated_at":"2024-01-%02dT00:00:00Z","head":{"ref":"x"},"base":{"ref":"main"}}]`,
+			page, page, 10-page*3)
+	})
+
+	res, err := c.ListPulls(context.Background(), "o", "r", provider.ListOptions{Since: "2024-01-02T00:00:00Z", MaxPages: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Truncated || res.Pages != 3 || len(res.Items) != 2 {
+		t.Errorf("truncated=%v pages=%d items=%+v, want #1 and #2 paged past MaxPages", res.Truncated, res.Pages, res.Items)
+	}
+
+	// 不带 since 的列表照常在 MaxPages 截断。
+	res, err = c.ListPulls(context.Background(), "o", "r", provider.ListOptions{MaxPages: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated || res.Pages != 1 {
+		t.Errorf("full listing: truncated=%v pages=%d", res.Truncated, res.Pages)
+	}
+}
+
+func TestListPullsUnsortedKeepsPaging(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "":
+			// 这一页没按更新时间倒序：后面的页里可能还有 since 之后的更新。
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/o/r/pulls?page=2>; rel="next"`, r.Host))
+			fmt.Fprint(w, `[
+				{"id":6,"number":6,"state":"open","updated_at":"2023-12-01T00:00:00Z","head":{"ref":"x"},"base":{"ref":"main"}},
+				{"id":7,"number":7,"state":"open","updated_at":"2024-02-02T00:00:00Z","head":{"ref":"y"},"base":{"ref":"main"}}
+			]`)
+		case "2":
+			fmt.Fprint(w, `[{"id":5,"number":5,"state":"open","updated_at":"2024-03-01T00:00:00Z","head":{"ref":"z"},"base":{"ref":"main"}}]`)
+		}
+	})
+
+	res, err := c.ListPulls(context.Background(), "o", "r", provider.ListOptions{Since: "2024-01-01T00:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 2 || res.Pages != 2 {
+		t.Errorf("pages=%d items=%+v, want #7 and #5 from both pages", res.Pages, res.Items)
+	}
+}
+
+func TestGetPullDetails(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/o/r/pulls/7" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"id":7,"number":7,"state":"open","comments":2,"additions":10,"deletions":0,"changed_files":1,"mergeable":false,"merged_by":null,"head":{"ref":"f"},"base":{"ref":"main"}}`)
+	})
+
+	pr, err := c.GetPull(context.Background(), "o", "r", "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Comments != 2 || pr.PR == nil || !pr.PR.HasSize || pr.PR.Additions != 10 || pr.PR.ChangedFiles != 1 {
+		t.Errorf("pr = %+v meta = %+v", pr, pr.PR)
+	}
+	if pr.PR.Mergeable == nil || *pr.PR.Mergeable {
+		t.Errorf("mergeable = %v, want false", pr.PR.Mergeable)
+	}
+
+	if _, err := c.GetIssue(context.Background(), "o", "r", "8"); provider.StatusCode(err) != http.StatusNotFound {
+		t.Errorf("GetIssue on missing issue: err = %v, want 404", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Run("records quota", func(t *testing.T) {
+		reset := time.Now().Add(time.Hour).Unix()
+		c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "4321")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+			w.Header().Set("X-RateLimit-Resource", "core")
+			fmt.Fprint(w, `[]`)
+		})
+		if _, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		q := c.Quota()
+		if !q.Known || q.Limit != 5000 || q.Remaining != 4321 || q.Reset.Unix() != reset {
+			t.Errorf("quota = %+v", q)
+		}
+	})
+
+	t.Run("waits for a near reset", func(t *testing.T) {
+		var calls atomic.Int32
+		c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
+				http.Error(w, `{"message":"API rate limit exceeded"}`, http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `[]`)
+		})
+		if _, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if calls.Load() != 2 {
+			t.Errorf("calls = %d, want a single retry", calls.Load())
+		}
+	})
+
+	t.Run("secondary limit honors Retry-After", func(t *testing.T) {
+		var calls atomic.Int32
+		c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				http.Error(w, `{"message":"You have exceeded a secondary rate limit"}`, http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `[]`)
+		})
+		if _, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if calls.Load() != 2 {
+			t.Errorf("calls = %d, want a single retry", calls.Load())
+		}
+	})
+
+	t.Run("fails fast on a distant reset", func(t *testing.T) {
+		var calls atomic.Int32
+		c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+			http.Error(w, `{"message":"API rate limit exceeded"}`, http.StatusForbidden)
+		})
+		_, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{})
+		if provider.StatusCode(err) != http.StatusForbidden {
+			t.Fatalf("err = %v, want 403", err)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("calls = %d, want no retry", calls.Load())
+		}
+	})
+
+	t.Run("plain 403 is not retried", func(t *testing.T) {
+		var calls atomic.Int32
+		c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("X-RateLimit-Remaining", "4999")
+			http.Error(w, `{"message":"Resource not accessible by integration"}`, http.StatusForbidden)
+		})
+		if _, err := c.ListIssues(context.Background(), "o", "r", provider.ListOptions{}); provider.StatusCode(err) != http.StatusForbidden {
+			t.Fatalf("err = %v, want 403", err)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("calls = %d, want no retry", calls.Load())
+		}
+	})
+}
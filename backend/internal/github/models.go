@@ -0,0 +1,246 @@
+package github
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"tracker/internal/provider"
+)
+
+type User struct {
+	Login string `json:"login"`
+}
+
+func (u *User) login() string {
+	if u == nil {
+		return ""
+	}
+	return u.Login
+}
+
+type Label struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+type Milestone struct {
+	Title string `json:"title"`
+}
+
+type Issue struct {
+	ID        int64      `json:"id"`
+	Number    int        `json:"number"`
+	Title     string     `json:"title"`
+	State     string     `json:"state"`
+	HTMLURL   string     `json:"html_url"`
+	Body      string     `json:"body"`
+	User      *User      `json:"user"`
+	Labels    []Label    `json:"labels"`
+	Assignee  *User      `json:"assignee"`
+	Assignees []User     `json:"assignees"`
+	Milestone *Milestone `json:"milestone"`
+	Comments  int        `json:"comments"`
+	Type      *struct {
+		Name string `json:"name"`
+	} `json:"type"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	ClosedAt  string `json:"closed_at"`
+	// PullRequest is set when the issues endpoint returns a PR, which GitHub
+	// models as an issue.
+	PullRequest json.RawMessage `json:"pull_request"`
+}
+
+func (it Issue) isPull() bool { return len(it.PullRequest) > 0 && string(it.PullRequest) != "null" }
+
+type Branch struct {
+	Ref string `json:"ref"`
+}
+
+type PullRequest struct {
+	ID                 int64      `json:"id"`
+	Number             int        `json:"number"`
+	Title              string     `json:"title"`
+	State              string     `json:"state"`
+	HTMLURL            string     `json:"html_url"`
+	Body               string     `json:"body"`
+	User               *User      `json:"user"`
+	Labels             []Label    `json:"labels"`
+	Assignee           *User      `json:"assignee"`
+	Assignees          []User     `json:"assignees"`
+	RequestedReviewers []User     `json:"requested_reviewers"`
+	Milestone          *Milestone `json:"milestone"`
+	CreatedAt          string     `json:"created_at"`
+	UpdatedAt          string     `json:"updated_at"`
+	ClosedAt           string     `json:"closed_at"`
+	MergedAt           string     `json:"merged_at"`
+	MergedBy           *User      `json:"merged_by"`
+	Draft              bool       `json:"draft"`
+	Head               Branch     `json:"head"`
+	Base               Branch     `json:"base"`
+	// The fields below only come back from the single-PR endpoint.
+	Comments     *int  `json:"comments"`
+	Additions    *int  `json:"additions"`
+	Deletions    *int  `json:"deletions"`
+	ChangedFiles *int  `json:"changed_files"`
+	Mergeable    *bool `json:"mergeable"`
+}
+
+type Comment struct {
+	ID        int64  `json:"id"`
+	Body      string `json:"body"`
+	User      *User  `json:"user"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type Repository struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Owner    *User  `json:"owner"`
+	Archived bool   `json:"archived"`
+}
+
+func (it Issue) toItem() provider.Item {
+	issueType := ""
+	if it.Type != nil {
+		issueType = it.Type.Name
+	}
+	return provider.Item{
+		Key:       strconv.Itoa(it.Number),
+		ID:        strconv.FormatInt(it.ID, 10),
+		Title:     it.Title,
+		State:     it.State,
+		URL:       it.HTMLURL,
+		Author:    it.User.login(),
+		CreatedAt: it.CreatedAt,
+		UpdatedAt: it.UpdatedAt,
+		Body:      it.Body,
+		Labels:    toLabels(it.Labels),
+		Assignees: logins(it.Assignee, it.Assignees),
+		Milestone: milestoneTitle(it.Milestone),
+		ClosedAt:  it.ClosedAt,
+		Comments:  it.Comments,
+		IssueType: issueType,
+	}
+}
+
+func (pr PullRequest) toItem() provider.Item {
+	// GitHub 只有 open/closed，合并过的 PR 按 GitCode 的习惯记为 merged。
+	state := pr.State
+	if pr.MergedAt != "" {
+		state = "merged"
+	}
+	return provider.Item{
+		Key:       strconv.Itoa(pr.Number),
+		ID:        strconv.FormatInt(pr.ID, 10),
+		Title:     pr.Title,
+		State:     state,
+		URL:       pr.HTMLURL,
+		Author:    pr.User.login(),
+		CreatedAt: pr.CreatedAt,
+		UpdatedAt: pr.UpdatedAt,
+		Body:      pr.Body,
+		Labels:    toLabels(pr.Labels),
+		Assignees: logins(pr.Assignee, pr.Assignees),
+		Reviewers: logins(nil, pr.RequestedReviewers),
+		Milestone: milestoneTitle(pr.Milestone),
+		ClosedAt:  pr.ClosedAt,
+		MergedAt:  pr.MergedAt,
+		Comments:  intOf(pr.Comments),
+		PR: &provider.PRMeta{
+			MergedBy:     pr.MergedBy.login(),
+			Draft:        pr.Draft,
+			HeadBranch:   pr.Head.Ref,
+			BaseBranch:   pr.Base.Ref,
+			Additions:    intOf(pr.Additions),
+			Deletions:    intOf(pr.Deletions),
+			ChangedFiles: intOf(pr.ChangedFiles),
+			HasSize:      pr.Additions != nil || pr.Deletions != nil || pr.ChangedFiles != nil,
+			Mergeable:    pr.Mergeable,
+		},
+	}
+}
+
+func (r Repository) toRepo(owner string) provider.Repo {
+	if o, n, ok := strings.Cut(r.FullName, "/"); ok {
+		return provider.Repo{Owner: o, Name: n, Archived: r.Archived}
+	}
+	if l := r.Owner.login(); l != "" {
+		owner = l
+	}
+	return provider.Repo{Owner: owner, Name: r.Name, Archived: r.Archived}
+}
+
+func toLabels(in []Label) []provider.Label {
+	out := make([]provider.Label, 0, len(in))
+	for _, l := range in {
+		// GitHub 的颜色不带 #，和 GitCode 保持一致。
+		color := l.Color
+		if color != "" && !strings.HasPrefix(color, "#") {
+			color = "#" + color
+		}
+		out = append(out, provider.Label{Name: l.Name, Color: color, Description: l.Description})
+	}
+	return out
+}
+
+// logins merges a single user field with a user list into unique logins.
+func logins(one *User, many []User) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	add := func(l string) {
+		if l != "" && !seen[l] {
+			seen[l] = true
+			out = append(out, l)
+		}
+	}
+	add(one.login())
+	for i := range many {
+		add(many[i].login())
+	}
+	return out
+}
+
+func milestoneTitle(m *Milestone) string {
+	if m == nil {
+		return ""
+	}
+	return m.Title
+}
+
+func intOf(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func decodeIssues(body []byte) ([]provider.Item, error) {
+	var raw []Issue
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	items := make([]provider.Item, 0, len(raw))
+	for _, it := range raw {
+		if it.isPull() {
+			continue
+		}
+		items = append(items, it.toItem())
+	}
+	return items, nil
+}
+
+func decodePulls(body []byte) ([]provider.Item, error) {
+	var raw []PullRequest
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	items := make([]provider.Item, 0, len(raw))
+	for _, pr := range raw {
+		items = append(items, pr.toItem())
+	}
+	return items, nil
+}
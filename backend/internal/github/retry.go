@@ -0,0 +1,131 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"tracker/internal/provider"
+)
+
+// WithRetry replaces the client's retry policy and returns the client.
+func (c *Client) WithRetry(p provider.RetryPolicy) *Client {
+	c.retry = provider.NewRetrier("github", p)
+	return c
+}
+
+type quotaState struct {
+	mu sync.Mutex
+	q  provider.Quota
+}
+
+func (c *Client) Quota() provider.Quota {
+	c.quota.mu.Lock()
+	defer c.quota.mu.Unlock()
+	return c.quota.q
+}
+
+// observeQuota records the core REST quota. Search and other resources have
+// their own, much smaller buckets that would make pacing far too cautious.
+func (c *Client) observeQuota(h http.Header) {
+	if res := h.Get("X-RateLimit-Resource"); res != "" && res != "core" {
+		return
+	}
+	remaining, ok := provider.HeaderInt(h, "X-RateLimit-Remaining")
+	if !ok {
+		return
+	}
+	q := provider.Quota{Known: true, Remaining: remaining}
+	q.Limit, _ = provider.HeaderInt(h, "X-RateLimit-Limit")
+	if reset, ok := provider.HeaderInt(h, "X-RateLimit-Reset"); ok {
+		q.Reset = time.Unix(int64(reset), 0)
+	}
+	c.quota.mu.Lock()
+	c.quota.q = q
+	c.quota.mu.Unlock()
+}
+
+// get issues a GET and retries rate limits, 5xx and transient network errors.
+// A rate limit whose reset is further away than RetryPolicy.MaxDelay fails
+// right away instead of burning retries that cannot succeed.
+func (c *Client) get(ctx context.Context, fullURL string) (*provider.Response, error) {
+	res, err := c.retry.Do(ctx, fullURL, func() (*provider.Response, error) {
+		return c.getOnce(ctx, fullURL)
+	}, c.classify)
+	if err != nil {
+		return nil, err
+	}
+	if res.Status < 200 || res.Status >= 300 {
+		return nil, httpError(fullURL, res)
+	}
+	return res, nil
+}
+
+func (c *Client) getOnce(ctx context.Context, fullURL string) (*provider.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	c.observeQuota(res.Header)
+	return &provider.Response{Status: res.StatusCode, Header: res.Header, Body: body}, nil
+}
+
+// classify reports whether a response is worth retrying and after how long.
+// GitHub signals both the primary and the secondary rate limit with 403 or
+// 429; a plain 403 (no access) is not retried.
+func (c *Client) classify(res *provider.Response, err error, attempt int) (bool, time.Duration) {
+	policy := c.retry.Policy
+	if err != nil {
+		if provider.TransientError(err) {
+			return true, policy.Backoff(attempt)
+		}
+		return false, 0
+	}
+	switch {
+	case res.Status == http.StatusForbidden || res.Status == http.StatusTooManyRequests:
+		if d, ok := provider.RetryAfter(res.Header); ok {
+			return true, d
+		}
+		if remaining, ok := provider.HeaderInt(res.Header, "X-RateLimit-Remaining"); ok && remaining == 0 {
+			if reset, ok := provider.HeaderInt(res.Header, "X-RateLimit-Reset"); ok {
+				return true, max(time.Until(time.Unix(int64(reset), 0)), 0)
+			}
+			return true, policy.Backoff(attempt)
+		}
+		if res.Status == http.StatusTooManyRequests || bytes.Contains(bytes.ToLower(res.Body), []byte("rate limit")) {
+			// 没有任何提示的二级限流：GitHub 建议至少等一分钟。
+			return true, time.Minute
+		}
+		return false, 0
+	case res.Status >= 500:
+		if d, ok := provider.RetryAfter(res.Header); ok {
+			return true, d
+		}
+		return true, policy.Backoff(attempt)
+	}
+	return false, 0
+}
+
+func httpError(fullURL string, res *provider.Response) error {
+	return &provider.HTTPError{Provider: "github", URL: fullURL, Status: res.Status, Body: strings.TrimSpace(string(res.Body))}
+}
@@ -0,0 +1,163 @@
+// Package internaltracker talks to the in-house issue tracker that items are
+// mirrored into when syncInternal is turned on.
+package internaltracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"tracker/internal/provider"
+)
+
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http: &http.Client{
+			Timeout: 20 * time.Second,
+		},
+	}
+}
+
+// NewIssue is what the tracker sends when it opens an internal issue for an item.
+type NewIssue struct {
+	Title         string `json:"title"`
+	Body          string `json:"body"`
+	Link          string `json:"link"` // the upstream issue or PR
+	Repo          string `json:"repo"`
+	Kind          string `json:"kind"`
+	Key           string `json:"key"`
+	Priority      int    `json:"priority"`
+	Assignee      string `json:"assignee,omitempty"`
+	AssigneeGroup string `json:"assigneeGroup,omitempty"`
+}
+
+type Issue struct {
+	ID  string
+	URL string
+	// State is the tracker's own state name; see Closed.
+	State string
+}
+
+// closedStates are the tracker states that mean the issue is finished.
+var closedStates = []string{"closed", "done", "resolved", "fixed", "rejected", "canceled", "cancelled"}
+
+// Closed reports whether the issue is finished, whatever the tracker calls it.
+func (is Issue) Closed() bool { return slices.Contains(closedStates, strings.ToLower(is.State)) }
+
+// issueResponse accepts both numeric and string ids, and url or web_url.
+type issueResponse struct {
+	ID     json.RawMessage `json:"id"`
+	URL    string          `json:"url"`
+	WebURL string          `json:"web_url"`
+	State  string          `json:"state"`
+	Status string          `json:"status"`
+}
+
+func (r issueResponse) issue() Issue {
+	id := strings.Trim(string(r.ID), `"`)
+	if id == "null" {
+		id = ""
+	}
+	u := r.WebURL
+	if u == "" {
+		u = r.URL
+	}
+	st := r.State
+	if st == "" {
+		st = r.Status
+	}
+	return Issue{ID: id, URL: u, State: st}
+}
+
+// CreateIssue opens an internal issue. idempotencyKey is sent as the
+// Idempotency-Key header so a retry after a lost response does not open a
+// second issue on trackers that honour it.
+func (c *Client) CreateIssue(ctx context.Context, in NewIssue, idempotencyKey string) (Issue, error) {
+	logger := slog.Default().With("component", "internaltracker", "op", "create-issue", "repo", in.Repo, "key", in.Key)
+	b, err := json.Marshal(in)
+	if err != nil {
+		return Issue{}, err
+	}
+	body, err := c.do(ctx, http.MethodPost, c.baseURL+"/api/issues", b, idempotencyKey)
+	if err != nil {
+		logger.Warn("request failed", "err", err)
+		return Issue{}, err
+	}
+	var res issueResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return Issue{}, fmt.Errorf("decode issue: %w", err)
+	}
+	is := res.issue()
+	if is.ID == "" {
+		return Issue{}, fmt.Errorf("internal tracker returned no issue id")
+	}
+	return is, nil
+}
+
+// GetIssue fetches an internal issue by id.
+func (c *Client) GetIssue(ctx context.Context, id string) (Issue, error) {
+	body, err := c.do(ctx, http.MethodGet, c.baseURL+"/api/issues/"+url.PathEscape(id), nil, "")
+	if err != nil {
+		return Issue{}, err
+	}
+	var res issueResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return Issue{}, fmt.Errorf("decode issue: %w", err)
+	}
+	is := res.issue()
+	if is.ID == "" {
+		is.ID = id
+	}
+	return is, nil
+}
+
+func (c *Client) do(ctx context.Context, method, fullURL string, body []byte, idempotencyKey string) ([]byte, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fullURL, rd)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &provider.HTTPError{Provider: "internal", URL: fullURL, Status: res.StatusCode, Body: string(resBody)}
+	}
+	return resBody, nil
+}
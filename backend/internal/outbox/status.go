@@ -0,0 +1,51 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// CheckStatus fetches the state of up to StatusBatch linked internal issues,
+// least recently checked first, and stores it on the item, logging items whose internal issue now disagrees with
+// upstream (e.g. the upstream issue was closed but the internal one is
+// still open). A failed fetch keeps the previous state.
+func (w *Worker) CheckStatus(ctx context.Context) {
+	logger := slog.Default().With("component", "outbox", "op", "check-status")
+	start := time.Now()
+	links, err := w.st.ListInternalLinks(ctx, w.cfg.StatusBatch)
+	if err != nil {
+		logger.Error("load internal links failed", "err", err)
+		return
+	}
+
+	var checked, failed, mismatched int
+	for _, l := range links {
+		if ctx.Err() != nil {
+			return
+		}
+		issue, err := w.client.GetIssue(ctx, l.IssueID)
+		if err != nil {
+			failed++
+			logger.Warn("get internal issue failed", "issue", l.IssueID, "repo", l.RepoFullName, "kind", l.Kind, "key", l.ExternalKey, "err", err)
+			continue
+		}
+		state := "open"
+		if issue.Closed() {
+			state = "closed"
+		}
+		if err := w.st.SetInternalState(ctx, l.ItemID, state); err != nil {
+			logger.Error("store internal state failed", "issue", l.IssueID, "err", err)
+			return
+		}
+		checked++
+		if l.StatusMismatch(state) {
+			mismatched++
+			if state != l.InternalState {
+				logger.Warn("status mismatch", "issue", l.IssueID, "repo", l.RepoFullName, "kind", l.Kind, "key", l.ExternalKey,
+					"upstream", l.State, "internal", state)
+			}
+		}
+	}
+	logger.Info("check status ok", "checked", checked, "failed", failed, "mismatched", mismatched, "elapsed_ms", time.Since(start).Milliseconds())
+}
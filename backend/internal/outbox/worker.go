@@ -0,0 +1,203 @@
+// Package outbox creates internal tracker issues for items whose
+// syncInternal was turned on. PatchCustom records the request in the
+// internal_outbox table; the worker here picks it up, so a slow or failing
+// internal tracker never blocks or loses an edit. The same worker
+// periodically reads back the state of the issues it created.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"tracker/internal/internaltracker"
+	"tracker/internal/provider"
+	"tracker/internal/store"
+)
+
+type Config struct {
+	// PollInterval is how often due entries are looked for.
+	PollInterval time.Duration
+	// MaxAttempts is how many times an entry is tried before it is marked failed.
+	MaxAttempts int
+	// Backoff spaces out the attempts of one entry (BaseDelay and MaxDelay only).
+	Backoff provider.RetryPolicy
+	// StatusInterval is how often internal issue states are refreshed; 0 disables it.
+	StatusInterval time.Duration
+	// StatusBatch caps the issues fetched per refresh, least recently checked
+	// first, so a large backlog is spread over several sweeps.
+	StatusBatch int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PollInterval:   10 * time.Second,
+		MaxAttempts:    10,
+		Backoff:        provider.RetryPolicy{BaseDelay: 30 * time.Second, MaxDelay: time.Hour},
+		StatusInterval: 10 * time.Minute,
+		StatusBatch:    200,
+	}
+}
+
+type Worker struct {
+	st     *store.Store
+	client *internaltracker.Client
+	cfg    Config
+}
+
+// New returns a worker; client may be nil when no internal tracker is
+// configured, in which case entries stay pending until one is.
+func New(st *store.Store, client *internaltracker.Client, cfg Config) *Worker {
+	return &Worker{st: st, client: client, cfg: cfg}
+}
+
+// Run works off due entries every PollInterval until ctx is done. Internal
+// issue states are refreshed every StatusInterval on a separate goroutine, so
+// a slow status sweep never holds up issue creation.
+func (w *Worker) Run(ctx context.Context) {
+	logger := slog.Default().With("component", "outbox", "op", "run")
+	if w.client == nil {
+		logger.Warn("INTERNAL_TRACKER_URL not set; internal issues stay queued")
+		return
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	if w.cfg.StatusInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.statusLoop(ctx)
+		}()
+	}
+
+	t := time.NewTicker(w.cfg.PollInterval)
+	defer t.Stop()
+	for {
+		w.Drain(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (w *Worker) statusLoop(ctx context.Context) {
+	t := time.NewTicker(w.cfg.StatusInterval)
+	defer t.Stop()
+	for {
+		w.CheckStatus(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Drain handles every entry that is due now, each at most once per call. It
+// stops at the first entry whose outcome could not be recorded: that entry is
+// still due, and trying it again right away could open a second issue.
+func (w *Worker) Drain(ctx context.Context) {
+	logger := slog.Default().With("component", "outbox", "op", "drain")
+	handled := map[int64]bool{}
+	for ctx.Err() == nil {
+		entries, err := w.st.DueOutbox(ctx, 20+len(handled))
+		if err != nil {
+			logger.Error("load outbox failed", "err", err)
+			return
+		}
+		fresh := 0
+		for _, e := range entries {
+			if ctx.Err() != nil {
+				return
+			}
+			if handled[e.ID] {
+				continue
+			}
+			handled[e.ID] = true
+			fresh++
+			if err := w.handle(ctx, e); err != nil {
+				logger.Error("outbox store write failed; waiting for next poll", "id", e.ID, "err", err)
+				return
+			}
+		}
+		if fresh == 0 {
+			return
+		}
+	}
+}
+
+// handle tries to create the issue for e. The error is only about recording
+// the outcome in the store; upstream failures are recorded as attempts.
+func (w *Worker) handle(ctx context.Context, e store.OutboxEntry) error {
+	it := e.Item
+	logger := slog.Default().With("component", "outbox", "op", "create-issue", "id", e.ID,
+		"kind", it.Kind, "repo", it.RepoFullName, "key", it.ExternalKey, "attempt", e.Attempts+1)
+	start := time.Now()
+
+	issue, err := w.client.CreateIssue(ctx, internaltracker.NewIssue{
+		Title:         it.Title,
+		Body:          it.Body,
+		Link:          it.URL,
+		Repo:          it.RepoFullName,
+		Kind:          it.Kind,
+		Key:           it.ExternalKey,
+		Priority:      it.Priority,
+		Assignee:      it.Assignee,
+		AssigneeGroup: it.AssigneeGroup,
+	}, fmt.Sprintf("tracker-outbox-%d", e.ID))
+	if err == nil {
+		canceled, err := w.st.CompleteOutbox(ctx, e.ID, issue.ID, issue.URL)
+		if err != nil {
+			logger.Error("complete outbox failed", "issue", issue.ID, "err", err)
+			return err
+		}
+		if canceled {
+			// 创建期间用户关掉了 syncInternal：单已经建好，仍记在条目上，重新打开时不会再建一张。
+			logger.Warn("outbox entry canceled while creating internal issue", "issue", issue.ID, "url", issue.URL)
+			return nil
+		}
+		logger.Info("create internal issue ok", "issue", issue.ID, "elapsed_ms", time.Since(start).Milliseconds())
+		return nil
+	}
+	if ctx.Err() != nil {
+		// 关停时被打断的请求不算一次失败。
+		return nil
+	}
+
+	var next time.Time
+	if retryable(err) && e.Attempts+1 < w.cfg.MaxAttempts {
+		next = time.Now().Add(max(w.cfg.Backoff.Backoff(e.Attempts), w.cfg.Backoff.BaseDelay))
+	}
+	if rerr := w.st.RetryOutbox(ctx, e.ID, err.Error(), next); rerr != nil {
+		logger.Error("record outbox attempt failed", "err", rerr)
+		return rerr
+	}
+	if next.IsZero() {
+		logger.Error("create internal issue failed", "err", err)
+		return nil
+	}
+	logger.Warn("create internal issue failed; will retry", "next", next.UTC().Format(time.RFC3339), "err", err)
+	return nil
+}
+
+// retryable is false for requests the internal tracker rejected outright;
+// sending them again would fail the same way.
+func retryable(err error) bool {
+	var httpErr *provider.HTTPError
+	if !errors.As(err, &httpErr) {
+		return true
+	}
+	switch {
+	case httpErr.Status == http.StatusRequestTimeout, httpErr.Status == http.StatusTooManyRequests:
+		return true
+	case httpErr.Status >= 400 && httpErr.Status < 500:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"tracker/internal/internaltracker"
+	"tracker/internal/provider"
+	"tracker/internal/store"
+)
+
+// testWorker wires a worker to a fresh store holding o/r#1 with syncInternal
+// on, and to a fake internal tracker running handler after each create. It
+// also returns a second handle on the database for tests to tamper with.
+func testWorker(t *testing.T, handler func()) (*Worker, *store.Store, *sql.DB, *atomic.Int32) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tracker.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	st := store.New(db)
+	ctx := context.Background()
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.UpsertCore(ctx, []store.CoreItem{{Kind: "issue", RepoFullName: "o/r", ExternalKey: "1", Title: "crash", State: "open",
+		CreatedAt: "2024-01-01T00:00:00Z", UpdatedAt: "2024-01-01T00:00:00Z"}}); err != nil {
+		t.Fatal(err)
+	}
+	on := true
+	if _, err := st.PatchCustom(ctx, "issue", "o/r", "1", store.CustomPatch{SyncInternal: &on}); err != nil {
+		t.Fatal(err)
+	}
+
+	var creates atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := creates.Add(1)
+		if handler != nil {
+			handler()
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id": %d, "url": "https://it/%d"}`, n, n)
+	}))
+	t.Cleanup(srv.Close)
+
+	raw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { raw.Close() })
+	return New(st, internaltracker.NewClient(srv.URL, "tok"), DefaultConfig()), st, raw, &creates
+}
+
+func TestDrainCreatesIssue(t *testing.T) {
+	w, st, _, creates := testWorker(t, nil)
+	ctx := context.Background()
+	w.Drain(ctx)
+	w.Drain(ctx)
+	items, err := st.ListItems(ctx, store.ListFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creates.Load() != 1 || items[0].InternalIssueID != "1" {
+		t.Errorf("creates = %d, item = %+v", creates.Load(), items[0])
+	}
+}
+
+func TestDrainStopsWhenCompleteFails(t *testing.T) {
+	w, _, raw, creates := testWorker(t, nil)
+	// 让 CompleteOutbox 失败而 DueOutbox 照常可读：条目一直是 pending 且到期。
+	if _, err := raw.Exec(`CREATE TRIGGER fail_done BEFORE UPDATE ON internal_outbox
+		WHEN NEW.status = 'done' BEGIN SELECT RAISE(ABORT, 'disk full'); END;`); err != nil {
+		t.Fatal(err)
+	}
+	w.Drain(context.Background())
+	if n := creates.Load(); n != 1 {
+		t.Errorf("CreateIssue called %d times in one drain, want 1", n)
+	}
+}
+
+func TestDrainCanceledWhileCreating(t *testing.T) {
+	var st *store.Store
+	w, st, _, creates := testWorker(t, func() {
+		off := false
+		if _, err := st.PatchCustom(context.Background(), "issue", "o/r", "1", store.CustomPatch{SyncInternal: &off}); err != nil {
+			t.Error(err)
+		}
+	})
+	ctx := context.Background()
+	w.Drain(ctx)
+	w.Drain(ctx)
+
+	// 单已建好：重新打开 syncInternal 后不会再建第二张。
+	on := true
+	if _, err := st.PatchCustom(ctx, "issue", "o/r", "1", store.CustomPatch{SyncInternal: &on}); err != nil {
+		t.Fatal(err)
+	}
+	w.Drain(ctx)
+	items, err := st.ListItems(ctx, store.ListFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creates.Load() != 1 || items[0].InternalIssueID != "1" || items[0].InternalIssueURL != "https://it/1" {
+		t.Errorf("creates = %d, item internal issue = %q %q", creates.Load(), items[0].InternalIssueID, items[0].InternalIssueURL)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection refused"), true},
+		{&provider.HTTPError{Status: http.StatusServiceUnavailable}, true},
+		{&provider.HTTPError{Status: http.StatusTooManyRequests}, true},
+		{&provider.HTTPError{Status: http.StatusRequestTimeout}, true},
+		{&provider.HTTPError{Status: http.StatusBadRequest}, false},
+		{&provider.HTTPError{Status: http.StatusUnauthorized}, false},
+		{fmt.Errorf("create: %w", &provider.HTTPError{Status: http.StatusUnprocessableEntity}), false},
+	} {
+		if got := retryable(tc.err); got != tc.want {
+			t.Errorf("retryable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// HTTP helpers shared by the REST clients.
+
+type RetryPolicy struct {
+	// MaxRetries is the number of retries per request after the first attempt.
+	MaxRetries int
+	// Budget caps the retries across all requests made by the client; 0 means no cap.
+	Budget    int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 4,
+		Budget:     50,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   30 * time.Second,
+	}
+}
+
+// Backoff is exponential with full jitter.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	d := p.BaseDelay << min(attempt, 16)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) + 1
+}
+
+// Response is one REST response, read in full.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// StatusOf is res.Status, or 0 when there is no response.
+func StatusOf(res *Response) int {
+	if res == nil {
+		return 0
+	}
+	return res.Status
+}
+
+// Classify reports whether the outcome of an attempt is worth retrying and
+// after how long. Each client brings its own, since hosts signal rate limits
+// differently.
+type Classify func(res *Response, err error, attempt int) (retry bool, wait time.Duration)
+
+// Retrier runs requests under a RetryPolicy. Its budget is shared by every
+// request of the client that owns it.
+type Retrier struct {
+	Provider string
+	Policy   RetryPolicy
+
+	mu   sync.Mutex
+	used int
+}
+
+func NewRetrier(provider string, p RetryPolicy) *Retrier {
+	return &Retrier{Provider: provider, Policy: p}
+}
+
+// Do calls send until classify takes its outcome as final, the retries run
+// out or ctx is done, and returns the last outcome; the caller decides which
+// statuses count as success. A wait longer than Policy.MaxDelay is not
+// retried, since it cannot succeed within the policy.
+func (r *Retrier) Do(ctx context.Context, url string, send func() (*Response, error), classify Classify) (*Response, error) {
+	logger := slog.Default().With("component", r.Provider, "op", "retry")
+	for attempt := 0; ; attempt++ {
+		res, err := send()
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+		retryable, wait := classify(res, err, attempt)
+		if retryable && wait > r.Policy.MaxDelay {
+			logger.Warn("retry wait beyond max delay", "url", url, "wait_ms", wait.Milliseconds(), "status", StatusOf(res))
+			retryable = false
+		}
+		if !retryable {
+			return res, err
+		}
+		if attempt >= r.Policy.MaxRetries || !r.take() {
+			logger.Error("retries exhausted", "url", url, "attempt", attempt+1, "err", err, "status", StatusOf(res))
+			return res, err
+		}
+
+		logger.Warn("retrying request", "url", url, "attempt", attempt+1, "wait_ms", wait.Milliseconds(), "err", err, "status", StatusOf(res))
+		if err := SleepCtx(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// take uses up one retry of the budget; it reports false once none are left.
+func (r *Retrier) take() bool {
+	if r.Policy.Budget <= 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.used >= r.Policy.Budget {
+		return false
+	}
+	r.used++
+	return true
+}
+
+// TransientError reports whether a request error is worth retrying: a
+// timeout, a reset or refused connection, or a response cut short. Other
+// errors, such as a bad URL, a TLS failure or a canceled context, are not.
+func TransientError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// HeaderInt returns the first of keys that is set to an integer.
+func HeaderInt(h http.Header, keys ...string) (int, bool) {
+	for _, k := range keys {
+		if v := strings.TrimSpace(h.Get(k)); v != "" {
+			if n, err := strconv.Atoi(v); err == nil {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// LinkRel extracts the URL for rel from RFC 8288 Link header values.
+func LinkRel(values []string, rel string) (string, bool) {
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			segs := strings.Split(part, ";")
+			if len(segs) < 2 {
+				continue
+			}
+			target := strings.TrimSpace(segs[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, p := range segs[1:] {
+				k, val, ok := strings.Cut(strings.TrimSpace(p), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(k), "rel") {
+					continue
+				}
+				for _, r := range strings.Fields(strings.Trim(val, `"`)) {
+					if strings.EqualFold(r, rel) {
+						return target[1 : len(target)-1], true
+					}
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+func RetryAfter(h http.Header) (time.Duration, bool) {
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// ResetTime accepts either a unix timestamp or a number of seconds from now.
+func ResetTime(v int) time.Time {
+	if v > 1_000_000_000 {
+		return time.Unix(int64(v), 0)
+	}
+	return time.Now().Add(time.Duration(v) * time.Second)
+}
+
+func SleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
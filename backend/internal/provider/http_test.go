@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetrierBudgetAndMaxDelay(t *testing.T) {
+	r := NewRetrier("test", RetryPolicy{MaxRetries: 3, Budget: 4, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+	ctx := context.Background()
+	calls := 0
+	send := func() (*Response, error) {
+		calls++
+		return &Response{Status: http.StatusBadGateway}, nil
+	}
+	retryAfter := func(wait time.Duration) Classify {
+		return func(res *Response, err error, attempt int) (bool, time.Duration) { return res.Status >= 500, wait }
+	}
+
+	// 每个请求最多重试 3 次；预算 4 次由同一个 Retrier 的所有请求共用。
+	res, err := r.Do(ctx, "u", send, retryAfter(time.Millisecond))
+	if err != nil || res.Status != http.StatusBadGateway || calls != 4 {
+		t.Fatalf("first request: calls=%d res=%+v err=%v", calls, res, err)
+	}
+	calls = 0
+	if _, err := r.Do(ctx, "u", send, retryAfter(time.Millisecond)); err != nil || calls != 2 {
+		t.Errorf("second request: calls=%d err=%v, want one retry left in the budget", calls, err)
+	}
+
+	// 等待超过 MaxDelay 的不重试。
+	r = NewRetrier("test", RetryPolicy{MaxRetries: 3, MaxDelay: 10 * time.Millisecond})
+	calls = 0
+	if _, err := r.Do(ctx, "u", send, retryAfter(time.Minute)); err != nil || calls != 1 {
+		t.Errorf("long wait: calls=%d err=%v, want no retry", calls, err)
+	}
+}
+
+func TestTransientError(t *testing.T) {
+	wrap := func(err error) error { return &url.Error{Op: "Get", URL: "https://example.com", Err: err} }
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"timeout", wrap(&net.OpError{Op: "dial", Err: timeoutErr{}}), true},
+		{"connection reset", wrap(&net.OpError{Op: "read", Err: syscall.ECONNRESET}), true},
+		{"connection refused", wrap(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}), true},
+		{"body cut short", wrap(io.ErrUnexpectedEOF), true},
+		{"unknown host", wrap(&net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}), false},
+		{"bad certificate", wrap(errors.New("tls: failed to verify certificate")), false},
+		{"canceled", wrap(context.Canceled), false},
+		{"other", fmt.Errorf("decode: %w", errors.New("bad json")), false},
+	} {
+		if got := TransientError(tc.err); got != tc.want {
+			t.Errorf("%s: TransientError = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestRepoFilterMatch(t *testing.T) {
+	f := RepoFilter{Include: []string{"yuanrong*", "Ray-Adapter", "o/tools-*"}, Exclude: []string{"*-archive", "o/yuanrong-test*"}}
+	for _, tt := range []struct {
+		owner, name string
+		want        bool
+	}{
+		{"o", "yuanrong", true},
+		{"other", "yuanrong-core", true},
+		{"o", "ray-adapter", true},
+		{"O", "YuanRong", true},
+		{"o", "ray", false},
+		{"o", "tools-cli", true},
+		{"O", "Tools-cli", true},
+		{"other", "tools-cli", false},
+		{"o", "yuanrong-archive", false},
+		{"o", "yuanrong-test-1", false},
+		{"other", "yuanrong-test-1", true},
+	} {
+		if got := f.Match(tt.owner, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.owner, tt.name, got, tt.want)
+		}
+	}
+	if (RepoFilter{Exclude: []string{"x"}}).Match("o", "r") {
+		t.Error("empty Include matched")
+	}
+}
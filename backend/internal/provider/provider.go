@@ -0,0 +1,281 @@
+// Package provider defines what the syncer needs from a code host, so GitCode,
+// GitHub and others can be synced the same way.
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Provider lists issues, PRs, comments and repos on one code host. kind is
+// "issue" or "pr" wherever it appears.
+type Provider interface {
+	// Name is the provider id stored on tracked repos, e.g. "gitcode".
+	Name() string
+	ListIssues(ctx context.Context, owner, repo string, opts ListOptions) (ListResult, error)
+	ListPulls(ctx context.Context, owner, repo string, opts ListOptions) (ListResult, error)
+	GetIssue(ctx context.Context, owner, repo, number string) (Item, error)
+	// GetPull returns a single PR, including the size fields lists may omit.
+	GetPull(ctx context.Context, owner, repo, number string) (Item, error)
+	// ListComments returns every comment on an item. When paging stops at
+	// the page limit it returns the comments fetched so far with an error
+	// wrapping ErrTruncated.
+	ListComments(ctx context.Context, owner, repo, kind, number string) ([]Comment, error)
+	// ListRepos returns owner's repos (org or user) that pass filter. Like
+	// ListComments, a listing cut at the page limit comes back with an error
+	// wrapping ErrTruncated.
+	ListRepos(ctx context.Context, owner string, filter RepoFilter) ([]Repo, error)
+	// Quota is the rate-limit state from the most recent response.
+	Quota() Quota
+}
+
+// ErrTruncated reports a listing that stopped at the page limit while the
+// host still had more.
+var ErrTruncated = errors.New("listing truncated at page limit")
+
+// ListOptions narrows a list request. A zero value lists everything.
+type ListOptions struct {
+	// Since limits the result to items updated at or after this timestamp
+	// (RFC3339). Such listings are paged oldest update first where the host
+	// allows it, so a truncated one can be resumed from ListResult.Resume.
+	Since string
+	// MaxPages is a safety limit on pages fetched; 0 means the provider default.
+	MaxPages int
+	// Cache, when set, makes list pages conditional requests: a page the host
+	// answers with 304 is skipped, so its items are missing from the result.
+	// Leave it nil when the listing has to be complete. Providers that do not
+	// support it ignore it.
+	Cache PageCache
+	// BeforePage, when set, runs before each page request, e.g. to wait for
+	// the rate limit; an error ends the listing with that error.
+	BeforePage func(ctx context.Context) error
+}
+
+// PageCache looks up the validators saved for a page URL.
+type PageCache interface {
+	Lookup(ctx context.Context, url string) (PageValidators, bool)
+}
+
+// PageValidators are the ETag/Last-Modified a host sent for one list page,
+// plus the next page's URL ("" on the last page) so paging can go on after a
+// 304, which carries no body and often no Link header.
+type PageValidators struct {
+	URL          string
+	ETag         string
+	LastModified string
+	Next         string
+}
+
+// ListResult is one fully paged listing.
+type ListResult struct {
+	Items []Item
+	Pages int
+	// Truncated reports that paging stopped at ListOptions.MaxPages while the host still had more.
+	Truncated bool
+	// Resume is set on a truncated Since listing that was paged oldest
+	// update first: every item updated before it has been fetched, so the
+	// next listing can start there. Empty when the listing cannot be resumed.
+	Resume string
+	// CacheHits counts pages answered 304 and CacheMisses the pages that
+	// came back with data; both stay 0 without ListOptions.Cache.
+	CacheHits   int
+	CacheMisses int
+	// Validators are the fresh validators of the pages fetched. The caller
+	// saves them once the items are stored, so a failed write never leaves a
+	// page that looks unchanged.
+	Validators []PageValidators
+}
+
+type Item struct {
+	Key string
+	// ID is the host's global id, used to recognise an item moved to another repo.
+	ID        string
+	Title     string
+	State     string
+	URL       string
+	Author    string
+	CreatedAt string
+	UpdatedAt string
+	Body      string
+	Labels    []Label
+	Assignees []string
+	Reviewers []string
+	Milestone string
+	ClosedAt  string
+	MergedAt  string
+	Comments  int
+	IssueType string
+	// PR is set for pull requests only.
+	PR *PRMeta
+}
+
+type Label struct {
+	Name        string
+	Color       string
+	Description string
+}
+
+type PRMeta struct {
+	MergedBy     string
+	Draft        bool
+	HeadBranch   string
+	BaseBranch   string
+	Additions    int
+	Deletions    int
+	ChangedFiles int
+	// HasSize reports whether Additions/Deletions/ChangedFiles came back at all.
+	HasSize   bool
+	Mergeable *bool
+}
+
+type Comment struct {
+	ID        string
+	Author    string
+	Body      string
+	CreatedAt string
+	UpdatedAt string
+}
+
+type Repo struct {
+	Owner    string
+	Name     string
+	Archived bool
+}
+
+func (r Repo) FullName() string { return r.Owner + "/" + r.Name }
+
+// RepoFilter selects repos by glob (path.Match syntax). A pattern containing
+// "/" is matched against "owner/name", otherwise against the name alone.
+// An empty Include matches nothing.
+type RepoFilter struct {
+	Include []string
+	Exclude []string
+}
+
+func (f RepoFilter) Match(owner, name string) bool {
+	return matchAny(f.Include, owner, name) && !matchAny(f.Exclude, owner, name)
+}
+
+func matchAny(patterns []string, owner, name string) bool {
+	for _, p := range patterns {
+		target := name
+		if strings.Contains(p, "/") {
+			target = owner + "/" + name
+		}
+		if ok, _ := path.Match(strings.ToLower(p), strings.ToLower(target)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Quota is the rate-limit state reported by the most recent response.
+type Quota struct {
+	Known     bool      `json:"known"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// HTTPError is a non-2xx response that was not (or no longer) retried.
+type HTTPError struct {
+	Provider string
+	URL      string
+	Status   int
+	Body     string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s: status=%d body=%s", e.Provider, e.URL, e.Status, e.Body)
+}
+
+// StatusCode returns the HTTP status behind err, or 0 if err is not an HTTPError.
+func StatusCode(err error) int {
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he.Status
+	}
+	return 0
+}
+
+// LatestUpdatedAt returns the newest UpdatedAt among items, as sent by the host.
+func LatestUpdatedAt(items []Item) string {
+	latest, latestT := "", time.Time{}
+	for _, it := range items {
+		t, ok := ParseTime(it.UpdatedAt)
+		if !ok {
+			continue
+		}
+		if latest == "" || t.After(latestT) {
+			latest, latestT = it.UpdatedAt, t
+		}
+	}
+	return latest
+}
+
+// UpdateOrder checks that the pages of a listing come back sorted by
+// UpdatedAt, oldest first when Asc is set and newest first otherwise. Hosts
+// do not always honor the sort they are asked for, and paging shortcuts that
+// rely on it are only safe while it holds.
+type UpdateOrder struct {
+	Asc    bool
+	last   time.Time
+	broken bool
+}
+
+// Add checks the next page's items and reports whether the listing is
+// still in order. An UpdatedAt that does not parse breaks the order.
+func (o *UpdateOrder) Add(items []Item) bool {
+	for _, it := range items {
+		if o.broken {
+			break
+		}
+		t, ok := ParseTime(it.UpdatedAt)
+		switch {
+		case !ok:
+			o.broken = true
+		case !o.last.IsZero() && (o.Asc && t.Before(o.last) || !o.Asc && t.After(o.last)):
+			o.broken = true
+		default:
+			o.last = t
+		}
+	}
+	return o.Sorted()
+}
+
+// Sorted reports whether every page added so far was in order.
+func (o *UpdateOrder) Sorted() bool { return !o.broken }
+
+// ParseTime accepts the timestamp layouts code hosts send.
+func ParseTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05Z0700", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Message is the host's error message from the response body when it is
+// JSON with a usual message field, else the raw body.
+func (e *HTTPError) Message() string {
+	var body struct {
+		Message      string `json:"message"`
+		ErrorMessage string `json:"error_message"`
+		Error        string `json:"error"`
+		Msg          string `json:"msg"`
+	}
+	if json.Unmarshal([]byte(e.Body), &body) == nil {
+		for _, m := range []string{body.Message, body.ErrorMessage, body.Error, body.Msg} {
+			if m != "" {
+				return m
+			}
+		}
+	}
+	return e.Body
+}
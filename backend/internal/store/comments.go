@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Comment struct {
+	ID        string `json:"id"`
+	Author    string `json:"author"`
+	Body      string `json:"body"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// ReplaceComments stores the full upstream comment list for an item and
+// refreshes its last_comment_at.
+func (s *Store) ReplaceComments(ctx context.Context, kind, repoFullName, externalKey string, comments []Comment) error {
+	logger := slog.Default().With("component", "store", "op", "replace-comments")
+	start := time.Now()
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	itemID, err := lookupItemID(ctx, tx, kind, repoFullName, externalKey)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE item_id = ?;`, itemID); err != nil {
+		logger.Error("delete comments failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
+		return err
+	}
+	lastCommentAt := ""
+	for _, c := range comments {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR REPLACE INTO comments(item_id, external_id, author, body, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?);`,
+			itemID, c.ID, c.Author, c.Body, c.CreatedAt, c.UpdatedAt,
+		); err != nil {
+			logger.Error("insert comment failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
+			return err
+		}
+		if c.CreatedAt > lastCommentAt {
+			lastCommentAt = c.CreatedAt
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE items SET last_comment_at = ? WHERE id = ?;`, lastCommentAt, itemID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	logger.Debug("replace comments ok", "kind", kind, "repo", repoFullName, "key", externalKey, "count", len(comments), "elapsed_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+func (s *Store) ListComments(ctx context.Context, kind, repoFullName, externalKey string) ([]Comment, error) {
+	logger := slog.Default().With("component", "store", "op", "list-comments")
+
+	itemID, err := lookupItemID(ctx, s.db, kind, repoFullName, externalKey)
+	if err != nil {
+		if !IsNotFound(err) {
+			logger.Error("list comments lookup failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
+		}
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT external_id, author, body, created_at, updated_at FROM comments WHERE item_id = ? ORDER BY created_at, id;`,
+		itemID,
+	)
+	if err != nil {
+		logger.Error("list comments query failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		if err := rows.Scan(&c.ID, &c.Author, &c.Body, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
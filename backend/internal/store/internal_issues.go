@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// openStates are the upstream states that mean the work is not done yet;
+// anything else (closed, merged, rejected) counts as done.
+var openStates = []string{"open", "opened", "reopened", "progressing"}
+
+var openStatesSQL = "'" + strings.Join(openStates, "', '") + "'"
+
+// statusMismatch reports whether upstream and the internal issue disagree:
+// one is done while the other is still open. Items never checked are not
+// flagged.
+func statusMismatch(upstreamState, internalState string) bool {
+	if internalState == "" {
+		return false
+	}
+	upstreamOpen := slices.Contains(openStates, strings.ToLower(upstreamState))
+	return upstreamOpen == (internalState == "closed")
+}
+
+// InternalLink is an item that has an internal tracker issue.
+type InternalLink struct {
+	ItemID       int64
+	IssueID      string
+	Kind         string
+	RepoFullName string
+	ExternalKey  string
+	State        string
+	// InternalState is the last known internal state, "" if never checked.
+	InternalState string
+}
+
+// ListInternalLinks returns up to limit live items with an internal issue,
+// least recently checked first; limit <= 0 means all.
+func (s *Store) ListInternalLinks(ctx context.Context, limit int) ([]InternalLink, error) {
+	logger := slog.Default().With("component", "store", "op", "list-internal-links")
+	if limit <= 0 {
+		limit = -1 // SQLite 里负数 LIMIT 表示不限
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, internal_issue_id, kind, repo_full_name, external_key, state, internal_state
+		FROM items WHERE internal_issue_id <> '' AND tombstoned_at = ''
+		ORDER BY internal_checked_at, id LIMIT ?;`, limit)
+	if err != nil {
+		logger.Error("list internal links failed", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []InternalLink
+	for rows.Next() {
+		var l InternalLink
+		if err := rows.Scan(&l.ItemID, &l.IssueID, &l.Kind, &l.RepoFullName, &l.ExternalKey, &l.State, &l.InternalState); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// SetInternalState records the state ("open" or "closed") the internal
+// tracker reported for an item's issue.
+func (s *Store) SetInternalState(ctx context.Context, itemID int64, state string) error {
+	logger := slog.Default().With("component", "store", "op", "set-internal-state")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE items SET internal_state = ?, internal_checked_at = ? WHERE id = ?;`,
+		state, time.Now().UTC().Format(time.RFC3339), itemID,
+	); err != nil {
+		logger.Error("set internal state failed", "id", itemID, "err", err)
+		return err
+	}
+	return nil
+}
+
+// StatusMismatch reports whether l would be flagged with internal state st.
+func (l InternalLink) StatusMismatch(st string) bool { return statusMismatch(l.State, st) }
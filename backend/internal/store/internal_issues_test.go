@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestStatusMismatchFilterMatchesFlag(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	n := 0
+	for _, state := range []string{"open", "OPEN", "reopened", "progressing", "closed", "merged", "rejected", "Merged"} {
+		for _, internal := range []string{"", "open", "closed"} {
+			n++
+			key := fmt.Sprint(n)
+			it := seedIssue(t, st, key, func(c *CoreItem) { c.State = state })
+			if internal != "" {
+				if err := st.SetInternalState(ctx, it.ID, internal); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	all, err := st.ListItems(ctx, ListFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flagged, err := st.ListItems(ctx, ListFilter{StatusMismatch: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inFilter := map[string]bool{}
+	for _, it := range flagged {
+		inFilter[it.ExternalKey] = true
+	}
+	mismatched := 0
+	for _, it := range all {
+		if it.StatusMismatch != inFilter[it.ExternalKey] {
+			t.Errorf("state=%q internal=%q: flag=%v filter=%v", it.State, it.InternalState, it.StatusMismatch, inFilter[it.ExternalKey])
+		}
+		if it.StatusMismatch {
+			mismatched++
+		}
+	}
+	// 4 个未关闭状态 × 内部已关闭 + 4 个已关闭状态 × 内部未关闭。
+	if mismatched != 8 {
+		t.Errorf("mismatched = %d, want 8", mismatched)
+	}
+}
+
+func TestListInternalLinksLimit(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	for _, key := range []string{"1", "2", "3"} {
+		seedIssue(t, st, key, nil)
+		if _, err := st.PatchCustom(ctx, "issue", "o/r", key, CustomPatch{SyncInternal: ptr(true)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	due, err := st.DueOutbox(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range due {
+		if _, err := st.CompleteOutbox(ctx, e.ID, fmt.Sprint(40+i), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links, err := st.ListInternalLinks(ctx, 2)
+	if err != nil || len(links) != 2 {
+		t.Fatalf("links = %+v err = %v", links, err)
+	}
+	// 回查过的排到后面，下一轮先轮到没查过的。
+	if err := st.SetInternalState(ctx, links[0].ItemID, "open"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetInternalState(ctx, links[1].ItemID, "open"); err != nil {
+		t.Fatal(err)
+	}
+	next, err := st.ListInternalLinks(ctx, 2)
+	if err != nil || len(next) != 2 || next[0].ExternalKey != "3" {
+		t.Errorf("next sweep = %+v err = %v", next, err)
+	}
+	if all, err := st.ListInternalLinks(ctx, 0); err != nil || len(all) != 3 {
+		t.Errorf("unlimited = %d err = %v", len(all), err)
+	}
+}
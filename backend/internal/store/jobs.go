@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+type SyncJob struct {
+	ID      string        `json:"id"`
+	Trigger string        `json:"trigger"` // api|schedule
+	Status  string        `json:"status"`  // running|succeeded|partial|failed
+	Full    bool          `json:"full"`
+	Repos   []SyncJobRepo `json:"repos"`
+	// Discovery is set when the run looked for new repos under an owner.
+	Discovery  *SyncJobDiscovery `json:"discovery,omitempty"`
+	Fetched    int               `json:"fetched"`
+	Upserted   int               `json:"upserted"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  string            `json:"createdAt"`
+	FinishedAt string            `json:"finishedAt,omitempty"`
+}
+
+type SyncJobRepo struct {
+	Repo     string `json:"repo"`
+	Status   string `json:"status"` // pending|running|ok|failed
+	Issues   int    `json:"issues"`
+	PRs      int    `json:"prs"`
+	Comments int    `json:"comments"`
+	Upserted int    `json:"upserted"`
+	// Tombstoned counts items a full sync found missing upstream.
+	Tombstoned int `json:"tombstoned"`
+	// CacheHits counts list pages GitCode answered 304 Not Modified,
+	// CacheMisses the pages it sent in full during an incremental run.
+	CacheHits   int      `json:"cacheHits"`
+	CacheMisses int      `json:"cacheMisses"`
+	ElapsedMs   int64    `json:"elapsedMs"`
+	Warnings    []string `json:"warnings,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+type SyncJobDiscovery struct {
+	Owner   string   `json:"owner"`
+	Matched int      `json:"matched"`
+	Added   []string `json:"added"`
+	// Truncated is set when the listing stopped at the page limit; only the
+	// repos on the pages read were considered.
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (s *Store) SaveSyncJob(ctx context.Context, j SyncJob) error {
+	logger := slog.Default().With("component", "store", "op", "save-sync-job")
+	repos, err := json.Marshal(j.Repos)
+	if err != nil {
+		return err
+	}
+	discovery := ""
+	if j.Discovery != nil {
+		b, err := json.Marshal(j.Discovery)
+		if err != nil {
+			return err
+		}
+		discovery = string(b)
+	}
+	q := `INSERT INTO sync_jobs(id, trigger, status, full, repos, discovery, fetched, upserted, error, created_at, finished_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			status=excluded.status,
+			repos=excluded.repos,
+			discovery=excluded.discovery,
+			fetched=excluded.fetched,
+			upserted=excluded.upserted,
+			error=excluded.error,
+			finished_at=excluded.finished_at;`
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.db.ExecContext(ctx, q,
+		j.ID, j.Trigger, j.Status, boolToInt(j.Full), string(repos), discovery, j.Fetched, j.Upserted, j.Error, j.CreatedAt, j.FinishedAt,
+	); err != nil {
+		logger.Error("save sync job failed", "id", j.ID, "err", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Store) GetSyncJob(ctx context.Context, id string) (SyncJob, error) {
+	logger := slog.Default().With("component", "store", "op", "get-sync-job")
+	q := `SELECT id, trigger, status, full, repos, discovery, fetched, upserted, error, created_at, finished_at
+		FROM sync_jobs WHERE id = ? LIMIT 1;`
+	var j SyncJob
+	var full int
+	var repos, discovery string
+	if err := s.db.QueryRowContext(ctx, q, id).Scan(
+		&j.ID, &j.Trigger, &j.Status, &full, &repos, &discovery, &j.Fetched, &j.Upserted, &j.Error, &j.CreatedAt, &j.FinishedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SyncJob{}, errNotFound
+		}
+		logger.Error("get sync job failed", "id", id, "err", err)
+		return SyncJob{}, err
+	}
+	j.Full = full != 0
+	if err := json.Unmarshal([]byte(repos), &j.Repos); err != nil {
+		logger.Error("decode sync job repos failed", "id", id, "err", err)
+		return SyncJob{}, err
+	}
+	if discovery != "" {
+		j.Discovery = &SyncJobDiscovery{}
+		if err := json.Unmarshal([]byte(discovery), j.Discovery); err != nil {
+			logger.Error("decode sync job discovery failed", "id", id, "err", err)
+			return SyncJob{}, err
+		}
+	}
+	return j, nil
+}
+
+// FailInterruptedSyncJobs marks jobs still "running" from a previous process as failed.
+func (s *Store) FailInterruptedSyncJobs(ctx context.Context) (int, error) {
+	logger := slog.Default().With("component", "store", "op", "fail-interrupted-sync-jobs")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE sync_jobs SET status = 'failed', error = 'interrupted by server restart', finished_at = ?
+		WHERE status = 'running';`,
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		logger.Error("fail interrupted sync jobs failed", "err", err)
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	if n > 0 {
+		logger.Warn("marked interrupted sync jobs failed", "count", n)
+	}
+	return int(n), nil
+}
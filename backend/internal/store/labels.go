@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+)
+
+type Label struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+// setItemLabels replaces the labels on an item, creating or refreshing label rows as needed.
+func setItemLabels(ctx context.Context, tx *sql.Tx, itemID int64, repoFullName string, labels []Label) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM item_labels WHERE item_id = ?;`, itemID); err != nil {
+		return err
+	}
+	// 只有字符串形式的标签时没有颜色/描述，不要用空值覆盖已有信息。
+	q := `INSERT INTO labels(repo_full_name, name, color, description)
+		VALUES(?, ?, ?, ?)
+		ON CONFLICT(repo_full_name, name) DO UPDATE SET
+			color=CASE WHEN excluded.color != '' THEN excluded.color ELSE labels.color END,
+			description=CASE WHEN excluded.description != '' THEN excluded.description ELSE labels.description END
+		RETURNING id;`
+	for _, l := range labels {
+		if l.Name == "" {
+			continue
+		}
+		var labelID int64
+		if err := tx.QueryRowContext(ctx, q, repoFullName, l.Name, l.Color, l.Description).Scan(&labelID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO item_labels(item_id, label_id) VALUES(?, ?);`, itemID, labelID,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachLabels fills Labels on each item from item_labels.
+func (s *Store) attachLabels(ctx context.Context, items []Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+	byID := make(map[int64]int, len(items))
+	ids := make([]any, 0, len(items))
+	for i := range items {
+		byID[items[i].ID] = i
+		ids = append(ids, items[i].ID)
+	}
+
+	// SQLite 默认最多 32766 个参数，分批查询。
+	const batch = 500
+	for start := 0; start < len(ids); start += batch {
+		chunk := ids[start:min(start+batch, len(ids))]
+		q := `SELECT il.item_id, l.name, l.color, l.description
+			FROM item_labels il JOIN labels l ON l.id = il.label_id
+			WHERE il.item_id IN (?` + strings.Repeat(", ?", len(chunk)-1) + `)
+			ORDER BY l.name;`
+		rows, err := s.db.QueryContext(ctx, q, chunk...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int64
+			var l Label
+			if err := rows.Scan(&id, &l.Name, &l.Color, &l.Description); err != nil {
+				rows.Close()
+				return err
+			}
+			if i, ok := byID[id]; ok {
+				items[i].Labels = append(items[i].Labels, l)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) ListLabels(ctx context.Context, repoFullName string) ([]Label, error) {
+	logger := slog.Default().With("component", "store", "op", "list-labels")
+	q := `SELECT DISTINCT name, color, description FROM labels`
+	args := []any{}
+	if repoFullName != "" {
+		q += ` WHERE repo_full_name = ?`
+		args = append(args, repoFullName)
+	}
+	q += ` ORDER BY name;`
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		logger.Error("list labels query failed", "repo", repoFullName, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := []Label{}
+	for rows.Next() {
+		var l Label
+		if err := rows.Scan(&l.Name, &l.Color, &l.Description); err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	return labels, rows.Err()
+}
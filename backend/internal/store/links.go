@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// ItemRef points at an issue by repo and number; the issue may not be synced yet.
+type ItemRef struct {
+	RepoFullName string
+	Key          string
+}
+
+// ItemLink is the other side of an issue↔PR link. Title, State and URL are
+// empty when the linked item has not been synced.
+type ItemLink struct {
+	Kind         string `json:"kind"`
+	RepoFullName string `json:"repoFullName"`
+	Key          string `json:"key"`
+	Title        string `json:"title"`
+	State        string `json:"state"`
+	URL          string `json:"url"`
+	// Relation is "closes" on a PR and "closedBy" on an issue.
+	Relation string `json:"relation"`
+}
+
+// setItemLinks replaces the issues a PR claims to close. Repo names are
+// stored in the casing of the tracked repo, since "Fixes OpenEuler/yuanrong#1"
+// must link to the issues synced under openeuler/yuanrong.
+func setItemLinks(ctx context.Context, tx *sql.Tx, prID int64, refs []ItemRef) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM item_links WHERE pr_item_id = ?;`, prID); err != nil {
+		return err
+	}
+	for _, r := range refs {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO item_links(pr_item_id, issue_repo, issue_key)
+			VALUES(?, COALESCE((SELECT owner || '/' || name FROM repos WHERE owner || '/' || name = ? COLLATE NOCASE), ?), ?);`,
+			prID, r.RepoFullName, r.RepoFullName, r.Key,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachLinks fills Links on each item: closed issues for PRs, closing PRs for issues.
+func (s *Store) attachLinks(ctx context.Context, items []Item) error {
+	prIdx := map[int64]int{}
+	prIDs := []any{}
+	issueIdx := map[ItemRef]int{}
+	issueRefs := []ItemRef{}
+	for i := range items {
+		items[i].Links = []ItemLink{}
+		switch items[i].Kind {
+		case "pr":
+			prIdx[items[i].ID] = i
+			prIDs = append(prIDs, items[i].ID)
+		case "issue":
+			ref := ItemRef{RepoFullName: items[i].RepoFullName, Key: items[i].ExternalKey}
+			issueIdx[ref] = i
+			issueRefs = append(issueRefs, ref)
+		}
+	}
+
+	const batch = 500
+	for start := 0; start < len(prIDs); start += batch {
+		chunk := prIDs[start:min(start+batch, len(prIDs))]
+		q := `SELECT l.pr_item_id, l.issue_repo, l.issue_key, COALESCE(i.title, ''), COALESCE(i.state, ''), COALESCE(i.url, '')
+			FROM item_links l
+			LEFT JOIN items i ON i.kind = 'issue' AND i.repo_full_name = l.issue_repo AND i.external_key = l.issue_key
+			WHERE l.pr_item_id IN (?` + strings.Repeat(", ?", len(chunk)-1) + `)
+			ORDER BY l.issue_repo, CAST(l.issue_key AS INTEGER);`
+		err := s.scanLinks(ctx, q, chunk, func(rows *sql.Rows) error {
+			var id int64
+			l := ItemLink{Kind: "issue", Relation: "closes"}
+			if err := rows.Scan(&id, &l.RepoFullName, &l.Key, &l.Title, &l.State, &l.URL); err != nil {
+				return err
+			}
+			if i, ok := prIdx[id]; ok {
+				items[i].Links = append(items[i].Links, l)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// 每个 issue 占两个参数，批量减半。
+	for start := 0; start < len(issueRefs); start += batch / 2 {
+		chunk := issueRefs[start:min(start+batch/2, len(issueRefs))]
+		args := make([]any, 0, 2*len(chunk))
+		for _, r := range chunk {
+			args = append(args, r.RepoFullName, r.Key)
+		}
+		q := `SELECT l.issue_repo, l.issue_key, p.repo_full_name, p.external_key, p.title, p.state, p.url
+			FROM item_links l JOIN items p ON p.id = l.pr_item_id
+			WHERE (l.issue_repo, l.issue_key) IN (VALUES (?, ?)` + strings.Repeat(", (?, ?)", len(chunk)-1) + `)
+			ORDER BY p.repo_full_name, CAST(p.external_key AS INTEGER);`
+		err := s.scanLinks(ctx, q, args, func(rows *sql.Rows) error {
+			var ref ItemRef
+			l := ItemLink{Kind: "pr", Relation: "closedBy"}
+			if err := rows.Scan(&ref.RepoFullName, &ref.Key, &l.RepoFullName, &l.Key, &l.Title, &l.State, &l.URL); err != nil {
+				return err
+			}
+			if i, ok := issueIdx[ref]; ok {
+				items[i].Links = append(items[i].Links, l)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) scanLinks(ctx context.Context, q string, args []any, scan func(*sql.Rows) error) error {
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLinksUseTrackedRepoCasing(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	if _, _, err := st.SaveRepo(ctx, Repo{Provider: "gitcode", Owner: "openeuler", Name: "yuanrong", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	seedIssue(t, st, "1", func(c *CoreItem) { c.RepoFullName = "openeuler/yuanrong" })
+	seedIssue(t, st, "2", func(c *CoreItem) {
+		c.Kind = "pr"
+		c.Closes = []ItemRef{{RepoFullName: "OpenEuler/YuanRong", Key: "1"}, {RepoFullName: "Other/Repo", Key: "3"}}
+	})
+
+	items, err := st.ListItems(ctx, ListFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range items {
+		switch it.Kind {
+		case "issue":
+			if len(it.Links) != 1 || it.Links[0].Key != "2" {
+				t.Errorf("issue links = %+v", it.Links)
+			}
+		case "pr":
+			// 未跟踪的仓库保留原写法。
+			if len(it.Links) != 2 || it.Links[0].RepoFullName != "Other/Repo" ||
+				it.Links[1].RepoFullName != "openeuler/yuanrong" || it.Links[1].Title != "issue 1" {
+				t.Errorf("pr links = %+v", it.Links)
+			}
+		}
+	}
+}
+
+func TestMigrateFixesLinkCasing(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	if _, _, err := st.SaveRepo(ctx, Repo{Provider: "gitcode", Owner: "o", Name: "r", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	pr := seedIssue(t, st, "2", func(c *CoreItem) { c.Kind = "pr" })
+	// 早先按原写法存下的关联，其中一条改名后会和已有的重复。
+	if _, err := st.db.ExecContext(ctx,
+		`INSERT INTO item_links(pr_item_id, issue_repo, issue_key) VALUES(?, 'O/R', '1'), (?, 'o/r', '1'), (?, 'O/r', '3');`,
+		pr.ID, pr.ID, pr.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := st.db.QueryContext(ctx, `SELECT issue_repo || '#' || issue_key FROM item_links ORDER BY issue_key;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ref)
+	}
+	if len(got) != 2 || got[0] != "o/r#1" || got[1] != "o/r#3" {
+		t.Errorf("links = %v", got)
+	}
+}
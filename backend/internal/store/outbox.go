@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// OutboxEntry is a pending request to create an internal tracker issue for
+// an item. Entries are written by PatchCustom in the same transaction that
+// turns syncInternal on, and worked off by the outbox worker.
+type OutboxEntry struct {
+	ID        int64
+	Attempts  int
+	CreatedAt string
+	Item      Item
+}
+
+func enqueueOutbox(ctx context.Context, tx *sql.Tx, itemID int64) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO internal_outbox(item_id, status, next_attempt_at, created_at, updated_at)
+		SELECT ?, 'pending', ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM internal_outbox WHERE item_id = ? AND status = 'pending');`,
+		itemID, now, now, now, itemID,
+	)
+	return err
+}
+
+// cancelOutbox drops the pending entry of an item whose syncInternal was
+// turned off before the issue got created.
+func cancelOutbox(ctx context.Context, tx *sql.Tx, itemID int64) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE internal_outbox SET status = 'canceled', updated_at = ? WHERE item_id = ? AND status = 'pending';`,
+		time.Now().UTC().Format(time.RFC3339), itemID,
+	)
+	return err
+}
+
+// DueOutbox returns up to limit pending entries whose next attempt is due,
+// oldest first, with their item loaded.
+func (s *Store) DueOutbox(ctx context.Context, limit int) ([]OutboxEntry, error) {
+	logger := slog.Default().With("component", "store", "op", "due-outbox")
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, item_id, attempts, created_at FROM internal_outbox
+		WHERE status = 'pending' AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id LIMIT ?;`,
+		time.Now().UTC().Format(time.RFC3339), limit,
+	)
+	if err != nil {
+		logger.Error("due outbox query failed", "err", err)
+		return nil, err
+	}
+	var entries []OutboxEntry
+	var itemIDs []int64
+	for rows.Next() {
+		var e OutboxEntry
+		var itemID int64
+		if err := rows.Scan(&e.ID, &itemID, &e.Attempts, &e.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		entries = append(entries, e)
+		itemIDs = append(itemIDs, itemID)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT ` + itemColumns + ` FROM items WHERE id = ?;`
+	for i := range entries {
+		it, err := scanItem(s.db.QueryRowContext(ctx, q, itemIDs[i]))
+		if err != nil {
+			logger.Error("due outbox item failed", "id", entries[i].ID, "err", err)
+			return nil, err
+		}
+		entries[i].Item = it
+	}
+	return entries, nil
+}
+
+// CompleteOutbox marks entry id done and stores the created issue on its item.
+// An entry canceled while the issue was being created stays canceled, but the
+// issue is still stored on the item: it exists upstream, and turning
+// syncInternal back on must not open a second one. canceled reports that case.
+// errNotFound means the entry is neither pending nor canceled.
+func (s *Store) CompleteOutbox(ctx context.Context, id int64, issueID, issueURL string) (canceled bool, err error) {
+	logger := slog.Default().With("component", "store", "op", "complete-outbox")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var itemID int64
+	var status string
+	err = tx.QueryRowContext(ctx,
+		`SELECT item_id, status FROM internal_outbox WHERE id = ? AND status IN ('pending', 'canceled');`, id,
+	).Scan(&itemID, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, errNotFound
+	}
+	if err != nil {
+		logger.Error("complete outbox read failed", "id", id, "err", err)
+		return false, err
+	}
+	canceled = status == "canceled"
+	if !canceled {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE internal_outbox SET status = 'done', last_error = '', updated_at = ? WHERE id = ?;`,
+			time.Now().UTC().Format(time.RFC3339), id,
+		); err != nil {
+			logger.Error("complete outbox failed", "id", id, "err", err)
+			return false, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE items SET internal_issue_id = ?, internal_issue_url = ? WHERE id = ?;`,
+		issueID, issueURL, itemID,
+	); err != nil {
+		logger.Error("complete outbox item failed", "id", id, "err", err)
+		return false, err
+	}
+	return canceled, tx.Commit()
+}
+
+// RetryOutbox records a failed attempt on entry id. The entry is tried again
+// at next, or marked failed for good when next is zero. Entries canceled in
+// the meantime stay canceled.
+func (s *Store) RetryOutbox(ctx context.Context, id int64, errMsg string, next time.Time) error {
+	logger := slog.Default().With("component", "store", "op", "retry-outbox")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	now := time.Now().UTC()
+	status, nextAt := "pending", next.UTC().Format(time.RFC3339)
+	if next.IsZero() {
+		status, nextAt = "failed", now.Format(time.RFC3339)
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE internal_outbox SET status = ?, attempts = attempts + 1, next_attempt_at = ?, last_error = ?, updated_at = ?
+		WHERE id = ? AND status = 'pending';`,
+		status, nextAt, errMsg, now.Format(time.RFC3339), id,
+	); err != nil {
+		logger.Error("retry outbox failed", "id", id, "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPatchCustomQueuesOutbox(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	seedIssue(t, st, "1", nil)
+
+	on := CustomPatch{SyncInternal: ptr(true)}
+	if _, err := st.PatchCustom(ctx, "issue", "o/r", "1", on); err != nil {
+		t.Fatal(err)
+	}
+	// 已经打开时再打开一次不会重复入队。
+	if _, err := st.PatchCustom(ctx, "issue", "o/r", "1", on); err != nil {
+		t.Fatal(err)
+	}
+	due, err := st.DueOutbox(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].Item.ExternalKey != "1" || due[0].Attempts != 0 {
+		t.Fatalf("due = %+v", due)
+	}
+
+	if _, err := st.PatchCustom(ctx, "issue", "o/r", "1", CustomPatch{SyncInternal: ptr(false)}); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := st.DueOutbox(ctx, 10); err != nil || len(due) != 0 {
+		t.Fatalf("due after cancel = %+v err = %v", due, err)
+	}
+}
+
+func TestDueOutboxWaitsForNextAttempt(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	seedIssue(t, st, "1", nil)
+	seedIssue(t, st, "2", nil)
+	for _, key := range []string{"1", "2"} {
+		if _, err := st.PatchCustom(ctx, "issue", "o/r", key, CustomPatch{SyncInternal: ptr(true)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	due, err := st.DueOutbox(ctx, 10)
+	if err != nil || len(due) != 2 {
+		t.Fatalf("due = %+v err = %v", due, err)
+	}
+	if err := st.RetryOutbox(ctx, due[0].ID, "503", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.RetryOutbox(ctx, due[1].ID, "400", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := st.DueOutbox(ctx, 10); err != nil || len(due) != 0 {
+		t.Errorf("due = %+v err = %v, want the retried entry to wait and the failed one gone", due, err)
+	}
+}
+
+func TestCompleteOutboxAfterCancel(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	seedIssue(t, st, "1", nil)
+	seedIssue(t, st, "2", nil)
+	for _, key := range []string{"1", "2"} {
+		if _, err := st.PatchCustom(ctx, "issue", "o/r", key, CustomPatch{SyncInternal: ptr(true)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	due, err := st.DueOutbox(ctx, 10)
+	if err != nil || len(due) != 2 {
+		t.Fatalf("due = %+v err = %v", due, err)
+	}
+
+	// 第 1 条在创建过程中被取消。
+	if _, err := st.PatchCustom(ctx, "issue", "o/r", "1", CustomPatch{SyncInternal: ptr(false)}); err != nil {
+		t.Fatal(err)
+	}
+	if canceled, err := st.CompleteOutbox(ctx, due[0].ID, "41", "https://it/41"); err != nil || !canceled {
+		t.Errorf("complete canceled entry: canceled = %v err = %v", canceled, err)
+	}
+	if err := st.RetryOutbox(ctx, due[0].ID, "late", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if canceled, err := st.CompleteOutbox(ctx, due[1].ID, "42", "https://it/42"); err != nil || canceled {
+		t.Fatalf("complete: canceled = %v err = %v", canceled, err)
+	}
+
+	items, err := st.ListItems(ctx, ListFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, it := range items {
+		got[it.ExternalKey] = it.InternalIssueID
+	}
+	// 已建好的单仍记在被取消的条目上。
+	if got["1"] != "41" || got["2"] != "42" {
+		t.Errorf("internal issue ids = %v", got)
+	}
+	if due, err := st.DueOutbox(ctx, 10); err != nil || len(due) != 0 {
+		t.Errorf("due = %+v err = %v, want the canceled entry to stay canceled", due, err)
+	}
+
+	// 重新打开 syncInternal 不会再排一次建单。
+	if _, err := st.PatchCustom(ctx, "issue", "o/r", "1", CustomPatch{SyncInternal: ptr(true)}); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := st.DueOutbox(ctx, 10); err != nil || len(due) != 0 {
+		t.Errorf("due after re-enable = %+v err = %v", due, err)
+	}
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	neturl "net/url"
+	"time"
+)
+
+// PageCacheEntry holds the validators of one list page response, so the
+// next sync can ask GitCode whether the page changed. Entries are keyed by
+// the URL without its since parameter, so each listing keeps one row per
+// page however often its watermark moves; a lookup with a different since
+// is a miss.
+type PageCacheEntry struct {
+	URL          string
+	ETag         string
+	LastModified string
+	NextURL      string
+}
+
+// GetPageCache returns the validators saved for url, or errNotFound.
+func (s *Store) GetPageCache(ctx context.Context, url string) (PageCacheEntry, error) {
+	logger := slog.Default().With("component", "store", "op", "get-page-cache")
+	key, since := pageCacheKey(url)
+	e := PageCacheEntry{URL: url}
+	var cachedSince string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT etag, last_modified, next_url, since FROM http_cache WHERE url = ?;`, key,
+	).Scan(&e.ETag, &e.LastModified, &e.NextURL, &cachedSince)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && cachedSince != since) {
+		return PageCacheEntry{}, errNotFound
+	}
+	if err != nil {
+		logger.Error("get page cache failed", "url", url, "err", err)
+		return PageCacheEntry{}, err
+	}
+	return e, nil
+}
+
+// pageCacheKey splits a page URL into its cache key and since value.
+func pageCacheKey(rawURL string) (key, since string) {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return rawURL, ""
+	}
+	q := u.Query()
+	since = q.Get("since")
+	q.Del("since")
+	u.RawQuery = q.Encode()
+	return u.String(), since
+}
+
+// SavePageCache upserts validators in one transaction. Callers save them only
+// after the pages' items are stored; otherwise a 304 next time would skip
+// data that never made it into the database.
+func (s *Store) SavePageCache(ctx context.Context, entries []PageCacheEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	logger := slog.Default().With("component", "store", "op", "save-page-cache")
+	now := time.Now().UTC().Format(time.RFC3339)
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("begin tx failed", "err", err)
+		return err
+	}
+	defer tx.Rollback()
+	for _, e := range entries {
+		key, since := pageCacheKey(e.URL)
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO http_cache(url, since, etag, last_modified, next_url, updated_at)
+			VALUES(?, ?, ?, ?, ?, ?)
+			ON CONFLICT(url) DO UPDATE SET
+				since=excluded.since,
+				etag=excluded.etag,
+				last_modified=excluded.last_modified,
+				next_url=excluded.next_url,
+				updated_at=excluded.updated_at;`,
+			key, since, e.ETag, e.LastModified, e.NextURL, now,
+		); err != nil {
+			logger.Error("save page cache failed", "url", e.URL, "err", err)
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("commit failed", "err", err)
+		return err
+	}
+	logger.Debug("save page cache ok", "count", len(entries))
+	return nil
+}
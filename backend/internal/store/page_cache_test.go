@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPageCacheKeepsOneRowPerPage(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	page := func(since string) string {
+		return "https://api.gitcode.com/api/v5/repos/o/r/issues?direction=asc&page=1&per_page=100&since=" + since + "&sort=updated&state=all"
+	}
+
+	for _, since := range []string{"2024-01-01T00:00:00Z", "2024-02-01T00:00:00Z", "2024-03-01T00:00:00Z"} {
+		if err := st.SavePageCache(ctx, []PageCacheEntry{{URL: page(since), ETag: `W/"` + since + `"`}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var rows int
+	if err := st.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM http_cache;`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("rows = %d, want one per page whatever the since", rows)
+	}
+
+	e, err := st.GetPageCache(ctx, page("2024-03-01T00:00:00Z"))
+	if err != nil || e.ETag != `W/"2024-03-01T00:00:00Z"` || e.URL != page("2024-03-01T00:00:00Z") {
+		t.Errorf("entry = %+v err = %v", e, err)
+	}
+	// 水位变了就是另一份列表，旧的 ETag 不能拿来用。
+	if _, err := st.GetPageCache(ctx, page("2024-01-01T00:00:00Z")); !IsNotFound(err) {
+		t.Errorf("lookup with an older since: err = %v, want not found", err)
+	}
+}
@@ -0,0 +1,225 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Repo is a tracked repository and its sync options.
+type Repo struct {
+	// Provider names the upstream the repo is synced from ("gitcode", ...).
+	Provider      string `json:"provider"`
+	Owner         string `json:"owner"`
+	Name          string `json:"name"`
+	Enabled       bool   `json:"enabled"`
+	SyncComments  bool   `json:"syncComments"`
+	SyncPRDetails bool   `json:"syncPrDetails"`
+	// Interval overrides SYNC_INTERVAL for scheduled syncs, as a Go duration
+	// ("5m"); "" uses the default and "0" leaves the repo to manual syncs.
+	Interval  string `json:"interval"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+func (r Repo) FullName() string { return r.Owner + "/" + r.Name }
+
+const repoColumns = `provider, owner, name, enabled, sync_comments, sync_pr_details, interval, created_at, updated_at`
+
+func scanRepo(row rowScanner) (Repo, error) {
+	var r Repo
+	var enabled, comments, details int
+	if err := row.Scan(&r.Provider, &r.Owner, &r.Name, &enabled, &comments, &details, &r.Interval, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return Repo{}, err
+	}
+	r.Enabled, r.SyncComments, r.SyncPRDetails = enabled != 0, comments != 0, details != 0
+	return r, nil
+}
+
+// ListRepos returns tracked repos ordered by owner and name, optionally only enabled ones.
+func (s *Store) ListRepos(ctx context.Context, enabledOnly bool) ([]Repo, error) {
+	logger := slog.Default().With("component", "store", "op", "list-repos")
+	q := `SELECT ` + repoColumns + ` FROM repos`
+	if enabledOnly {
+		q += ` WHERE enabled = 1`
+	}
+	q += ` ORDER BY owner, name;`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		logger.Error("list repos query failed", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	repos := []Repo{}
+	for rows.Next() {
+		r, err := scanRepo(rows)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, r)
+	}
+	return repos, rows.Err()
+}
+
+// GetRepo looks a repo up; owner and name compare case-insensitively, as GitCode paths do.
+// owner/name is unique across providers, since items are keyed by it too.
+func (s *Store) GetRepo(ctx context.Context, owner, name string) (Repo, error) {
+	r, err := scanRepo(s.db.QueryRowContext(ctx,
+		`SELECT `+repoColumns+` FROM repos WHERE owner = ? AND name = ?;`, owner, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Repo{}, errNotFound
+	}
+	return r, err
+}
+
+// SaveRepo creates the repo or updates its options; created reports which.
+func (s *Store) SaveRepo(ctx context.Context, r Repo) (Repo, bool, error) {
+	logger := slog.Default().With("component", "store", "op", "save-repo")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	_, err := s.GetRepo(ctx, r.Owner, r.Name)
+	if err != nil && !IsNotFound(err) {
+		return Repo{}, false, err
+	}
+	created := IsNotFound(err)
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var createdAt string
+	if err := s.db.QueryRowContext(ctx,
+		`INSERT INTO repos(provider, owner, name, enabled, sync_comments, sync_pr_details, interval, created_at, updated_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(owner, name) DO UPDATE SET
+			provider=excluded.provider,
+			enabled=excluded.enabled,
+			sync_comments=excluded.sync_comments,
+			sync_pr_details=excluded.sync_pr_details,
+			interval=excluded.interval,
+			updated_at=excluded.updated_at
+		RETURNING created_at;`,
+		r.Provider, r.Owner, r.Name, boolToInt(r.Enabled), boolToInt(r.SyncComments), boolToInt(r.SyncPRDetails), r.Interval, now, now,
+	).Scan(&createdAt); err != nil {
+		logger.Error("save repo failed", "repo", r.FullName(), "err", err)
+		return Repo{}, false, err
+	}
+	r.CreatedAt, r.UpdatedAt = createdAt, now
+	logger.Info("save repo ok", "repo", r.FullName(), "created", created)
+	return r, created, nil
+}
+
+// DeleteRepo stops tracking a repo. Its items stay in the store.
+func (s *Store) DeleteRepo(ctx context.Context, owner, name string) error {
+	logger := slog.Default().With("component", "store", "op", "delete-repo")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM repos WHERE owner = ? AND name = ?;`, owner, name)
+	if err != nil {
+		logger.Error("delete repo failed", "repo", owner+"/"+name, "err", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNotFound
+	}
+	logger.Info("delete repo ok", "repo", owner+"/"+name)
+	return nil
+}
+
+// SeedRepos fills the repos table the first time the server starts. Later
+// starts leave it alone, even if every repo has since been deleted.
+func (s *Store) SeedRepos(ctx context.Context, repos []Repo) (int, error) {
+	logger := slog.Default().With("component", "store", "op", "seed-repos")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO app_meta(key, value) VALUES('repos_seeded', ?);`,
+		time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		logger.Debug("seed repos skipped", "reason", "already seeded")
+		return 0, nil
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	count := 0
+	for _, r := range repos {
+		res, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO repos(provider, owner, name, enabled, sync_comments, sync_pr_details, interval, created_at, updated_at)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+			r.Provider, r.Owner, r.Name, boolToInt(r.Enabled), boolToInt(r.SyncComments), boolToInt(r.SyncPRDetails), r.Interval, now, now,
+		)
+		if err != nil {
+			logger.Error("seed repo failed", "repo", r.FullName(), "err", err)
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		count += int(n)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	logger.Info("seed repos ok", "count", count)
+	return count, nil
+}
+
+// AddDiscoveredRepos tracks the repos discovery has not seen before and
+// returns the ones it added. A repo is only ever auto-added once, so one
+// removed with DeleteRepo stays removed; repos already tracked are just
+// marked as seen.
+func (s *Store) AddDiscoveredRepos(ctx context.Context, repos []Repo) ([]Repo, error) {
+	logger := slog.Default().With("component", "store", "op", "add-discovered-repos")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	added := []Repo{}
+	for _, r := range repos {
+		res, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO discovered_repos(owner, name, first_seen_at) VALUES(?, ?, ?);`, r.Owner, r.Name, now)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		res, err = tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO repos(provider, owner, name, enabled, sync_comments, sync_pr_details, interval, created_at, updated_at)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+			r.Provider, r.Owner, r.Name, boolToInt(r.Enabled), boolToInt(r.SyncComments), boolToInt(r.SyncPRDetails), r.Interval, now, now,
+		)
+		if err != nil {
+			logger.Error("add discovered repo failed", "repo", r.FullName(), "err", err)
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			r.CreatedAt, r.UpdatedAt = now, now
+			added = append(added, r)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if len(added) > 0 {
+		logger.Info("add discovered repos ok", "count", len(added))
+	}
+	return added, nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSeedReposRunsOnce(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	seed := []Repo{
+		{Provider: "gitcode", Owner: "o", Name: "a", Enabled: true},
+		{Provider: "gitcode", Owner: "o", Name: "b", Enabled: true, Interval: "5m"},
+	}
+
+	if n, err := st.SeedRepos(ctx, seed); err != nil || n != 2 {
+		t.Fatalf("first seed = %d err = %v, want 2", n, err)
+	}
+	// 用户删掉的仓库在下次启动时不会被种子重新加回来。
+	if err := st.DeleteRepo(ctx, "o", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := st.SeedRepos(ctx, append(seed, Repo{Provider: "gitcode", Owner: "o", Name: "c", Enabled: true})); err != nil || n != 0 {
+		t.Fatalf("second seed = %d err = %v, want 0", n, err)
+	}
+	repos, err := st.ListRepos(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || repos[0].FullName() != "o/b" || repos[0].Interval != "5m" {
+		t.Errorf("repos = %+v, want only o/b", repos)
+	}
+
+	// 删光之后也一样。
+	if err := st.DeleteRepo(ctx, "o", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := st.SeedRepos(ctx, seed); err != nil || n != 0 {
+		t.Errorf("seed after deleting everything = %d err = %v, want 0", n, err)
+	}
+}
+
+func TestAddDiscoveredRepos(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	if _, err := st.SeedRepos(ctx, []Repo{{Provider: "gitcode", Owner: "o", Name: "a", Enabled: true}}); err != nil {
+		t.Fatal(err)
+	}
+	found := []Repo{
+		{Provider: "gitcode", Owner: "o", Name: "a", Enabled: true},
+		{Provider: "gitcode", Owner: "o", Name: "b", Enabled: true, SyncComments: true},
+	}
+
+	// 已经跟踪的 o/a 只记为见过，不算新加入。
+	added, err := st.AddDiscoveredRepos(ctx, found)
+	if err != nil || len(added) != 1 || added[0].FullName() != "o/b" {
+		t.Fatalf("first discovery = %+v err = %v, want o/b", added, err)
+	}
+	if added, err := st.AddDiscoveredRepos(ctx, found); err != nil || len(added) != 0 {
+		t.Fatalf("second discovery = %+v err = %v, want nothing", added, err)
+	}
+
+	// 用户删掉的仓库不会被再次发现加回来，o/a 也一样。
+	for _, name := range []string{"a", "b"} {
+		if err := st.DeleteRepo(ctx, "o", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if added, err := st.AddDiscoveredRepos(ctx, append(found, Repo{Provider: "gitcode", Owner: "o", Name: "c", Enabled: true})); err != nil ||
+		len(added) != 1 || added[0].FullName() != "o/c" {
+		t.Fatalf("discovery after delete = %+v err = %v, want only o/c", added, err)
+	}
+	repos, err := st.ListRepos(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || repos[0].FullName() != "o/c" || !repos[0].Enabled {
+		t.Errorf("repos = %+v, want only o/c", repos)
+	}
+}
@@ -3,15 +3,23 @@ package store
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
 	"time"
+
+	"modernc.org/sqlite"
 )
 
 type Store struct {
 	db *sql.DB
+	// writeMu serializes writes: SQLite allows a single writer, and concurrent
+	// sync workers would otherwise race into SQLITE_BUSY.
+	writeMu sync.Mutex
 }
 
 func New(db *sql.DB) *Store {
@@ -48,6 +56,129 @@ func (s *Store) Migrate(ctx context.Context) error {
 		`CREATE INDEX IF NOT EXISTS idx_items_kind ON items(kind);`,
 		`CREATE INDEX IF NOT EXISTS idx_items_repo ON items(repo_full_name);`,
 		`CREATE INDEX IF NOT EXISTS idx_items_due ON items(due_at);`,
+		`CREATE TABLE IF NOT EXISTS sync_watermarks (
+			repo_full_name TEXT NOT NULL,        -- owner/repo
+			kind TEXT NOT NULL,                 -- issue|pr
+			updated_at TEXT NOT NULL,            -- newest upstream updated_at seen
+			synced_at TEXT NOT NULL,
+
+			PRIMARY KEY(repo_full_name, kind)
+		);`,
+		`CREATE TABLE IF NOT EXISTS sync_jobs (
+			id TEXT PRIMARY KEY,
+			trigger TEXT NOT NULL,              -- api|schedule
+			status TEXT NOT NULL,               -- running|succeeded|partial|failed
+			full INTEGER NOT NULL DEFAULT 0,
+			repos TEXT NOT NULL DEFAULT '[]',    -- JSON per-repo progress
+			fetched INTEGER NOT NULL DEFAULT 0,
+			upserted INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL,
+			finished_at TEXT NOT NULL DEFAULT ''
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_sync_jobs_created ON sync_jobs(created_at);`,
+		`CREATE TABLE IF NOT EXISTS labels (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			repo_full_name TEXT NOT NULL,        -- owner/repo; GitCode labels are per repo
+			name TEXT NOT NULL,
+			color TEXT NOT NULL DEFAULT '',
+			description TEXT NOT NULL DEFAULT '',
+
+			UNIQUE(repo_full_name, name)
+		);`,
+		`CREATE TABLE IF NOT EXISTS item_labels (
+			item_id INTEGER NOT NULL,            -- items.id
+			label_id INTEGER NOT NULL,           -- labels.id
+
+			PRIMARY KEY(item_id, label_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_item_labels_label ON item_labels(label_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_labels_name ON labels(name);`,
+		`CREATE TABLE IF NOT EXISTS comments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			item_id INTEGER NOT NULL,            -- items.id
+			external_id TEXT NOT NULL,           -- GitCode comment id
+			author TEXT NOT NULL,
+			body TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL,
+
+			UNIQUE(item_id, external_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_item ON comments(item_id, created_at);`,
+		`CREATE TABLE IF NOT EXISTS item_links (
+			pr_item_id INTEGER NOT NULL,         -- items.id of the PR
+			issue_repo TEXT NOT NULL,            -- owner/repo of the referenced issue
+			issue_key TEXT NOT NULL,             -- issue number; the issue may not be synced yet
+
+			PRIMARY KEY(pr_item_id, issue_repo, issue_key)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_item_links_issue ON item_links(issue_repo, issue_key);`,
+		`CREATE TABLE IF NOT EXISTS repos (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			owner TEXT NOT NULL COLLATE NOCASE,
+			name TEXT NOT NULL COLLATE NOCASE,
+			enabled INTEGER NOT NULL DEFAULT 1,
+			sync_comments INTEGER NOT NULL DEFAULT 1,
+			sync_pr_details INTEGER NOT NULL DEFAULT 1,
+			interval TEXT NOT NULL DEFAULT '',   -- Go duration; '' = SYNC_INTERVAL, '0' = manual only
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL,
+
+			UNIQUE(owner, name)
+		);`,
+		`CREATE TABLE IF NOT EXISTS discovered_repos (
+			owner TEXT NOT NULL COLLATE NOCASE,
+			name TEXT NOT NULL COLLATE NOCASE,
+			first_seen_at TEXT NOT NULL,
+
+			PRIMARY KEY(owner, name)
+		);`,
+		`CREATE TABLE IF NOT EXISTS app_meta (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS item_writes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			item_id INTEGER NOT NULL,            -- items.id
+			action TEXT NOT NULL,                -- comment|labels|assignees
+			payload TEXT NOT NULL,               -- JSON request sent upstream
+			status TEXT NOT NULL,                -- pending|ok|failed
+			error TEXT NOT NULL DEFAULT '',
+			upstream_status INTEGER NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL,
+			finished_at TEXT NOT NULL DEFAULT ''
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_item_writes_item ON item_writes(item_id, id);`,
+		`CREATE TABLE IF NOT EXISTS internal_outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			item_id INTEGER NOT NULL,            -- items.id
+			status TEXT NOT NULL,                -- pending|done|failed|canceled
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at TEXT NOT NULL,
+			last_error TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_internal_outbox_due ON internal_outbox(status, next_attempt_at);`,
+		`CREATE TABLE IF NOT EXISTS http_cache (
+			url TEXT PRIMARY KEY,                -- list page URL without since
+			etag TEXT NOT NULL DEFAULT '',
+			last_modified TEXT NOT NULL DEFAULT '',
+			next_url TEXT NOT NULL DEFAULT '',   -- next page as of that response
+			updated_at TEXT NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id TEXT PRIMARY KEY,                 -- delivery id header, or sha256 of the body
+			event TEXT NOT NULL,                 -- issue|pr|comment
+			repo_full_name TEXT NOT NULL,
+			kind TEXT NOT NULL,                  -- issue|pr
+			external_key TEXT NOT NULL,
+			status TEXT NOT NULL,                -- queued|ok|ignored|failed
+			error TEXT NOT NULL DEFAULT '',
+			received_at TEXT NOT NULL,
+			finished_at TEXT NOT NULL DEFAULT ''
+		);`,
 	}
 
 	for _, stmt := range stmts {
@@ -56,33 +187,236 @@ func (s *Store) Migrate(ctx context.Context) error {
 			return fmt.Errorf("migrate exec: %w", err)
 		}
 	}
+
+	// Columns added after the first release; CREATE TABLE IF NOT EXISTS won't add them to old databases.
+	columns := []struct{ table, column, decl string }{
+		{"items", "body", "TEXT NOT NULL DEFAULT ''"},
+		{"items", "upstream_assignees", "TEXT NOT NULL DEFAULT '[]'"}, // JSON array of GitCode logins
+		{"items", "upstream_reviewers", "TEXT NOT NULL DEFAULT '[]'"}, // JSON array of GitCode logins (PRs)
+		{"items", "milestone", "TEXT NOT NULL DEFAULT ''"},
+		{"items", "closed_at", "TEXT NOT NULL DEFAULT ''"},
+		{"items", "merged_at", "TEXT NOT NULL DEFAULT ''"},
+		{"items", "comments_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"items", "issue_type", "TEXT NOT NULL DEFAULT ''"},
+		{"items", "last_comment_at", "TEXT NOT NULL DEFAULT ''"},
+		// PR-only columns; size and mergeable stay NULL until GitCode reports them.
+		{"items", "merged_by", "TEXT NOT NULL DEFAULT ''"},
+		{"items", "draft", "INTEGER NOT NULL DEFAULT 0"},
+		{"items", "head_branch", "TEXT NOT NULL DEFAULT ''"},
+		{"items", "base_branch", "TEXT NOT NULL DEFAULT ''"},
+		{"items", "additions", "INTEGER"},
+		{"items", "deletions", "INTEGER"},
+		{"items", "changed_files", "INTEGER"},
+		{"items", "mergeable", "INTEGER"},
+		{"items", "upstream_id", "TEXT NOT NULL DEFAULT ''"},    // provider:id, e.g. gitcode:123
+		{"items", "tombstoned_at", "TEXT NOT NULL DEFAULT ''"},  // set when a full sync no longer sees the item
+		{"items", "transferred_to", "TEXT NOT NULL DEFAULT ''"}, // owner/repo#key of the row it moved to
+		{"sync_jobs", "discovery", "TEXT NOT NULL DEFAULT ''"},  // JSON repo discovery result
+		{"repos", "provider", "TEXT NOT NULL DEFAULT 'gitcode'"},
+		{"items", "internal_issue_id", "TEXT NOT NULL DEFAULT ''"}, // set by the outbox worker
+		{"items", "internal_issue_url", "TEXT NOT NULL DEFAULT ''"},
+		{"items", "internal_state", "TEXT NOT NULL DEFAULT ''"}, // open|closed, from the status check
+		{"items", "internal_checked_at", "TEXT NOT NULL DEFAULT ''"},
+		{"http_cache", "since", "TEXT NOT NULL DEFAULT ''"}, // since of the cached response
+		{"webhook_deliveries", "attempts", "INTEGER NOT NULL DEFAULT 0"},
+		{"webhook_deliveries", "next_attempt_at", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, c := range columns {
+		if err := s.ensureColumn(ctx, c.table, c.column, c.decl); err != nil {
+			logger.Error("migrate add column failed", "table", c.table, "column", c.column, "err", err)
+			return fmt.Errorf("migrate add column %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	for _, stmt := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_items_base_branch ON items(base_branch);`,
+		`CREATE INDEX IF NOT EXISTS idx_items_upstream_id ON items(upstream_id);`,
+		// GitCode 和 GitHub 的 id 会重复，早先存的裸 id 补上仓库所属平台的前缀。
+		`UPDATE items SET upstream_id = COALESCE(
+				(SELECT provider FROM repos WHERE repos.owner || '/' || repos.name = items.repo_full_name COLLATE NOCASE), 'gitcode'
+			) || ':' || upstream_id
+		WHERE upstream_id != '' AND instr(upstream_id, ':') = 0;`,
+		// 早先的关联按 PR 描述里的大小写存仓库名，改成所跟踪仓库的写法；改名后重复的行删掉。
+		`UPDATE OR IGNORE item_links SET issue_repo = (
+				SELECT owner || '/' || name FROM repos WHERE owner || '/' || name = item_links.issue_repo COLLATE NOCASE
+			)
+		WHERE EXISTS (SELECT 1 FROM repos WHERE owner || '/' || name = item_links.issue_repo COLLATE NOCASE
+			AND owner || '/' || name != item_links.issue_repo);`,
+		`DELETE FROM item_links
+		WHERE EXISTS (SELECT 1 FROM repos WHERE owner || '/' || name = item_links.issue_repo COLLATE NOCASE
+			AND owner || '/' || name != item_links.issue_repo);`,
+		// 早先的缓存按带 since 的完整 URL 存，每次同步都多出一批，清掉即可。
+		`DELETE FROM http_cache WHERE url LIKE '%since=%';`,
+		// 投递改为排队后台处理；以前停在 processing 的记录重新排队。
+		`UPDATE webhook_deliveries SET status = 'queued' WHERE status = 'processing';`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);`,
+	} {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			logger.Error("migrate exec failed", "err", err)
+			return fmt.Errorf("migrate exec: %w", err)
+		}
+	}
 	logger.Info("migrate ok", "elapsed_ms", time.Since(start).Milliseconds())
 	return nil
 }
 
+func (s *Store) ensureColumn(ctx context.Context, table, column, decl string) error {
+	ok, err := s.hasColumn(ctx, table, column)
+	if err != nil || ok {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s;`, table, column, decl))
+	return err
+}
+
+func (s *Store) hasColumn(ctx context.Context, table, column string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?;`, table, column).Scan(&n)
+	return n > 0, err
+}
+
 type Item struct {
-	Kind          string `json:"kind"`
-	RepoFullName  string `json:"repoFullName"`
-	ExternalKey   string `json:"key"`
-	Title         string `json:"title"`
-	State         string `json:"state"`
-	URL           string `json:"url"`
-	Author        string `json:"author"`
-	CreatedAt     string `json:"createdAt"`
-	UpdatedAt     string `json:"updatedAt"`
-	Assignee      string `json:"assignee"`
-	AssigneeGroup string `json:"assigneeGroup"`
-	Note          string `json:"note"`
-	EstimatedAt   string `json:"estimatedResolveAt"`
-	SyncInternal  bool   `json:"syncInternal"`
-	Priority      int    `json:"priority"`
-	DueAt         string `json:"dueAt"`
-	OverdueDays   int    `json:"overdueDays"`
+	ID                int64    `json:"-"`
+	Kind              string   `json:"kind"`
+	RepoFullName      string   `json:"repoFullName"`
+	ExternalKey       string   `json:"key"`
+	Title             string   `json:"title"`
+	State             string   `json:"state"`
+	URL               string   `json:"url"`
+	Author            string   `json:"author"`
+	CreatedAt         string   `json:"createdAt"`
+	UpdatedAt         string   `json:"updatedAt"`
+	Body              string   `json:"body"`
+	Labels            []Label  `json:"labels"`
+	UpstreamAssignees []string `json:"upstreamAssignees"`
+	UpstreamReviewers []string `json:"upstreamReviewers"`
+	Milestone         string   `json:"milestone"`
+	ClosedAt          string   `json:"closedAt"`
+	MergedAt          string   `json:"mergedAt"`
+	CommentsCount     int      `json:"commentsCount"`
+	IssueType         string   `json:"issueType"`
+	LastCommentAt     string   `json:"lastCommentAt"`
+	// LastActivityAt is the later of UpdatedAt and LastCommentAt.
+	LastActivityAt string `json:"lastActivityAt"`
+	// TombstonedAt is set once a full sync stops seeing the item upstream.
+	TombstonedAt string `json:"tombstonedAt"`
+	// TransferredTo is "owner/repo#key" when the item was moved to another repo.
+	TransferredTo string `json:"transferredTo"`
+	// Links are the PRs closing this issue, or the issues this PR closes.
+	Links []ItemLink `json:"links"`
+	// PR is only set for pull requests.
+	PR            *PRInfo `json:"pr,omitempty"`
+	Assignee      string  `json:"assignee"`
+	AssigneeGroup string  `json:"assigneeGroup"`
+	Note          string  `json:"note"`
+	EstimatedAt   string  `json:"estimatedResolveAt"`
+	SyncInternal  bool    `json:"syncInternal"`
+	Priority      int     `json:"priority"`
+	DueAt         string  `json:"dueAt"`
+	// InternalIssueID and InternalIssueURL point at the internal tracker issue
+	// created once syncInternal was turned on; empty until it exists.
+	InternalIssueID  string `json:"internalIssueId"`
+	InternalIssueURL string `json:"internalIssueUrl"`
+	// InternalState is "open" or "closed" as of InternalCheckedAt; empty
+	// until the internal issue was checked once.
+	InternalState     string `json:"internalState"`
+	InternalCheckedAt string `json:"internalCheckedAt"`
+	// StatusMismatch is true when the upstream and internal issue disagree
+	// on whether the work is done.
+	StatusMismatch bool `json:"statusMismatch"`
+	OverdueDays    int  `json:"overdueDays"`
+	// AssigneeDrift is true when the tracker assignee is not among the GitCode assignees.
+	AssigneeDrift bool `json:"assigneeDrift"`
+}
+
+type PRInfo struct {
+	Merged     bool   `json:"merged"`
+	MergedBy   string `json:"mergedBy"`
+	Draft      bool   `json:"draft"`
+	HeadBranch string `json:"headBranch"`
+	BaseBranch string `json:"baseBranch"`
+	// Size and mergeable are null when GitCode has not reported them yet.
+	Additions    *int  `json:"additions"`
+	Deletions    *int  `json:"deletions"`
+	ChangedFiles *int  `json:"changedFiles"`
+	Mergeable    *bool `json:"mergeable"`
+}
+
+const itemColumns = `id, kind, repo_full_name, external_key, title, state, url, author, created_at, updated_at,
+		body, upstream_assignees, upstream_reviewers, milestone, closed_at, merged_at, comments_count, issue_type, last_comment_at,
+		merged_by, draft, head_branch, base_branch, additions, deletions, changed_files, mergeable,
+		tombstoned_at, transferred_to,
+		assignee, assignee_group, note, estimated_resolve_at, sync_internal, priority, due_at,
+		internal_issue_id, internal_issue_url, internal_state, internal_checked_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanItem(row rowScanner) (Item, error) {
+	var it Item
+	var syncInt int
+	var assignees, reviewers string
+	var pr PRInfo
+	var draft int
+	var additions, deletions, changedFiles sql.NullInt64
+	var mergeable sql.NullBool
+	if err := row.Scan(
+		&it.ID, &it.Kind, &it.RepoFullName, &it.ExternalKey, &it.Title, &it.State, &it.URL, &it.Author, &it.CreatedAt, &it.UpdatedAt,
+		&it.Body, &assignees, &reviewers, &it.Milestone, &it.ClosedAt, &it.MergedAt, &it.CommentsCount, &it.IssueType, &it.LastCommentAt,
+		&pr.MergedBy, &draft, &pr.HeadBranch, &pr.BaseBranch, &additions, &deletions, &changedFiles, &mergeable,
+		&it.TombstonedAt, &it.TransferredTo,
+		&it.Assignee, &it.AssigneeGroup, &it.Note, &it.EstimatedAt, &syncInt, &it.Priority, &it.DueAt,
+		&it.InternalIssueID, &it.InternalIssueURL, &it.InternalState, &it.InternalCheckedAt,
+	); err != nil {
+		return Item{}, err
+	}
+	it.SyncInternal = syncInt != 0
+	it.LastActivityAt = max(it.UpdatedAt, it.LastCommentAt)
+	it.Labels = []Label{}
+	it.UpstreamAssignees = decodeStrings(assignees)
+	it.UpstreamReviewers = decodeStrings(reviewers)
+	it.AssigneeDrift = assigneeDrift(it.Assignee, it.UpstreamAssignees)
+	it.StatusMismatch = statusMismatch(it.State, it.InternalState)
+	it.OverdueDays = computeOverdueDays(it.DueAt)
+	if it.Kind == "pr" {
+		pr.Merged = it.MergedAt != ""
+		pr.Draft = draft != 0
+		pr.Additions = nullIntPtr(additions)
+		pr.Deletions = nullIntPtr(deletions)
+		pr.ChangedFiles = nullIntPtr(changedFiles)
+		if mergeable.Valid {
+			pr.Mergeable = &mergeable.Bool
+		}
+		it.PR = &pr
+	}
+	return it, nil
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
 }
 
 type ListFilter struct {
 	Kind         string
 	RepoFullName string
+	// Labels keeps only items carrying every one of these label names.
+	Labels []string
+	// AssigneeDrift keeps only items whose tracker assignee disagrees with GitCode.
+	AssigneeDrift bool
+	// StatusMismatch keeps only items whose internal issue state disagrees with upstream.
+	StatusMismatch bool
+	// BaseBranch keeps only PRs targeting this branch.
+	BaseBranch string
+	// Merged, when set, keeps only merged (true) or unmerged (false) PRs.
+	Merged *bool
+	// IncludeTombstoned also returns rows deleted or transferred upstream.
+	IncludeTombstoned bool
+	// Sort is "" for the default (due date) order or "lastActivity" for most recent activity first.
+	Sort string
 }
 
 func (s *Store) ListItems(ctx context.Context, f ListFilter) ([]Item, error) {
@@ -91,6 +425,9 @@ func (s *Store) ListItems(ctx context.Context, f ListFilter) ([]Item, error) {
 	where := []string{"1=1"}
 	args := []any{}
 
+	if !f.IncludeTombstoned {
+		where = append(where, "tombstoned_at = ''")
+	}
 	if f.Kind != "" {
 		where = append(where, "kind = ?")
 		args = append(args, f.Kind)
@@ -99,12 +436,45 @@ func (s *Store) ListItems(ctx context.Context, f ListFilter) ([]Item, error) {
 		where = append(where, "repo_full_name = ?")
 		args = append(args, f.RepoFullName)
 	}
+	for _, name := range f.Labels {
+		where = append(where, `EXISTS (SELECT 1 FROM item_labels il JOIN labels l ON l.id = il.label_id
+			WHERE il.item_id = items.id AND l.name = ?)`)
+		args = append(args, name)
+	}
+
+	if f.BaseBranch != "" {
+		where = append(where, "kind = 'pr' AND base_branch = ?")
+		args = append(args, f.BaseBranch)
+	}
+	if f.Merged != nil {
+		if *f.Merged {
+			where = append(where, "kind = 'pr' AND merged_at <> ''")
+		} else {
+			where = append(where, "kind = 'pr' AND merged_at = ''")
+		}
+	}
+
+	if f.AssigneeDrift {
+		// 与 assigneeDrift() 保持一致：两边都为空视为一致，否则本地责任人必须在上游列表里。
+		where = append(where, `NOT ((assignee = '' AND upstream_assignees = '[]')
+			OR EXISTS (SELECT 1 FROM json_each(items.upstream_assignees) WHERE equal_fold(value, items.assignee)))`)
+	}
 
-	q := `SELECT kind, repo_full_name, external_key, title, state, url, author, created_at, updated_at,
-		assignee, assignee_group, note, estimated_resolve_at, sync_internal, priority, due_at
+	if f.StatusMismatch {
+		// 与 statusMismatch() 保持一致。
+		where = append(where, `internal_state <> '' AND
+			(internal_state = 'closed') = (lower(state) IN (`+openStatesSQL+`))`)
+	}
+
+	orderBy := "due_at DESC, updated_at DESC"
+	if f.Sort == "lastActivity" {
+		orderBy = "MAX(updated_at, last_comment_at) DESC, updated_at DESC"
+	}
+
+	q := `SELECT ` + itemColumns + `
 		FROM items
 		WHERE ` + strings.Join(where, " AND ") + `
-		ORDER BY due_at DESC, updated_at DESC;`
+		ORDER BY ` + orderBy + `;`
 
 	rows, err := s.db.QueryContext(ctx, q, args...)
 	if err != nil {
@@ -115,23 +485,25 @@ func (s *Store) ListItems(ctx context.Context, f ListFilter) ([]Item, error) {
 
 	items := []Item{}
 	for rows.Next() {
-		var it Item
-		var syncInt int
-		if err := rows.Scan(
-			&it.Kind, &it.RepoFullName, &it.ExternalKey, &it.Title, &it.State, &it.URL, &it.Author, &it.CreatedAt, &it.UpdatedAt,
-			&it.Assignee, &it.AssigneeGroup, &it.Note, &it.EstimatedAt, &syncInt, &it.Priority, &it.DueAt,
-		); err != nil {
+		it, err := scanItem(rows)
+		if err != nil {
 			logger.Error("list scan failed", "err", err)
 			return nil, err
 		}
-		it.SyncInternal = syncInt != 0
-		it.OverdueDays = computeOverdueDays(it.DueAt)
 		items = append(items, it)
 	}
 	if err := rows.Err(); err != nil {
 		logger.Error("list rows error", "err", err)
 		return nil, err
 	}
+	if err := s.attachLabels(ctx, items); err != nil {
+		logger.Error("list labels failed", "err", err)
+		return nil, err
+	}
+	if err := s.attachLinks(ctx, items); err != nil {
+		logger.Error("list links failed", "err", err)
+		return nil, err
+	}
 	logger.Info("list ok", "kind", f.Kind, "repo", f.RepoFullName, "count", len(items), "elapsed_ms", time.Since(start).Milliseconds())
 	return items, nil
 }
@@ -153,18 +525,22 @@ func IsNotFound(err error) bool { return errors.Is(err, errNotFound) }
 func (s *Store) PatchCustom(ctx context.Context, kind, repoFullName, externalKey string, p CustomPatch) (Item, error) {
 	logger := slog.Default().With("component", "store", "op", "patch")
 	start := time.Now()
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("patch begin failed", "err", err)
+		return Item{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
 	// Read existing first
-	q := `SELECT kind, repo_full_name, external_key, title, state, url, author, created_at, updated_at,
-		assignee, assignee_group, note, estimated_resolve_at, sync_internal, priority, due_at
+	q := `SELECT ` + itemColumns + `
 		FROM items WHERE kind = ? AND repo_full_name = ? AND external_key = ? LIMIT 1;`
 
-	var it Item
-	var syncInt int
-	row := s.db.QueryRowContext(ctx, q, kind, repoFullName, externalKey)
-	if err := row.Scan(
-		&it.Kind, &it.RepoFullName, &it.ExternalKey, &it.Title, &it.State, &it.URL, &it.Author, &it.CreatedAt, &it.UpdatedAt,
-		&it.Assignee, &it.AssigneeGroup, &it.Note, &it.EstimatedAt, &syncInt, &it.Priority, &it.DueAt,
-	); err != nil {
+	it, err := scanItem(tx.QueryRowContext(ctx, q, kind, repoFullName, externalKey))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Warn("patch not found", "kind", kind, "repo", repoFullName, "key", externalKey)
 			return Item{}, errNotFound
@@ -172,7 +548,6 @@ func (s *Store) PatchCustom(ctx context.Context, kind, repoFullName, externalKey
 		logger.Error("patch read failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
 		return Item{}, err
 	}
-	it.SyncInternal = syncInt != 0
 
 	if p.Assignee != nil {
 		it.Assignee = *p.Assignee
@@ -186,6 +561,9 @@ func (s *Store) PatchCustom(ctx context.Context, kind, repoFullName, externalKey
 	if p.EstimatedResolveAt != nil {
 		it.EstimatedAt = *p.EstimatedResolveAt
 	}
+	// 从 false 变成 true 时才需要在内部系统建单。
+	enqueue := p.SyncInternal != nil && *p.SyncInternal && !it.SyncInternal && it.InternalIssueID == ""
+	cancel := p.SyncInternal != nil && !*p.SyncInternal && it.SyncInternal
 	if p.SyncInternal != nil {
 		it.SyncInternal = *p.SyncInternal
 	}
@@ -198,31 +576,75 @@ func (s *Store) PatchCustom(ctx context.Context, kind, repoFullName, externalKey
 
 	upd := `UPDATE items SET assignee=?, assignee_group=?, note=?, estimated_resolve_at=?, sync_internal=?, priority=?, due_at=?
 		WHERE kind=? AND repo_full_name=? AND external_key=?;`
-	if _, err := s.db.ExecContext(ctx, upd,
+	if _, err := tx.ExecContext(ctx, upd,
 		it.Assignee, it.AssigneeGroup, it.Note, it.EstimatedAt, boolToInt(it.SyncInternal), it.Priority, it.DueAt,
 		it.Kind, it.RepoFullName, it.ExternalKey,
 	); err != nil {
 		logger.Error("patch update failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
 		return Item{}, err
 	}
+	if enqueue || cancel {
+		var err error
+		if enqueue {
+			err = enqueueOutbox(ctx, tx, it.ID)
+		} else {
+			err = cancelOutbox(ctx, tx, it.ID)
+		}
+		if err != nil {
+			logger.Error("patch outbox failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
+			return Item{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("patch commit failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
+		return Item{}, err
+	}
 
 	it.OverdueDays = computeOverdueDays(it.DueAt)
+	it.AssigneeDrift = assigneeDrift(it.Assignee, it.UpstreamAssignees)
+	one := []Item{it}
+	if err := s.attachLabels(ctx, one); err != nil {
+		logger.Error("patch labels failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
+		return Item{}, err
+	}
+	if err := s.attachLinks(ctx, one); err != nil {
+		logger.Error("patch links failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
+		return Item{}, err
+	}
+	it = one[0]
 	logger.Info("patch ok", "kind", kind, "repo", repoFullName, "key", externalKey, "elapsed_ms", time.Since(start).Milliseconds())
 	return it, nil
 }
 
 type CoreItem struct {
-	Kind         string
-	RepoFullName string
-	ExternalKey  string
-	Title        string
-	State        string
-	URL          string
-	Author       string
-	CreatedAt    string
-	UpdatedAt    string
+	Kind              string
+	UpstreamID        string
+	RepoFullName      string
+	ExternalKey       string
+	Title             string
+	State             string
+	URL               string
+	Author            string
+	CreatedAt         string
+	UpdatedAt         string
+	Body              string
+	Labels            []Label
+	UpstreamAssignees []string
+	UpstreamReviewers []string
+	Milestone         string
+	ClosedAt          string
+	MergedAt          string
+	CommentsCount     int
+	IssueType         string
+	// Closes lists the issues a PR references with a closing keyword; ignored for issues.
+	Closes []ItemRef
+	// PR carries PR-only fields; nil for issues. Nil size/mergeable fields keep what is already stored.
+	PR *PRInfo
 }
 
+// UpsertCore writes upstream fields only. Tracker-owned fields (assignee,
+// assignee_group, note, ...) are never touched here, so the local assignee
+// and the GitCode assignees stay separate.
 func (s *Store) UpsertCore(ctx context.Context, items []CoreItem) (int, error) {
 	logger := slog.Default().With("component", "store", "op", "upsert")
 	start := time.Now()
@@ -230,16 +652,42 @@ func (s *Store) UpsertCore(ctx context.Context, items []CoreItem) (int, error) {
 		logger.Debug("upsert skipped", "reason", "no items")
 		return 0, nil
 	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 
-	q := `INSERT INTO items(kind, repo_full_name, external_key, title, state, url, author, created_at, updated_at)
-		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
+	q := `INSERT INTO items(kind, repo_full_name, external_key, title, state, url, author, created_at, updated_at,
+			body, upstream_assignees, upstream_reviewers, milestone, closed_at, merged_at, comments_count, issue_type,
+			merged_by, draft, head_branch, base_branch, additions, deletions, changed_files, mergeable, upstream_id)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(kind, repo_full_name, external_key) DO UPDATE SET
 			title=excluded.title,
 			state=excluded.state,
 			url=excluded.url,
 			author=excluded.author,
 			created_at=excluded.created_at,
-			updated_at=excluded.updated_at;`
+			updated_at=excluded.updated_at,
+			body=excluded.body,
+			upstream_assignees=excluded.upstream_assignees,
+			upstream_reviewers=excluded.upstream_reviewers,
+			milestone=excluded.milestone,
+			closed_at=excluded.closed_at,
+			merged_at=excluded.merged_at,
+			comments_count=excluded.comments_count,
+			issue_type=excluded.issue_type,
+			merged_by=excluded.merged_by,
+			draft=excluded.draft,
+			head_branch=excluded.head_branch,
+			base_branch=excluded.base_branch,
+			-- 列表接口不带代码量，缺省时保留上次从详情接口拿到的值
+			additions=COALESCE(excluded.additions, items.additions),
+			deletions=COALESCE(excluded.deletions, items.deletions),
+			changed_files=COALESCE(excluded.changed_files, items.changed_files),
+			mergeable=COALESCE(excluded.mergeable, items.mergeable),
+			upstream_id=CASE WHEN excluded.upstream_id != '' THEN excluded.upstream_id ELSE items.upstream_id END,
+			-- 重新出现在上游的条目恢复可见
+			tombstoned_at='',
+			transferred_to=''
+		RETURNING id;`
 
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -260,12 +708,37 @@ func (s *Store) UpsertCore(ctx context.Context, items []CoreItem) (int, error) {
 		if it.Kind == "" || it.RepoFullName == "" || it.ExternalKey == "" || it.Title == "" {
 			continue
 		}
-		if _, err := stmt.ExecContext(ctx,
+		pr := it.PR
+		if pr == nil {
+			pr = &PRInfo{}
+		}
+		var id int64
+		if err := stmt.QueryRowContext(ctx,
 			it.Kind, it.RepoFullName, it.ExternalKey, it.Title, it.State, it.URL, it.Author, it.CreatedAt, it.UpdatedAt,
-		); err != nil {
+			it.Body, encodeStrings(it.UpstreamAssignees), encodeStrings(it.UpstreamReviewers), it.Milestone, it.ClosedAt, it.MergedAt, it.CommentsCount, it.IssueType,
+			pr.MergedBy, boolToInt(pr.Draft), pr.HeadBranch, pr.BaseBranch, pr.Additions, pr.Deletions, pr.ChangedFiles, pr.Mergeable, it.UpstreamID,
+		).Scan(&id); err != nil {
 			logger.Error("upsert exec failed", "kind", it.Kind, "repo", it.RepoFullName, "key", it.ExternalKey, "err", err)
 			return 0, err
 		}
+		if err := setItemLabels(ctx, tx, id, it.RepoFullName, it.Labels); err != nil {
+			logger.Error("upsert labels failed", "kind", it.Kind, "repo", it.RepoFullName, "key", it.ExternalKey, "err", err)
+			return 0, err
+		}
+		moved, err := reconcileTransfer(ctx, tx, id, it.Kind, it.UpstreamID)
+		if err != nil {
+			logger.Error("upsert transfer check failed", "kind", it.Kind, "repo", it.RepoFullName, "key", it.ExternalKey, "err", err)
+			return 0, err
+		}
+		if moved {
+			logger.Info("upsert transfer reconciled", "kind", it.Kind, "repo", it.RepoFullName, "key", it.ExternalKey, "upstream_id", it.UpstreamID)
+		}
+		if it.Kind == "pr" {
+			if err := setItemLinks(ctx, tx, id, it.Closes); err != nil {
+				logger.Error("upsert links failed", "kind", it.Kind, "repo", it.RepoFullName, "key", it.ExternalKey, "err", err)
+				return 0, err
+			}
+		}
 		count++
 	}
 
@@ -277,6 +750,64 @@ func (s *Store) UpsertCore(ctx context.Context, items []CoreItem) (int, error) {
 	return count, nil
 }
 
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func lookupItemID(ctx context.Context, q queryRower, kind, repoFullName, externalKey string) (int64, error) {
+	var id int64
+	err := q.QueryRowContext(ctx,
+		`SELECT id FROM items WHERE kind = ? AND repo_full_name = ? AND external_key = ?;`,
+		kind, repoFullName, externalKey,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errNotFound
+	}
+	return id, err
+}
+
+// SQL filters call equal_fold so they compare logins exactly like the Go
+// side; SQLite's lower() only folds ASCII.
+func init() {
+	err := sqlite.RegisterDeterministicScalarFunction("equal_fold", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		a, _ := args[0].(string)
+		b, _ := args[1].(string)
+		return strings.EqualFold(a, b), nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func assigneeDrift(local string, upstream []string) bool {
+	if local == "" {
+		return len(upstream) > 0
+	}
+	for _, u := range upstream {
+		if strings.EqualFold(u, local) {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeStrings(v []string) string {
+	if len(v) == 0 {
+		return "[]"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func decodeStrings(s string) []string {
+	var out []string
+	_ = json.Unmarshal([]byte(s), &out)
+	if out == nil {
+		return []string{}
+	}
+	return out
+}
+
 func boolToInt(b bool) int {
 	if b {
 		return 1
@@ -0,0 +1,199 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tracker.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	st := New(db)
+	if err := st.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// seedIssue stores an upstream issue o/r#key and returns it.
+func seedIssue(t *testing.T, st *Store, key string, mod func(*CoreItem)) Item {
+	t.Helper()
+	c := CoreItem{Kind: "issue", RepoFullName: "o/r", ExternalKey: key, Title: "issue " + key, State: "open",
+		URL: "https://gitcode.com/o/r/issues/" + key, Author: "ann", CreatedAt: "2024-01-01T00:00:00Z", UpdatedAt: "2024-01-01T00:00:00Z"}
+	if mod != nil {
+		mod(&c)
+	}
+	ctx := context.Background()
+	if _, err := st.UpsertCore(ctx, []CoreItem{c}); err != nil {
+		t.Fatal(err)
+	}
+	it, err := st.PatchCustom(ctx, c.Kind, c.RepoFullName, key, CustomPatch{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return it
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestAssigneeDriftFilterMatchesFlag(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	for _, tc := range []struct {
+		key      string
+		local    string
+		upstream []string
+	}{
+		{"1", "", nil},
+		{"2", "", []string{"bo"}},
+		{"3", "Bo", []string{"bo"}},
+		{"4", "cy", []string{"bo"}},
+		// lower() 只处理 ASCII，这两条要和 strings.EqualFold 一致。
+		{"5", "Ünal", []string{"ünal"}},
+		{"6", "ΣΟΦΙΑ", []string{"σοφια"}},
+	} {
+		seedIssue(t, st, tc.key, func(c *CoreItem) { c.UpstreamAssignees = tc.upstream })
+		if _, err := st.PatchCustom(ctx, "issue", "o/r", tc.key, CustomPatch{Assignee: ptr(tc.local)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := st.ListItems(ctx, ListFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	drifted, err := st.ListItems(ctx, ListFilter{AssigneeDrift: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inFilter := map[string]bool{}
+	for _, it := range drifted {
+		inFilter[it.ExternalKey] = true
+	}
+	for _, it := range all {
+		if it.AssigneeDrift != inFilter[it.ExternalKey] {
+			t.Errorf("#%s: flag=%v filter=%v", it.ExternalKey, it.AssigneeDrift, inFilter[it.ExternalKey])
+		}
+	}
+	if len(drifted) != 2 {
+		t.Errorf("drifted = %d items, want #2 and #4", len(drifted))
+	}
+}
+
+// listKeys returns the external keys ListItems finds for f, sorted.
+func listKeys(t *testing.T, st *Store, f ListFilter) []string {
+	t.Helper()
+	items, err := st.ListItems(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{}
+	for _, it := range items {
+		keys = append(keys, it.ExternalKey)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestLabelsFilterNeedsEveryLabel(t *testing.T) {
+	st := testStore(t)
+	for key, names := range map[string][]string{
+		"1": {"kind/bug", "sig/core"},
+		"2": {"kind/bug"},
+		"3": {"sig/core", "good-first-issue"},
+		"4": nil,
+	} {
+		seedIssue(t, st, key, func(c *CoreItem) {
+			for _, n := range names {
+				c.Labels = append(c.Labels, Label{Name: n})
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		labels []string
+		want   []string
+	}{
+		{[]string{"kind/bug"}, []string{"1", "2"}},
+		{[]string{"kind/bug", "sig/core"}, []string{"1"}},
+		{[]string{"kind/bug", "good-first-issue"}, []string{}},
+	} {
+		if got := listKeys(t, st, ListFilter{Labels: tc.labels}); !slices.Equal(got, tc.want) {
+			t.Errorf("labels %v: keys = %v, want %v", tc.labels, got, tc.want)
+		}
+	}
+}
+
+func TestPRFiltersBaseBranchAndMerged(t *testing.T) {
+	st := testStore(t)
+	seedIssue(t, st, "1", nil)
+	for _, pr := range []struct {
+		key, base, mergedAt string
+	}{
+		{"2", "master", "2024-02-01T00:00:00Z"},
+		{"3", "master", ""},
+		{"4", "dev", "2024-02-01T00:00:00Z"},
+	} {
+		seedIssue(t, st, pr.key, func(c *CoreItem) {
+			c.Kind, c.MergedAt = "pr", pr.mergedAt
+			c.PR = &PRInfo{Merged: pr.mergedAt != "", HeadBranch: "fix-" + pr.key, BaseBranch: pr.base}
+		})
+	}
+
+	for _, tc := range []struct {
+		name string
+		f    ListFilter
+		want []string
+	}{
+		{"base", ListFilter{BaseBranch: "master"}, []string{"2", "3"}},
+		{"merged", ListFilter{Merged: ptr(true)}, []string{"2", "4"}},
+		{"unmerged", ListFilter{Merged: ptr(false)}, []string{"3"}},
+		{"base and merged", ListFilter{BaseBranch: "master", Merged: ptr(true)}, []string{"2"}},
+		{"unknown base", ListFilter{BaseBranch: "release"}, []string{}},
+	} {
+		if got := listKeys(t, st, tc.f); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: keys = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestUpsertKeepsPRSizeMissingFromList(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	seed := func(title string, info PRInfo) {
+		seedIssue(t, st, "2", func(c *CoreItem) {
+			c.Kind, c.Title, c.PR = "pr", title, &info
+		})
+	}
+	seed("detail", PRInfo{BaseBranch: "master", Additions: ptr(10), Deletions: ptr(2), ChangedFiles: ptr(3), Mergeable: ptr(true)})
+	// 列表页不带大小字段：保留详情接口取到的值，其余字段照常更新。
+	seed("list", PRInfo{BaseBranch: "master"})
+
+	items, err := st.ListItems(ctx, ListFilter{Kind: "pr"})
+	if err != nil || len(items) != 1 {
+		t.Fatalf("items = %+v err = %v", items, err)
+	}
+	pr := items[0].PR
+	if items[0].Title != "list" || pr.Additions == nil || *pr.Additions != 10 || *pr.Deletions != 2 || *pr.ChangedFiles != 3 || !*pr.Mergeable {
+		t.Errorf("pr = %+v title = %q", pr, items[0].Title)
+	}
+
+	// 上游明确报告的 0 和 false 会覆盖旧值。
+	seed("detail", PRInfo{BaseBranch: "master", Additions: ptr(0), Deletions: ptr(0), ChangedFiles: ptr(0), Mergeable: ptr(false)})
+	items, err = st.ListItems(ctx, ListFilter{Kind: "pr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr := items[0].PR; *pr.Additions != 0 || *pr.ChangedFiles != 0 || *pr.Mergeable {
+		t.Errorf("pr after zero sizes = %+v", pr)
+	}
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// TombstoneMissing marks live rows of kind in repoFullName whose key is not in
+// seen as deleted upstream. It must only be called with a complete listing.
+// Rows are never removed, so tracker-owned fields survive; a row that shows up
+// again is revived by UpsertCore.
+func (s *Store) TombstoneMissing(ctx context.Context, kind, repoFullName string, seen []string) (int, error) {
+	logger := slog.Default().With("component", "store", "op", "tombstone")
+	start := time.Now()
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, external_key FROM items WHERE kind = ? AND repo_full_name = ? AND tombstoned_at = '';`,
+		kind, repoFullName,
+	)
+	if err != nil {
+		logger.Error("tombstone query failed", "kind", kind, "repo", repoFullName, "err", err)
+		return 0, err
+	}
+	present := make(map[string]bool, len(seen))
+	for _, k := range seen {
+		present[k] = true
+	}
+	var missing []any
+	for rows.Next() {
+		var id int64
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !present[key] {
+			missing = append(missing, id)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	const batch = 500
+	for i := 0; i < len(missing); i += batch {
+		chunk := missing[i:min(i+batch, len(missing))]
+		q := `UPDATE items SET tombstoned_at = ? WHERE id IN (?` + strings.Repeat(", ?", len(chunk)-1) + `);`
+		if _, err := s.db.ExecContext(ctx, q, append([]any{now}, chunk...)...); err != nil {
+			logger.Error("tombstone update failed", "kind", kind, "repo", repoFullName, "err", err)
+			return 0, err
+		}
+	}
+	logger.Info("tombstone ok", "kind", kind, "repo", repoFullName, "count", len(missing), "elapsed_ms", time.Since(start).Milliseconds())
+	return len(missing), nil
+}
+
+// reconcileTransfer looks for an older row with the same upstream id under a
+// different repo or key, i.e. the item was transferred. The old row is
+// tombstoned and points at the new one, and tracker-owned fields the new row
+// does not have yet are copied over. Upstream ids carry their provider's
+// prefix, so items of different providers never match. It reports whether a
+// transfer was found.
+func reconcileTransfer(ctx context.Context, tx *sql.Tx, id int64, kind, upstreamID string) (bool, error) {
+	if upstreamID == "" {
+		return false, nil
+	}
+	var oldID int64
+	err := tx.QueryRowContext(ctx,
+		`SELECT id FROM items WHERE kind = ? AND upstream_id = ? AND id <> ? AND transferred_to = '' LIMIT 1;`,
+		kind, upstreamID, id,
+	).Scan(&oldID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE items AS n SET
+			assignee = CASE WHEN n.assignee = '' THEN o.assignee ELSE n.assignee END,
+			assignee_group = CASE WHEN n.assignee_group = '' THEN o.assignee_group ELSE n.assignee_group END,
+			note = CASE WHEN n.note = '' THEN o.note ELSE n.note END,
+			estimated_resolve_at = CASE WHEN n.estimated_resolve_at = '' THEN o.estimated_resolve_at ELSE n.estimated_resolve_at END,
+			sync_internal = MAX(n.sync_internal, o.sync_internal),
+			priority = CASE WHEN n.priority = 0 THEN o.priority ELSE n.priority END,
+			due_at = CASE WHEN n.due_at = '' THEN o.due_at ELSE n.due_at END,
+			internal_issue_id = CASE WHEN n.internal_issue_id = '' THEN o.internal_issue_id ELSE n.internal_issue_id END,
+			internal_issue_url = CASE WHEN n.internal_issue_id = '' THEN o.internal_issue_url ELSE n.internal_issue_url END,
+			internal_state = CASE WHEN n.internal_issue_id = '' THEN o.internal_state ELSE n.internal_state END,
+			internal_checked_at = CASE WHEN n.internal_issue_id = '' THEN o.internal_checked_at ELSE n.internal_checked_at END
+		FROM items AS o
+		WHERE n.id = ? AND o.id = ?;`, id, oldID); err != nil {
+		return false, err
+	}
+	// 还没创建的内部 issue 跟着条目走。
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE internal_outbox SET item_id = ? WHERE item_id = ? AND status = 'pending';`, id, oldID); err != nil {
+		return false, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx, `UPDATE items SET
+			tombstoned_at = CASE WHEN tombstoned_at = '' THEN ? ELSE tombstoned_at END,
+			transferred_to = (SELECT repo_full_name || '#' || external_key FROM items WHERE id = ?)
+		WHERE id = ?;`, now, id, oldID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// TombstoneItem marks a single item as deleted upstream, e.g. when GitCode
+// answers 404 for an item a webhook reported.
+func (s *Store) TombstoneItem(ctx context.Context, kind, repoFullName, externalKey string) error {
+	logger := slog.Default().With("component", "store", "op", "tombstone-item")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE items SET tombstoned_at = ? WHERE kind = ? AND repo_full_name = ? AND external_key = ? AND tombstoned_at = '';`,
+		time.Now().UTC().Format(time.RFC3339), kind, repoFullName, externalKey,
+	); err != nil {
+		logger.Error("tombstone item failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTombstoneMissing(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	for _, key := range []string{"1", "2", "3"} {
+		seedIssue(t, st, key, nil)
+	}
+
+	n, err := st.TombstoneMissing(ctx, "issue", "o/r", []string{"1", "3"})
+	if err != nil || n != 1 {
+		t.Fatalf("tombstoned = %d err = %v, want 1", n, err)
+	}
+	live, err := st.ListItems(ctx, ListFilter{Kind: "issue"})
+	if err != nil || len(live) != 2 {
+		t.Fatalf("live = %+v err = %v", live, err)
+	}
+	all, err := st.ListItems(ctx, ListFilter{Kind: "issue", IncludeTombstoned: true})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("all = %+v err = %v", all, err)
+	}
+	// 已经标记过的不再计数。
+	if n, err := st.TombstoneMissing(ctx, "issue", "o/r", []string{"1", "3"}); err != nil || n != 0 {
+		t.Errorf("second pass = %d err = %v, want 0", n, err)
+	}
+
+	// 重新出现在上游时恢复。
+	seedIssue(t, st, "2", nil)
+	if live, _ := st.ListItems(ctx, ListFilter{Kind: "issue"}); len(live) != 3 {
+		t.Errorf("live after revival = %d, want 3", len(live))
+	}
+}
+
+func TestUpsertReconcilesTransfer(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	old := seedIssue(t, st, "1", func(c *CoreItem) { c.UpstreamID = "gitcode:1" })
+	if _, err := st.PatchCustom(ctx, "issue", "o/r", "1", CustomPatch{
+		Assignee: ptr("ann"), Note: ptr("needs repro"), Priority: ptr(2), SyncInternal: ptr(true),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新记录先以另一个仓库的条目出现、还没有 id，看板上已经写了备注。
+	moved := CoreItem{Kind: "issue", RepoFullName: "o/new", ExternalKey: "7", Title: old.Title, State: "open",
+		CreatedAt: old.CreatedAt, UpdatedAt: "2024-02-01T00:00:00Z"}
+	if _, err := st.UpsertCore(ctx, []CoreItem{moved}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.PatchCustom(ctx, "issue", "o/new", "7", CustomPatch{Note: ptr("kept")}); err != nil {
+		t.Fatal(err)
+	}
+	// 同一个 GitCode id 出现在新记录上：看板字段搬过去，新记录已有的值保留。
+	moved.UpstreamID = "gitcode:1"
+	if _, err := st.UpsertCore(ctx, []CoreItem{moved}); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := st.ListItems(ctx, ListFilter{Kind: "issue", IncludeTombstoned: true})
+	if err != nil || len(all) != 2 {
+		t.Fatalf("items = %+v err = %v", all, err)
+	}
+	byRepo := map[string]Item{}
+	for _, it := range all {
+		byRepo[it.RepoFullName] = it
+	}
+	if o := byRepo["o/r"]; o.TombstonedAt == "" || o.TransferredTo != "o/new#7" {
+		t.Errorf("old row = tombstoned %q transferred %q", o.TombstonedAt, o.TransferredTo)
+	}
+	n := byRepo["o/new"]
+	if n.Assignee != "ann" || n.Priority != 2 || !n.SyncInternal || n.Note != "kept" || n.TombstonedAt != "" {
+		t.Errorf("new row = %+v", n)
+	}
+
+	// 排队中的内部 issue 跟着条目走。
+	due, err := st.DueOutbox(ctx, 10)
+	if err != nil || len(due) != 1 || due[0].Item.RepoFullName != "o/new" {
+		t.Errorf("outbox = %+v err = %v, want the entry moved to o/new#7", due, err)
+	}
+	if live, _ := st.ListItems(ctx, ListFilter{Kind: "issue"}); len(live) != 1 || live[0].RepoFullName != "o/new" {
+		t.Errorf("live = %+v", live)
+	}
+}
+
+func TestTransferNeedsSameProvider(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	seedIssue(t, st, "1", func(c *CoreItem) { c.UpstreamID = "gitcode:42" })
+	seedIssue(t, st, "2", func(c *CoreItem) { c.RepoFullName, c.UpstreamID = "gh/r", "github:42" })
+
+	all, err := st.ListItems(ctx, ListFilter{Kind: "issue", IncludeTombstoned: true})
+	if err != nil || len(all) != 2 {
+		t.Fatalf("items = %+v err = %v", all, err)
+	}
+	for _, it := range all {
+		if it.TombstonedAt != "" || it.TransferredTo != "" {
+			t.Errorf("%s#%s treated as transferred: %+v", it.RepoFullName, it.ExternalKey, it)
+		}
+	}
+}
+
+func TestMigratePrefixesUpstreamIDs(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	if _, _, err := st.SaveRepo(ctx, Repo{Provider: "github", Owner: "gh", Name: "r", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	seedIssue(t, st, "1", nil)
+	seedIssue(t, st, "2", func(c *CoreItem) { c.RepoFullName = "gh/r" })
+	if _, err := st.db.ExecContext(ctx, `UPDATE items SET upstream_id = '42';`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	rows, err := st.db.QueryContext(ctx, `SELECT repo_full_name, upstream_id FROM items;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var repo, id string
+		if err := rows.Scan(&repo, &id); err != nil {
+			t.Fatal(err)
+		}
+		got[repo] = id
+	}
+	if got["o/r"] != "gitcode:42" || got["gh/r"] != "github:42" {
+		t.Errorf("upstream ids = %v", got)
+	}
+}
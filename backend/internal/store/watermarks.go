@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// GetWatermark returns the newest upstream updated_at recorded for repo/kind,
+// or "" when the pair has never been synced.
+func (s *Store) GetWatermark(ctx context.Context, repoFullName, kind string) (string, error) {
+	logger := slog.Default().With("component", "store", "op", "get-watermark")
+	var updatedAt string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT updated_at FROM sync_watermarks WHERE repo_full_name = ? AND kind = ?;`,
+		repoFullName, kind,
+	).Scan(&updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		logger.Error("get watermark failed", "kind", kind, "repo", repoFullName, "err", err)
+		return "", err
+	}
+	return updatedAt, nil
+}
+
+func (s *Store) SetWatermark(ctx context.Context, repoFullName, kind, updatedAt string) error {
+	logger := slog.Default().With("component", "store", "op", "set-watermark")
+	q := `INSERT INTO sync_watermarks(repo_full_name, kind, updated_at, synced_at)
+		VALUES(?, ?, ?, ?)
+		ON CONFLICT(repo_full_name, kind) DO UPDATE SET
+			updated_at=excluded.updated_at,
+			synced_at=excluded.synced_at;`
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.db.ExecContext(ctx, q, repoFullName, kind, updatedAt, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		logger.Error("set watermark failed", "kind", kind, "repo", repoFullName, "err", err)
+		return err
+	}
+	logger.Debug("set watermark ok", "kind", kind, "repo", repoFullName, "updatedAt", updatedAt)
+	return nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+type WebhookDelivery struct {
+	ID           string
+	Event        string // issue|pr|comment
+	RepoFullName string
+	Kind         string
+	Key          string
+	Attempts     int
+}
+
+// BeginDelivery queues a webhook delivery for the syncer's webhook worker. It
+// returns false when the same delivery id is already queued or was handled;
+// a delivery that failed is queued again.
+func (s *Store) BeginDelivery(ctx context.Context, d WebhookDelivery) (bool, error) {
+	logger := slog.Default().With("component", "store", "op", "begin-delivery")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var id string
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO webhook_deliveries(id, event, repo_full_name, kind, external_key, status, received_at, next_attempt_at)
+		VALUES(?, ?, ?, ?, ?, 'queued', ?, ?)
+		ON CONFLICT(id) DO UPDATE SET status='queued', error='', attempts=0, finished_at='',
+			received_at=excluded.received_at, next_attempt_at=excluded.next_attempt_at
+			WHERE webhook_deliveries.status = 'failed'
+		RETURNING id;`,
+		d.ID, d.Event, d.RepoFullName, d.Kind, d.Key, now, now,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.Info("duplicate delivery", "delivery", d.ID)
+		return false, nil
+	}
+	if err != nil {
+		logger.Error("begin delivery failed", "delivery", d.ID, "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// DueDeliveries returns up to limit queued deliveries whose next attempt is
+// due, in the order they arrived.
+func (s *Store) DueDeliveries(ctx context.Context, limit int) ([]WebhookDelivery, error) {
+	logger := slog.Default().With("component", "store", "op", "due-deliveries")
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, event, repo_full_name, kind, external_key, attempts FROM webhook_deliveries
+		WHERE status = 'queued' AND next_attempt_at <= ?
+		ORDER BY received_at, id LIMIT ?;`,
+		time.Now().UTC().Format(time.RFC3339), limit,
+	)
+	if err != nil {
+		logger.Error("due deliveries query failed", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var out []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.Event, &d.RepoFullName, &d.Kind, &d.Key, &d.Attempts); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// FinishDelivery stores the outcome of a delivery; status is ok|ignored|failed.
+func (s *Store) FinishDelivery(ctx context.Context, id, status, errMsg string) error {
+	logger := slog.Default().With("component", "store", "op", "finish-delivery")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = ?, error = ?, attempts = attempts + 1, finished_at = ? WHERE id = ?;`,
+		status, errMsg, time.Now().UTC().Format(time.RFC3339), id,
+	); err != nil {
+		logger.Error("finish delivery failed", "delivery", id, "err", err)
+		return err
+	}
+	return nil
+}
+
+// RetryDelivery records a failed attempt and queues the delivery again at
+// next; a zero next marks it failed for good.
+func (s *Store) RetryDelivery(ctx context.Context, id, errMsg string, next time.Time) error {
+	if next.IsZero() {
+		return s.FinishDelivery(ctx, id, "failed", errMsg)
+	}
+	logger := slog.Default().With("component", "store", "op", "retry-delivery")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET error = ?, attempts = attempts + 1, next_attempt_at = ? WHERE id = ? AND status = 'queued';`,
+		errMsg, next.UTC().Format(time.RFC3339), id,
+	); err != nil {
+		logger.Error("retry delivery failed", "delivery", id, "err", err)
+		return err
+	}
+	return nil
+}
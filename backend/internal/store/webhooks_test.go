@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBeginDeliveryDedupes(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	d := WebhookDelivery{ID: "d-1", Event: "issue", RepoFullName: "o/r", Kind: "issue", Key: "1"}
+	begin := func() bool {
+		t.Helper()
+		fresh, err := st.BeginDelivery(ctx, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fresh
+	}
+
+	if !begin() {
+		t.Fatal("first delivery not queued")
+	}
+	if begin() {
+		t.Error("redelivery while queued was queued again")
+	}
+	due, err := st.DueDeliveries(ctx, 10)
+	if err != nil || len(due) != 1 || due[0] != d {
+		t.Fatalf("due = %+v err = %v, want the delivery once", due, err)
+	}
+
+	if err := st.FinishDelivery(ctx, d.ID, "ok", ""); err != nil {
+		t.Fatal(err)
+	}
+	if begin() {
+		t.Error("redelivery of a handled delivery was queued again")
+	}
+	if due, _ := st.DueDeliveries(ctx, 10); len(due) != 0 {
+		t.Errorf("due after finish = %+v", due)
+	}
+
+	// 失败的投递在上游重发时重新排队，次数从头算。
+	if err := st.FinishDelivery(ctx, d.ID, "failed", "boom"); err != nil {
+		t.Fatal(err)
+	}
+	if !begin() {
+		t.Error("redelivery of a failed delivery was not queued")
+	}
+	if due, _ := st.DueDeliveries(ctx, 10); len(due) != 1 || due[0].Attempts != 0 {
+		t.Errorf("due after requeue = %+v", due)
+	}
+}
+
+func TestRetryDelivery(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	for _, id := range []string{"d-1", "d-2"} {
+		if _, err := st.BeginDelivery(ctx, WebhookDelivery{ID: id, Event: "pr", RepoFullName: "o/r", Kind: "pr", Key: "2"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := st.RetryDelivery(ctx, "d-1", "bad gateway", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	due, err := st.DueDeliveries(ctx, 10)
+	if err != nil || len(due) != 1 || due[0].ID != "d-2" {
+		t.Fatalf("due = %+v err = %v, want only d-2 while d-1 waits", due, err)
+	}
+
+	// 零值 next 表示不再重试。
+	if err := st.RetryDelivery(ctx, "d-2", "not found", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	var status, errMsg string
+	var attempts int
+	if err := st.db.QueryRowContext(ctx, `SELECT status, error, attempts FROM webhook_deliveries WHERE id = 'd-2';`).Scan(&status, &errMsg, &attempts); err != nil {
+		t.Fatal(err)
+	}
+	if status != "failed" || errMsg != "not found" || attempts != 1 {
+		t.Errorf("d-2 = %s %q attempts=%d", status, errMsg, attempts)
+	}
+}
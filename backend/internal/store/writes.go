@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// ItemWrite is one change the board pushed upstream (comment, labels,
+// assignees), kept as the item's write history whether or not it succeeded.
+type ItemWrite struct {
+	ID      int64           `json:"id"`
+	Action  string          `json:"action"` // comment|labels|assignees
+	Payload json.RawMessage `json:"payload"`
+	Status  string          `json:"status"` // pending|ok|failed
+	Error   string          `json:"error,omitempty"`
+	// UpstreamStatus is the HTTP status of a rejected request, 0 otherwise.
+	UpstreamStatus int    `json:"upstreamStatus,omitempty"`
+	CreatedAt      string `json:"createdAt"`
+	FinishedAt     string `json:"finishedAt,omitempty"`
+}
+
+// BeginWrite records a pending write on an item before it is sent, so a
+// crash mid-request still leaves a trace. It returns errNotFound if the item
+// is not in the store.
+func (s *Store) BeginWrite(ctx context.Context, kind, repoFullName, externalKey, action string, payload any) (ItemWrite, error) {
+	logger := slog.Default().With("component", "store", "op", "begin-write")
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return ItemWrite{}, err
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	itemID, err := lookupItemID(ctx, s.db, kind, repoFullName, externalKey)
+	if err != nil {
+		return ItemWrite{}, err
+	}
+	w := ItemWrite{Action: action, Payload: b, Status: "pending", CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO item_writes(item_id, action, payload, status, created_at) VALUES(?, ?, ?, ?, ?);`,
+		itemID, w.Action, string(b), w.Status, w.CreatedAt,
+	)
+	if err != nil {
+		logger.Error("begin write failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
+		return ItemWrite{}, err
+	}
+	w.ID, _ = res.LastInsertId()
+	return w, nil
+}
+
+// FinishWrite stores the outcome of w, including its payload, which may now
+// carry per-part results; w.Status is ok|failed.
+func (s *Store) FinishWrite(ctx context.Context, w ItemWrite) (ItemWrite, error) {
+	logger := slog.Default().With("component", "store", "op", "finish-write")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	w.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE item_writes SET payload = ?, status = ?, error = ?, upstream_status = ?, finished_at = ? WHERE id = ?;`,
+		string(w.Payload), w.Status, w.Error, w.UpstreamStatus, w.FinishedAt, w.ID,
+	); err != nil {
+		logger.Error("finish write failed", "id", w.ID, "err", err)
+		return w, err
+	}
+	return w, nil
+}
+
+// ListWrites returns an item's write history, newest first.
+func (s *Store) ListWrites(ctx context.Context, kind, repoFullName, externalKey string) ([]ItemWrite, error) {
+	logger := slog.Default().With("component", "store", "op", "list-writes")
+	itemID, err := lookupItemID(ctx, s.db, kind, repoFullName, externalKey)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, action, payload, status, error, upstream_status, created_at, finished_at
+		FROM item_writes WHERE item_id = ? ORDER BY id DESC;`, itemID)
+	if err != nil {
+		logger.Error("list writes query failed", "kind", kind, "repo", repoFullName, "key", externalKey, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	writes := []ItemWrite{}
+	for rows.Next() {
+		var w ItemWrite
+		var payload string
+		if err := rows.Scan(&w.ID, &w.Action, &payload, &w.Status, &w.Error, &w.UpstreamStatus, &w.CreatedAt, &w.FinishedAt); err != nil {
+			return nil, err
+		}
+		w.Payload = json.RawMessage(payload)
+		writes = append(writes, w)
+	}
+	return writes, rows.Err()
+}
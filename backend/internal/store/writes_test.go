@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteHistory(t *testing.T) {
+	st := testStore(t)
+	ctx := context.Background()
+	seedIssue(t, st, "1", nil)
+
+	if _, err := st.BeginWrite(ctx, "issue", "o/r", "404", "comment", map[string]any{"body": "x"}); !IsNotFound(err) {
+		t.Errorf("write on a missing item: err = %v, want not found", err)
+	}
+
+	first, err := st.BeginWrite(ctx, "issue", "o/r", "1", "comment", map[string]any{"body": "looking"})
+	if err != nil || first.Status != "pending" || first.ID == 0 {
+		t.Fatalf("begin = %+v err = %v", first, err)
+	}
+	// 写一半崩溃的记录停在 pending，历史里照样能看到。
+	second, err := st.BeginWrite(ctx, "issue", "o/r", "1", "labels", map[string]any{"add": []string{"bug"}, "remove": []string{"triage"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Status, second.Error, second.UpstreamStatus = "failed", "remove label triage: 404", 404
+	second.Payload = json.RawMessage(`{"add":["bug"],"remove":["triage"],"results":[{"label":"bug","op":"add","status":"ok"},{"label":"triage","op":"remove","status":"failed"}]}`)
+	if _, err := st.FinishWrite(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+
+	writes, err := st.ListWrites(ctx, "issue", "o/r", "1")
+	if err != nil || len(writes) != 2 {
+		t.Fatalf("writes = %+v err = %v", writes, err)
+	}
+	if w := writes[1]; w.ID != first.ID || w.Status != "pending" || w.FinishedAt != "" || string(w.Payload) != `{"body":"looking"}` {
+		t.Errorf("older write = %+v", w)
+	}
+	w := writes[0]
+	if w.ID != second.ID || w.Status != "failed" || w.UpstreamStatus != 404 || w.FinishedAt == "" {
+		t.Errorf("newer write = %+v", w)
+	}
+	var payload struct {
+		Results []struct{ Label, Status string }
+	}
+	if err := json.Unmarshal(w.Payload, &payload); err != nil || len(payload.Results) != 2 || payload.Results[1].Status != "failed" {
+		t.Errorf("payload = %s err = %v, want the per-label results kept", w.Payload, err)
+	}
+
+	if _, err := st.ListWrites(ctx, "pr", "o/r", "1"); !IsNotFound(err) {
+		t.Errorf("history of a missing item: err = %v, want not found", err)
+	}
+}
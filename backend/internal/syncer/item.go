@@ -0,0 +1,70 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"tracker/internal/provider"
+	"tracker/internal/store"
+)
+
+var ErrUntracked = errors.New("repo is not tracked")
+
+// SyncItem refetches a single issue or PR and upserts it, refreshing its
+// comments too when withComments is set. It does not take the run lock, so
+// webhook updates are not held up by a long-running sync. An item the
+// provider no longer has is tombstoned. The repo must be tracked on
+// providerName, so a webhook from one provider cannot touch another's repo.
+func (s *Syncer) SyncItem(ctx context.Context, providerName, repoFullName, kind, key string, withComments bool) error {
+	logger := slog.Default().With("component", "syncer", "op", "sync-item", "provider", providerName, "repo", repoFullName, "kind", kind, "key", key)
+	start := time.Now()
+	owner, name, _ := strings.Cut(repoFullName, "/")
+	r, err := s.st.GetRepo(ctx, owner, name)
+	if store.IsNotFound(err) || (err == nil && (!r.Enabled || r.Provider != providerName)) {
+		return ErrUntracked
+	}
+	if err != nil {
+		return err
+	}
+	// 统一使用 repos 表里的 owner/name 写法，避免大小写不同产生重复条目。
+	repoFullName = r.FullName()
+
+	client, err := s.newProvider(ConfigFromEnv(), r.Provider)
+	if err != nil {
+		return err
+	}
+	var it provider.Item
+	if kind == "pr" {
+		it, err = client.GetPull(ctx, r.Owner, r.Name, key)
+	} else {
+		it, err = client.GetIssue(ctx, r.Owner, r.Name, key)
+	}
+	if provider.StatusCode(err) == http.StatusNotFound {
+		logger.Info("sync item gone upstream")
+		return s.st.TombstoneItem(ctx, kind, repoFullName, key)
+	}
+	if err != nil {
+		return &UpstreamError{Repo: repoFullName, Err: err}
+	}
+
+	if _, err := s.st.UpsertCore(ctx, []store.CoreItem{toCore(r.Provider, kind, repoFullName, it)}); err != nil {
+		return err
+	}
+	if withComments && r.SyncComments {
+		remote, err := client.ListComments(ctx, r.Owner, r.Name, kind, key)
+		if errors.Is(err, provider.ErrTruncated) {
+			logger.Warn("sync item comments truncated", "count", len(remote))
+		} else if err != nil {
+			return &UpstreamError{Repo: repoFullName, Err: err}
+		}
+		if err := s.st.ReplaceComments(ctx, kind, repoFullName, key, toComments(remote)); err != nil {
+			return err
+		}
+	}
+	logger.Info("sync item ok", "comments", withComments, "elapsed_ms", time.Since(start).Milliseconds())
+	return nil
+}
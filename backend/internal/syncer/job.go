@@ -0,0 +1,36 @@
+package syncer
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"tracker/internal/store"
+)
+
+// jobRun holds the live state of a sync job and persists every change, so
+// GET /api/sync/jobs/{id} can report progress while the run is going.
+type jobRun struct {
+	st  *store.Store
+	mu  sync.Mutex
+	job store.SyncJob
+}
+
+func (r *jobRun) update(ctx context.Context, fn func(j *store.SyncJob)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.job)
+	// 即使同步本身被取消，也要把最终状态写进库。
+	if err := r.st.SaveSyncJob(context.WithoutCancel(ctx), r.job); err != nil {
+		slog.Default().With("component", "syncer", "job", r.job.ID).Warn("persist job progress failed", "err", err)
+	}
+}
+
+func (r *jobRun) snapshot() store.SyncJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j := r.job
+	j.Repos = slices.Clone(r.job.Repos)
+	return j
+}
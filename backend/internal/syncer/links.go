@@ -0,0 +1,70 @@
+package syncer
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"tracker/internal/store"
+)
+
+// 单个引用：#123、owner/repo#123，或 issue 的完整链接。
+const refPattern = `(?:https?://[^\s/]+/[\w.-]+/[\w.-]+/issues/\d+|(?:[\w.-]+/[\w.-]+)?#\d+)`
+
+var (
+	// closingRe matches a closing keyword followed by one or more references,
+	// e.g. "Fixes #12", "closes: openeuler/yuanrong#3, #4".
+	closingRe = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?)\s*:?\s*(` + refPattern + `(?:\s*(?:,|\band\b)\s*` + refPattern + `)*)`)
+	refRe     = regexp.MustCompile(`https?://([^\s/]+)/([\w.-]+/[\w.-]+)/issues/(\d+)|([\w.-]+/[\w.-]+)?#(\d+)`)
+)
+
+// parseClosingRefs returns the issues that text says it closes. Bare "#N"
+// references resolve against repoFullName, and references to it in another
+// casing are spelled like it. Full links only count when they
+// point at host, the web host of the item's provider; links to other hosts
+// name repos this provider does not have, and are skipped.
+func parseClosingRefs(repoFullName, host, text string) []store.ItemRef {
+	seen := map[store.ItemRef]bool{}
+	var refs []store.ItemRef
+	for _, m := range closingRe.FindAllStringSubmatch(text, -1) {
+		for _, r := range refRe.FindAllStringSubmatch(m[1], -1) {
+			ref := store.ItemRef{RepoFullName: repoFullName}
+			switch {
+			case r[3] != "":
+				if host == "" || !sameHost(r[1], host) {
+					continue
+				}
+				ref.RepoFullName, ref.Key = r[2], r[3]
+			default:
+				if r[4] != "" {
+					ref.RepoFullName = r[4]
+				}
+				ref.Key = r[5]
+			}
+			ref.RepoFullName = strings.TrimSuffix(ref.RepoFullName, ".git")
+			if strings.EqualFold(ref.RepoFullName, repoFullName) {
+				// 仓库名不区分大小写，按条目所在仓库的写法存，才能和同步下来的 issue 对上。
+				ref.RepoFullName = repoFullName
+			}
+			if !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// hostOf returns the host of an item's web URL, "" if it has none.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func sameHost(a, b string) bool {
+	trim := func(h string) string { return strings.TrimPrefix(strings.ToLower(h), "www.") }
+	return trim(a) == trim(b)
+}
@@ -0,0 +1,38 @@
+package syncer
+
+import (
+	"slices"
+	"testing"
+
+	"tracker/internal/store"
+)
+
+func TestParseClosingRefs(t *testing.T) {
+	ref := func(repo, key string) store.ItemRef { return store.ItemRef{RepoFullName: repo, Key: key} }
+	for _, tc := range []struct {
+		name, text string
+		want       []store.ItemRef
+	}{
+		{"bare number", "fixes #1", []store.ItemRef{ref("o/r", "1")}},
+		{"keyword variants", "Closes #1\nresolved: #2\nFIX #3", []store.ItemRef{ref("o/r", "1"), ref("o/r", "2"), ref("o/r", "3")}},
+		{"other repo", "fixes owner/repo#2", []store.ItemRef{ref("owner/repo", "2")}},
+		{"list", "closes #1, other/x#4 and #5", []store.ItemRef{ref("o/r", "1"), ref("other/x", "4"), ref("o/r", "5")}},
+		{"full url", "Fixes https://gitcode.com/owner/repo/issues/7", []store.ItemRef{ref("owner/repo", "7")}},
+		{"full url with www", "fixes https://www.GitCode.com/owner/repo/issues/8", []store.ItemRef{ref("owner/repo", "8")}},
+		{"url on a foreign host", "fixes https://github.com/owner/repo/issues/9", nil},
+		{"foreign url then shorthand", "fixes https://gitee.com/a/b/issues/1, #2", []store.ItemRef{ref("o/r", "2")}},
+		{"git suffix", "fixes owner/repo.git#3", []store.ItemRef{ref("owner/repo", "3")}},
+		{"duplicates", "fixes #1\ncloses #1", []store.ItemRef{ref("o/r", "1")}},
+		{"own repo in other casing", "fixes O/R#1, https://gitcode.com/o/R/issues/2 and #1", []store.ItemRef{ref("o/r", "1"), ref("o/r", "2")}},
+		{"mention without keyword", "see #1 and https://gitcode.com/o/r/issues/2", nil},
+	} {
+		if got := parseClosingRefs("o/r", "gitcode.com", tc.text); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: refs = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// 不知道条目所在主机时，完整链接一律不认。
+	if got := parseClosingRefs("o/r", "", "fixes https://gitcode.com/owner/repo/issues/7, #1"); !slices.Equal(got, []store.ItemRef{ref("o/r", "1")}) {
+		t.Errorf("unknown host: refs = %v", got)
+	}
+}
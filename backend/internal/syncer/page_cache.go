@@ -0,0 +1,36 @@
+package syncer
+
+import (
+	"context"
+	"log/slog"
+
+	"tracker/internal/provider"
+	"tracker/internal/store"
+)
+
+// pageCache serves saved list page validators to the provider clients.
+// Lookup errors only cost a conditional request, so they are logged and
+// treated as a miss.
+type pageCache struct{ st *store.Store }
+
+func (c pageCache) Lookup(ctx context.Context, url string) (provider.PageValidators, bool) {
+	e, err := c.st.GetPageCache(ctx, url)
+	if err != nil {
+		if !store.IsNotFound(err) {
+			slog.Default().With("component", "syncer", "op", "page-cache").Warn("page cache lookup failed", "url", url, "err", err)
+		}
+		return provider.PageValidators{}, false
+	}
+	return provider.PageValidators{URL: e.URL, ETag: e.ETag, LastModified: e.LastModified, Next: e.NextURL}, true
+}
+
+// savePageCache stores the validators of the pages a list call fetched in full.
+func (s *Syncer) savePageCache(ctx context.Context, results ...provider.ListResult) error {
+	var entries []store.PageCacheEntry
+	for _, r := range results {
+		for _, v := range r.Validators {
+			entries = append(entries, store.PageCacheEntry{URL: v.URL, ETag: v.ETag, LastModified: v.LastModified, NextURL: v.Next})
+		}
+	}
+	return s.st.SavePageCache(ctx, entries)
+}
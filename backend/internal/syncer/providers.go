@@ -0,0 +1,121 @@
+package syncer
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"tracker/internal/gitcode"
+	"tracker/internal/github"
+	"tracker/internal/provider"
+)
+
+// DefaultProvider is used for repos that do not name one.
+const DefaultProvider = "gitcode"
+
+// Providers lists the provider names a repo can use.
+func Providers() []string { return []string{"gitcode", "github"} }
+
+func KnownProvider(name string) bool { return slices.Contains(Providers(), name) }
+
+// newProvider builds the client for one provider from the env configuration.
+func (s *Syncer) newProvider(cfg Config, name string) (provider.Provider, error) {
+	switch name {
+	case "gitcode":
+		return s.gitcodeClient(cfg)
+	case "github":
+		return github.NewClient(cfg.GitHubBaseURL, cfg.GitHubToken).WithRetry(cfg.GitHubRetry), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q", name)
+	}
+}
+
+func (s *Syncer) gitcodeClient(cfg Config) (*gitcode.Client, error) {
+	tokens, err := s.gitcodeTokens(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return gitcode.NewClient(cfg.BaseURL, "").WithTokens(tokens).WithRetry(cfg.Retry), nil
+}
+
+// gitcodeTokens returns the GitCode token pool. It outlives single runs so
+// token health carries over, and is rebuilt only when GITCODE_TOKEN_FILE or
+// GITCODE_TOKEN change; edits to the file itself are picked up by the pool.
+func (s *Syncer) gitcodeTokens(cfg Config) (*gitcode.TokenPool, error) {
+	if !cfg.hasGitCodeToken() {
+		return nil, fmt.Errorf("%w: GITCODE_TOKEN or GITCODE_TOKEN_FILE", ErrMissingToken)
+	}
+	key := cfg.TokenFile + "\x00" + cfg.Token + "\x00" + cfg.TokenReload.String()
+	s.tokensMu.Lock()
+	defer s.tokensMu.Unlock()
+	if s.tokens != nil && s.tokensKey == key {
+		return s.tokens, nil
+	}
+	if cfg.TokenFile == "" {
+		s.tokens, s.tokensKey = gitcode.NewTokenPool(cfg.Token), key
+		return s.tokens, nil
+	}
+	tokens, err := gitcode.LoadTokenPool(cfg.TokenFile)
+	if err != nil {
+		slog.Default().With("component", "syncer").Error("load token pool failed", "source", cfg.TokenFile, "err", err)
+		return nil, fmt.Errorf("load GITCODE_TOKEN_FILE: %w", err)
+	}
+	s.tokens, s.tokensKey = tokens.WithReloadInterval(cfg.TokenReload), key
+	return s.tokens, nil
+}
+
+// TokenHealth reports the GitCode tokens in use, by fingerprint; it is empty
+// until a sync or write has needed them.
+func (s *Syncer) TokenHealth() []gitcode.TokenHealth {
+	s.tokensMu.Lock()
+	tokens := s.tokens
+	s.tokensMu.Unlock()
+	if tokens == nil {
+		return []gitcode.TokenHealth{}
+	}
+	return tokens.Health()
+}
+
+// providerSet hands out one client per provider for the length of a run, so
+// every repo on a provider shares its quota tracking and retry budget. A
+// provider missing its token fails only the repos on it.
+type providerSet struct {
+	cfg         Config
+	newProvider func(Config, string) (provider.Provider, error)
+
+	mu      sync.Mutex
+	clients map[string]provider.Provider
+}
+
+func newProviderSet(cfg Config, newProvider func(Config, string) (provider.Provider, error)) *providerSet {
+	return &providerSet{cfg: cfg, newProvider: newProvider, clients: map[string]provider.Provider{}}
+}
+
+func (ps *providerSet) get(name string) (provider.Provider, error) {
+	if name == "" {
+		name = DefaultProvider
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if p, ok := ps.clients[name]; ok {
+		return p, nil
+	}
+	p, err := ps.newProvider(ps.cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	ps.clients[name] = p
+	return p, nil
+}
+
+// used returns the clients handed out so far.
+func (ps *providerSet) used() []provider.Provider {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	out := make([]provider.Provider, 0, len(ps.clients))
+	for _, p := range ps.clients {
+		out = append(out, p)
+	}
+	return out
+}
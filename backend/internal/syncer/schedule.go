@@ -0,0 +1,165 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"tracker/internal/store"
+)
+
+// reloadEvery bounds how long the loop sleeps, so repos added or changed
+// through the API are picked up without a restart.
+const reloadEvery = time.Minute
+
+type Schedule struct {
+	// Interval applies to every repo without its own interval; 0 leaves them unscheduled.
+	Interval time.Duration
+	// Jitter adds a random delay in [0, Jitter) to every wait so runs don't line up.
+	Jitter time.Duration
+}
+
+// ParseRepoSchedule parses "repo=interval" pairs separated by commas, e.g. "yuanrong=5m,ray-adapter=1h".
+func ParseRepoSchedule(s string) (map[string]time.Duration, error) {
+	out := map[string]time.Duration{}
+	for _, part := range splitCSV(s) {
+		name, raw, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid repo schedule entry %q", part)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid repo schedule entry %q: %w", part, err)
+		}
+		out[name] = d
+	}
+	return out, nil
+}
+
+// RepoInterval returns how often r is synced on schedule; 0 means never.
+func RepoInterval(r store.Repo, def time.Duration) (time.Duration, error) {
+	if r.Interval == "" {
+		return def, nil
+	}
+	return time.ParseDuration(r.Interval)
+}
+
+// Loop runs incremental syncs for the enabled repos in the store until ctx is
+// cancelled. The repo list is re-read on every pass; repos that fall due
+// together are synced in one run.
+func (s *Syncer) Loop(ctx context.Context, sched Schedule) {
+	logger := slog.Default().With("component", "syncer", "op", "loop")
+	logger.Info("scheduled sync start", "interval", sched.Interval.String(), "jitter", sched.Jitter.String())
+
+	type slot struct {
+		at       time.Time
+		interval time.Duration
+	}
+	next := map[string]slot{}
+	for {
+		repos, err := s.st.ListRepos(ctx, true)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			// 读不到仓库列表时保留已有的计时，稍后再读，不能当成仓库都被删了。
+			logger.Error("scheduled sync list repos failed", "err", err)
+			if !sleep(ctx, reloadEvery) {
+				break
+			}
+			continue
+		}
+
+		now := time.Now()
+		wake := now.Add(reloadEvery)
+		seen := map[string]bool{}
+		var due []string
+		for _, r := range repos {
+			interval, err := RepoInterval(r, sched.Interval)
+			if err != nil {
+				logger.Warn("scheduled sync invalid interval", "repo", r.FullName(), "interval", r.Interval, "err", err)
+				continue
+			}
+			if interval <= 0 {
+				continue
+			}
+			name := r.FullName()
+			seen[name] = true
+			sl, ok := next[name]
+			if !ok || sl.interval != interval {
+				// 新加入或改了间隔的仓库从现在开始计时。
+				sl = slot{at: now.Add(withJitter(interval, sched.Jitter)), interval: interval}
+				next[name] = sl
+			}
+			if !sl.at.After(now) {
+				due = append(due, name)
+			} else if sl.at.Before(wake) {
+				wake = sl.at
+			}
+		}
+		for name := range next {
+			if !seen[name] {
+				delete(next, name)
+			}
+		}
+
+		if len(due) > 0 {
+			s.runScheduled(ctx, due)
+			if ctx.Err() != nil {
+				break
+			}
+			after := time.Now()
+			for _, name := range due {
+				sl := next[name]
+				sl.at = after.Add(withJitter(sl.interval, sched.Jitter))
+				next[name] = sl
+			}
+			continue
+		}
+
+		if !sleep(ctx, time.Until(wake)) {
+			break
+		}
+	}
+	logger.Info("scheduled sync stopped")
+}
+
+// sleep waits for d and reports false if ctx was cancelled first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (s *Syncer) runScheduled(ctx context.Context, repos []string) {
+	logger := slog.Default().With("component", "syncer", "op", "loop")
+	start := time.Now()
+	job, err := s.RunWait(ctx, Options{Repos: repos}, "schedule")
+	switch {
+	case err != nil:
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Error("scheduled sync failed", "job", job.ID, "repos", repos, "err", err)
+	case job.Status == "partial":
+		logger.Warn("scheduled sync partially failed", "job", job.ID, "repos", repos, "error", job.Error, "fetched", job.Fetched, "upserted", job.Upserted, "elapsed_ms", time.Since(start).Milliseconds())
+	default:
+		logger.Info("scheduled sync ok", "job", job.ID, "repos", repos, "fetched", job.Fetched, "upserted", job.Upserted, "elapsed_ms", time.Since(start).Milliseconds())
+	}
+}
+
+func withJitter(d, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	return d + rand.N(jitter)
+}
@@ -0,0 +1,110 @@
+package syncer
+
+import (
+	"context"
+	"maps"
+	"testing"
+	"time"
+
+	"tracker/internal/store"
+)
+
+func TestParseRepoSchedule(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    map[string]time.Duration
+		wantErr bool
+	}{
+		{in: "", want: map[string]time.Duration{}},
+		{in: "yuanrong=5m", want: map[string]time.Duration{"yuanrong": 5 * time.Minute}},
+		{in: " yuanrong = 5m , o/ray-adapter=1h ", want: map[string]time.Duration{"yuanrong": 5 * time.Minute, "o/ray-adapter": time.Hour}},
+		{in: "docs=0", want: map[string]time.Duration{"docs": 0}},
+		{in: "yuanrong", wantErr: true},
+		{in: "=5m", wantErr: true},
+		{in: "yuanrong=soon", wantErr: true},
+		{in: "yuanrong=5m,ray", wantErr: true},
+	} {
+		got, err := ParseRepoSchedule(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRepoSchedule(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !maps.Equal(got, tt.want) {
+			t.Errorf("ParseRepoSchedule(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRepoInterval(t *testing.T) {
+	for _, tt := range []struct {
+		interval string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{interval: "", want: time.Hour},
+		{interval: "5m", want: 5 * time.Minute},
+		{interval: "0", want: 0},
+		{interval: "often", wantErr: true},
+	} {
+		got, err := RepoInterval(store.Repo{Owner: "o", Name: "r", Interval: tt.interval}, time.Hour)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("RepoInterval(%q) = %v err = %v, want %v wantErr %v", tt.interval, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	if got := withJitter(time.Minute, 0); got != time.Minute {
+		t.Errorf("withJitter without jitter = %v", got)
+	}
+	for range 100 {
+		if got := withJitter(time.Minute, time.Second); got < time.Minute || got >= time.Minute+time.Second {
+			t.Fatalf("withJitter = %v, want in [1m, 1m1s)", got)
+		}
+	}
+}
+
+func TestLoop(t *testing.T) {
+	sy, st, srv := testSyncer(t, "fast", "slow", "manual")
+	ctx := context.Background()
+	for name, interval := range map[string]string{"fast": "50ms", "manual": "0"} {
+		if _, _, err := st.SaveRepo(ctx, store.Repo{Provider: "gitcode", Owner: "o", Name: name, Enabled: true, Interval: interval}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 占住同步槽，模拟一次正在跑的 API 同步：定时同步要等它结束。
+	sy.sem <- struct{}{}
+	loopCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sy.Loop(loopCtx, Schedule{Interval: time.Hour, Jitter: 10 * time.Millisecond})
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	if n := srv.Hits("/api/v5/repos/o/*/issues"); n != 0 {
+		t.Fatalf("scheduled sync ran %d listings while another sync was running", n)
+	}
+	<-sy.sem
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.Hits("/api/v5/repos/o/fast/issues") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("fast repo was not synced twice")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Loop did not stop after cancel")
+	}
+
+	// slow 用默认的 1h 间隔，manual 为 0 只手动同步，都不会在这期间跑。
+	if n := srv.Hits("/api/v5/repos/o/slow/issues") + srv.Hits("/api/v5/repos/o/manual/issues"); n != 0 {
+		t.Errorf("slow and manual repos listed %d times", n)
+	}
+}
@@ -0,0 +1,819 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"tracker/internal/gitcode"
+	"tracker/internal/provider"
+	"tracker/internal/store"
+)
+
+var (
+	ErrBusy         = errors.New("sync already running")
+	ErrMissingToken = errors.New("missing provider token")
+)
+
+// UpstreamError marks failures talking to a provider, as opposed to local store errors.
+type UpstreamError struct {
+	Repo string
+	Err  error
+}
+
+func (e *UpstreamError) Error() string { return fmt.Sprintf("sync %s: %v", e.Repo, e.Err) }
+func (e *UpstreamError) Unwrap() error { return e.Err }
+
+type Config struct {
+	BaseURL string
+	// GitHubBaseURL and GitHubToken configure the github provider; unlike
+	// GitCode it works without a token, at a much lower rate limit.
+	GitHubBaseURL string
+	GitHubToken   string
+	GitHubRetry   provider.RetryPolicy
+	// Owner, Repos, SyncComments and PRDetails only seed the repos table on
+	// first start; after that the table is the source of truth.
+	Owner string
+	Repos []string
+	Token string
+	// TokenFile, when set, replaces Token with a pool read from a file or a
+	// directory of secret files, reread every TokenReload.
+	TokenFile   string
+	TokenReload time.Duration
+	Concurrency int
+	Retry       provider.RetryPolicy
+	// QuotaReserve pauses new list requests once a provider's quota drops to this many calls.
+	QuotaReserve int
+	MaxPages     int
+	SyncComments bool
+	PRDetails    bool
+	// DiscoverOwner's repos on DiscoverProvider matching Discover are tracked
+	// automatically; an empty Discover.Include turns discovery off.
+	DiscoverProvider string
+	DiscoverOwner    string
+	Discover         provider.RepoFilter
+}
+
+// SeedRepos turns the env configuration into the initial repos table rows.
+func SeedRepos(cfg Config, perRepo map[string]time.Duration) []store.Repo {
+	repos := make([]store.Repo, 0, len(cfg.Repos))
+	for _, name := range cfg.Repos {
+		r := store.Repo{Provider: DefaultProvider, Owner: cfg.Owner, Name: name, Enabled: true, SyncComments: cfg.SyncComments, SyncPRDetails: cfg.PRDetails}
+		if d, ok := perRepo[name]; ok {
+			r.Interval = d.String()
+		}
+		repos = append(repos, r)
+	}
+	return repos
+}
+
+func ConfigFromEnv() Config {
+	logger := slog.Default().With("component", "syncer")
+	retry := provider.DefaultRetryPolicy()
+	retry.MaxRetries = envInt(logger, "GITCODE_MAX_RETRIES", retry.MaxRetries)
+	retry.Budget = envInt(logger, "GITCODE_RETRY_BUDGET", retry.Budget)
+	githubRetry := provider.DefaultRetryPolicy()
+	githubRetry.MaxRetries = envInt(logger, "GITHUB_MAX_RETRIES", githubRetry.MaxRetries)
+	githubRetry.Budget = envInt(logger, "GITHUB_RETRY_BUDGET", githubRetry.Budget)
+	return Config{
+		BaseURL:          envOrDefault("GITCODE_BASE_URL", "https://api.gitcode.com"),
+		Owner:            envOrDefault("GITCODE_OWNER", "openeuler"),
+		Repos:            splitCSV(envOrDefault("GITCODE_REPOS", "yuanrong,yuanrong-functionsystem,yuanrong-datasystem,ray-adapter,yuanrong-frontend,yuanrong-serve,spring-adapter")),
+		Token:            os.Getenv("GITCODE_TOKEN"),
+		TokenFile:        os.Getenv("GITCODE_TOKEN_FILE"),
+		TokenReload:      envDuration(logger, "GITCODE_TOKEN_RELOAD", gitcode.DefaultTokenReload),
+		GitHubBaseURL:    envOrDefault("GITHUB_BASE_URL", "https://api.github.com"),
+		GitHubToken:      os.Getenv("GITHUB_TOKEN"),
+		GitHubRetry:      githubRetry,
+		Concurrency:      envInt(logger, "SYNC_CONCURRENCY", 4),
+		Retry:            retry,
+		QuotaReserve:     envInt(logger, "SYNC_QUOTA_RESERVE", 20),
+		MaxPages:         envInt(logger, "GITCODE_MAX_PAGES", gitcode.DefaultMaxPages),
+		SyncComments:     envBool(logger, "SYNC_COMMENTS", true),
+		PRDetails:        envBool(logger, "SYNC_PR_DETAILS", true),
+		DiscoverProvider: envOrDefault("SYNC_DISCOVER_PROVIDER", DefaultProvider),
+		DiscoverOwner:    envOrDefault("SYNC_DISCOVER_OWNER", envOrDefault("GITCODE_OWNER", "openeuler")),
+		Discover: provider.RepoFilter{
+			Include: splitCSV(os.Getenv("SYNC_DISCOVER_INCLUDE")),
+			Exclude: splitCSV(os.Getenv("SYNC_DISCOVER_EXCLUDE")),
+		},
+	}
+}
+
+func (cfg Config) hasGitCodeToken() bool { return cfg.Token != "" || cfg.TokenFile != "" }
+
+type Options struct {
+	// Full ignores the stored watermarks and walks every page.
+	Full bool
+	// Repos restricts the run to these "owner/name" repos; empty means all enabled repos.
+	Repos []string
+}
+
+// Syncer pulls issues and PRs from each repo's provider into the store. Every
+// run is recorded as a store.SyncJob, and at most one run is in flight at a
+// time, whether started by the API or by the scheduler.
+type Syncer struct {
+	st  *store.Store
+	sem chan struct{}
+
+	tokensMu  sync.Mutex
+	tokens    *gitcode.TokenPool
+	tokensKey string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	webhooks chan struct{} // wakes RunWebhooks when a delivery is queued
+}
+
+func New(st *store.Store) *Syncer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Syncer{st: st, sem: make(chan struct{}, 1), ctx: ctx, cancel: cancel, webhooks: make(chan struct{}, 1)}
+}
+
+// Start launches a sync in the background and returns its job right away,
+// or ErrBusy if one is already running.
+func (s *Syncer) Start(ctx context.Context, opts Options, trigger string) (store.SyncJob, error) {
+	cfg := ConfigFromEnv()
+
+	select {
+	case s.sem <- struct{}{}:
+	default:
+		return store.SyncJob{}, ErrBusy
+	}
+
+	jr, repos, err := s.newJob(ctx, opts, trigger)
+	if err != nil {
+		<-s.sem
+		return store.SyncJob{}, err
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.sem }()
+		_ = s.run(s.ctx, cfg, opts, jr, repos)
+	}()
+	return jr.snapshot(), nil
+}
+
+// RunWait waits for any in-flight sync to finish, then runs one to completion.
+func (s *Syncer) RunWait(ctx context.Context, opts Options, trigger string) (store.SyncJob, error) {
+	cfg := ConfigFromEnv()
+
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		return store.SyncJob{}, ctx.Err()
+	}
+	defer func() { <-s.sem }()
+
+	jr, repos, err := s.newJob(ctx, opts, trigger)
+	if err != nil {
+		return store.SyncJob{}, err
+	}
+	err = s.run(ctx, cfg, opts, jr, repos)
+	return jr.snapshot(), err
+}
+
+// Close cancels a background sync started by Start and waits for it to stop.
+func (s *Syncer) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Syncer) newJob(ctx context.Context, opts Options, trigger string) (*jobRun, []store.Repo, error) {
+	repos, err := s.st.ListRepos(ctx, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(opts.Repos) > 0 {
+		repos = slices.DeleteFunc(repos, func(r store.Repo) bool {
+			return !slices.ContainsFunc(opts.Repos, func(name string) bool { return strings.EqualFold(name, r.FullName()) })
+		})
+	}
+	job := store.SyncJob{
+		ID:        uuid.NewString(),
+		Trigger:   trigger,
+		Status:    "running",
+		Full:      opts.Full,
+		Repos:     make([]store.SyncJobRepo, 0, len(repos)),
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, repo := range repos {
+		job.Repos = append(job.Repos, store.SyncJobRepo{Repo: repo.FullName(), Status: "pending"})
+	}
+	if err := s.st.SaveSyncJob(ctx, job); err != nil {
+		return nil, nil, err
+	}
+	return &jobRun{st: s.st, job: job}, repos, nil
+}
+
+// run syncs repos, whose order matches jr.job.Repos.
+func (s *Syncer) run(ctx context.Context, cfg Config, opts Options, jr *jobRun, repos []store.Repo) error {
+	logger := slog.Default().With("component", "syncer", "job", jr.job.ID)
+	start := time.Now()
+	logger.Info("sync start", "baseURL", cfg.BaseURL, "full", opts.Full, "repos", len(jr.job.Repos), "concurrency", cfg.Concurrency)
+
+	providers := newProviderSet(cfg, s.newProvider)
+
+	if len(cfg.Discover.Include) > 0 {
+		added := s.discover(ctx, providers, cfg, jr)
+		// 只有不限定仓库的同步才顺带同步新发现的仓库，定时同步的分组等下一轮再纳入。
+		if len(opts.Repos) == 0 && len(added) > 0 {
+			repos = append(repos, added...)
+			jr.update(ctx, func(j *store.SyncJob) {
+				for _, r := range added {
+					j.Repos = append(j.Repos, store.SyncJobRepo{Repo: r.FullName(), Status: "pending"})
+				}
+			})
+		}
+	}
+
+	// slots 限制同时进行的上游列表请求数（仓库 × issue/PR）。
+	slots := make(chan struct{}, max(cfg.Concurrency, 1))
+
+	// 每个仓库独立成功或失败，一个仓库出错不影响其他仓库。
+	var wg sync.WaitGroup
+	for i := range jr.job.Repos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repoStart := time.Now()
+			if err := s.syncRepo(ctx, providers, cfg, repos[i], opts.Full, jr, i, slots); err != nil {
+				jr.update(ctx, func(j *store.SyncJob) {
+					j.Repos[i].Status = "failed"
+					j.Repos[i].Error = err.Error()
+					j.Repos[i].ElapsedMs = time.Since(repoStart).Milliseconds()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	jr.update(ctx, func(j *store.SyncJob) {
+		for _, r := range j.Repos {
+			if r.Status == "failed" {
+				failed++
+			}
+		}
+		switch {
+		case failed == 0:
+			j.Status = "succeeded"
+		case failed < len(j.Repos):
+			j.Status = "partial"
+			j.Error = fmt.Sprintf("%d of %d repos failed", failed, len(j.Repos))
+		default:
+			j.Status = "failed"
+			j.Error = "all repos failed"
+		}
+		j.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	})
+	done := jr.snapshot()
+	elapsed := time.Since(start).Milliseconds()
+
+	switch done.Status {
+	case "failed":
+		logger.Error("sync failed", "failed", failed, "elapsed_ms", elapsed)
+		return errors.New(done.Error)
+	case "partial":
+		logger.Warn("sync partially failed", "failed", failed, "repos", len(done.Repos), "fetched", done.Fetched, "upserted", done.Upserted, "elapsed_ms", elapsed)
+	default:
+		logger.Info("sync done", "fetched", done.Fetched, "upserted", done.Upserted, "elapsed_ms", elapsed)
+	}
+	for _, p := range providers.used() {
+		if q := p.Quota(); q.Known {
+			logger.Info("provider quota", "provider", p.Name(), "limit", q.Limit, "remaining", q.Remaining, "reset", q.Reset.Format(time.RFC3339))
+		}
+		if gc, ok := p.(*gitcode.Client); ok {
+			for _, h := range gc.TokenHealth() {
+				logger.Info("gitcode token", "id", h.ID, "origin", h.Origin, "status", h.Status, "current", h.Current, "last_status", h.LastStatus)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Syncer) syncRepo(ctx context.Context, providers *providerSet, cfg Config, r store.Repo, full bool, jr *jobRun, i int, slots chan struct{}) error {
+	logger := slog.Default().With("component", "syncer", "job", jr.job.ID)
+	start := time.Now()
+	owner, repo := r.Owner, r.Name
+	repoFullName := r.FullName()
+
+	client, err := providers.get(r.Provider)
+	if err != nil {
+		logger.Error("sync provider unavailable", "repo", repoFullName, "provider", r.Provider, "err", err)
+		return err
+	}
+
+	// 每翻一页前都看一下额度，长列表也能在被限流前停下来。
+	pace := func(ctx context.Context) error { return paceQuota(ctx, client, cfg.QuotaReserve) }
+	issueOpts := provider.ListOptions{MaxPages: cfg.MaxPages, BeforePage: pace}
+	prOpts := provider.ListOptions{MaxPages: cfg.MaxPages, BeforePage: pace}
+	if !full {
+		// 全量同步需要看到每一条数据（墓碑判断依赖它），所以不走条件请求。
+		issueOpts.Cache, prOpts.Cache = pageCache{s.st}, pageCache{s.st}
+		var err error
+		if issueOpts.Since, err = s.st.GetWatermark(ctx, repoFullName, "issue"); err != nil {
+			return err
+		}
+		if prOpts.Since, err = s.st.GetWatermark(ctx, repoFullName, "pr"); err != nil {
+			return err
+		}
+	}
+
+	var issues, prs provider.ListResult
+	var issuesErr, prsErr error
+	var issuesMs, prsMs int64
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		issues, issuesMs, issuesErr = fetch(ctx, slots, func(ctx context.Context) (provider.ListResult, error) {
+			jr.update(ctx, func(j *store.SyncJob) { j.Repos[i].Status = "running" })
+			return client.ListIssues(ctx, owner, repo, issueOpts)
+		})
+		if issuesErr == nil {
+			jr.update(ctx, func(j *store.SyncJob) { j.Repos[i].Issues = len(issues.Items) })
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		prs, prsMs, prsErr = fetch(ctx, slots, func(ctx context.Context) (provider.ListResult, error) {
+			jr.update(ctx, func(j *store.SyncJob) { j.Repos[i].Status = "running" })
+			return client.ListPulls(ctx, owner, repo, prOpts)
+		})
+		if prsErr == nil {
+			jr.update(ctx, func(j *store.SyncJob) { j.Repos[i].PRs = len(prs.Items) })
+		}
+	}()
+	wg.Wait()
+
+	if issuesErr != nil {
+		logger.Error("sync list issues failed", "repo", repoFullName, "err", issuesErr)
+		return &UpstreamError{Repo: repoFullName, Err: issuesErr}
+	}
+	if prsErr != nil {
+		logger.Error("sync list pulls failed", "repo", repoFullName, "err", prsErr)
+		return &UpstreamError{Repo: repoFullName, Err: prsErr}
+	}
+
+	var warnings []string
+	if issues.Truncated {
+		warnings = append(warnings, fmt.Sprintf("issues truncated after %d pages", issues.Pages))
+	}
+	if prs.Truncated {
+		warnings = append(warnings, fmt.Sprintf("prs truncated after %d pages", prs.Pages))
+	}
+	for _, w := range warnings {
+		logger.Warn("sync repo truncated", "repo", repoFullName, "warning", w)
+	}
+
+	if r.SyncPRDetails {
+		warnings = append(warnings, s.fillPullDetails(ctx, client, owner, repo, prs.Items, slots)...)
+	}
+
+	core := make([]store.CoreItem, 0, len(issues.Items)+len(prs.Items))
+	for _, it := range issues.Items {
+		core = append(core, toCore(r.Provider, "issue", repoFullName, it))
+	}
+	for _, it := range prs.Items {
+		core = append(core, toCore(r.Provider, "pr", repoFullName, it))
+	}
+
+	up, err := s.st.UpsertCore(ctx, core)
+	if err != nil {
+		logger.Error("sync upsert failed", "repo", repoFullName, "err", err)
+		return err
+	}
+
+	tombstoned := 0
+	if full {
+		n, w, err := s.tombstone(ctx, repoFullName, issues, prs)
+		if err != nil {
+			logger.Error("sync tombstone failed", "repo", repoFullName, "err", err)
+			return err
+		}
+		tombstoned = n
+		warnings = append(warnings, w...)
+	}
+
+	var commentsFailed map[string]bool
+	if r.SyncComments {
+		n, failed, kinds := s.syncComments(ctx, client, owner, repo, issues.Items, prs.Items, slots)
+		warnings = append(warnings, failed...)
+		commentsFailed = kinds
+		jr.update(ctx, func(j *store.SyncJob) { j.Repos[i].Comments = n })
+	}
+
+	// 缓存同样要等写库成功后再保存，否则下次 304 会跳过没入库的数据。
+	// 评论没拉全的一侧也不保存，下次重新拉这些页，失败的评论随之重试。
+	var cached []provider.ListResult
+	if !commentsFailed["issue"] {
+		cached = append(cached, issues)
+	}
+	if !commentsFailed["pr"] {
+		cached = append(cached, prs)
+	}
+	if err := s.savePageCache(ctx, cached...); err != nil {
+		logger.Error("sync save page cache failed", "repo", repoFullName, "err", err)
+		return err
+	}
+
+	// 只有写库成功且评论都拉到时才推进水位，避免下次增量同步漏数据。
+	if wm := nextWatermark(issues); wm != "" && !commentsFailed["issue"] {
+		if err := s.st.SetWatermark(ctx, repoFullName, "issue", wm); err != nil {
+			return err
+		}
+	}
+	if wm := nextWatermark(prs); wm != "" && !commentsFailed["pr"] {
+		if err := s.st.SetWatermark(ctx, repoFullName, "pr", wm); err != nil {
+			return err
+		}
+	}
+
+	elapsed := time.Since(start).Milliseconds()
+	jr.update(ctx, func(j *store.SyncJob) {
+		j.Repos[i].Status = "ok"
+		j.Repos[i].Upserted = up
+		j.Repos[i].Tombstoned = tombstoned
+		j.Repos[i].CacheHits = issues.CacheHits + prs.CacheHits
+		j.Repos[i].CacheMisses = issues.CacheMisses + prs.CacheMisses
+		j.Repos[i].ElapsedMs = elapsed
+		j.Repos[i].Warnings = warnings
+		j.Fetched += len(core)
+		j.Upserted += up
+	})
+	logger.Info("sync repo ok", "repo", repoFullName, "issues", len(issues.Items), "prs", len(prs.Items), "upserted", up,
+		"since_issue", issueOpts.Since, "since_pr", prOpts.Since,
+		"cache_hits", issues.CacheHits+prs.CacheHits, "cache_misses", issues.CacheMisses+prs.CacheMisses,
+		"issues_ms", issuesMs, "prs_ms", prsMs, "elapsed_ms", elapsed)
+	return nil
+}
+
+// nextWatermark is where the next incremental listing should start: the
+// newest update fetched, or for a truncated listing the point it can be
+// resumed from. "" keeps the current watermark.
+func nextWatermark(res provider.ListResult) string {
+	if res.Truncated {
+		return res.Resume
+	}
+	return provider.LatestUpdatedAt(res.Items)
+}
+
+// discover lists DiscoverOwner's repos, tracks the matching ones not seen
+// before and records the result on the job. A discovery failure is reported
+// there but does not fail the run; a truncated listing still adds the repos
+// it did return.
+func (s *Syncer) discover(ctx context.Context, providers *providerSet, cfg Config, jr *jobRun) []store.Repo {
+	logger := slog.Default().With("component", "syncer", "op", "discover", "job", jr.job.ID, "owner", cfg.DiscoverOwner)
+	res := &store.SyncJobDiscovery{Owner: cfg.DiscoverOwner, Added: []string{}}
+
+	var remote []provider.Repo
+	client, err := providers.get(cfg.DiscoverProvider)
+	if err == nil {
+		remote, err = client.ListRepos(ctx, cfg.DiscoverOwner, cfg.Discover)
+		if errors.Is(err, provider.ErrTruncated) {
+			logger.Warn("repo listing truncated; later pages not considered", "count", len(remote))
+			res.Truncated, err = true, nil
+		}
+	}
+	var added []store.Repo
+	if err == nil {
+		candidates := make([]store.Repo, 0, len(remote))
+		for _, r := range remote {
+			if r.Archived {
+				continue
+			}
+			candidates = append(candidates, store.Repo{
+				Provider: client.Name(), Owner: r.Owner, Name: r.Name, Enabled: true, SyncComments: cfg.SyncComments, SyncPRDetails: cfg.PRDetails,
+			})
+		}
+		res.Matched = len(candidates)
+		added, err = s.st.AddDiscoveredRepos(ctx, candidates)
+	}
+	if err != nil {
+		logger.Warn("repo discovery failed", "err", err)
+		res.Error = err.Error()
+	}
+	for _, r := range added {
+		res.Added = append(res.Added, r.FullName())
+	}
+	if len(added) > 0 {
+		logger.Info("repo discovery added repos", "added", res.Added)
+	}
+	jr.update(ctx, func(j *store.SyncJob) { j.Discovery = res })
+	return added
+}
+
+// tombstone marks local items that a full listing no longer returns. A
+// truncated or empty listing is not trusted, so nothing is marked for it.
+func (s *Syncer) tombstone(ctx context.Context, repoFullName string, issues, prs provider.ListResult) (int, []string, error) {
+	total := 0
+	var warnings []string
+	for _, l := range []struct {
+		kind string
+		res  provider.ListResult
+	}{{"issue", issues}, {"pr", prs}} {
+		if l.res.Truncated {
+			warnings = append(warnings, fmt.Sprintf("%s listing truncated, tombstoning skipped", l.kind))
+			continue
+		}
+		if len(l.res.Items) == 0 {
+			// 空列表更可能是上游异常而不是整个仓库被清空，保守跳过。
+			continue
+		}
+		keys := make([]string, 0, len(l.res.Items))
+		for _, it := range l.res.Items {
+			keys = append(keys, it.Key)
+		}
+		n, err := s.st.TombstoneMissing(ctx, l.kind, repoFullName, keys)
+		if err != nil {
+			return 0, nil, err
+		}
+		total += n
+	}
+	return total, warnings, nil
+}
+
+// syncComments refreshes comments for the fetched items that have any. A
+// failure on one item is returned as a warning instead of failing the repo,
+// and its kind is reported so the caller keeps that listing's watermark.
+func (s *Syncer) syncComments(ctx context.Context, client provider.Provider, owner, repo string, issues, prs []provider.Item, slots chan struct{}) (int, []string, map[string]bool) {
+	logger := slog.Default().With("component", "syncer", "op", "comments")
+	repoFullName := owner + "/" + repo
+
+	type target struct {
+		kind string
+		key  string
+	}
+	var targets []target
+	for _, it := range issues {
+		if it.Comments > 0 {
+			targets = append(targets, target{"issue", it.Key})
+		}
+	}
+	for _, it := range prs {
+		if it.Comments > 0 {
+			targets = append(targets, target{"pr", it.Key})
+		}
+	}
+
+	var mu sync.Mutex
+	total := 0
+	var warnings []string
+	failedKinds := map[string]bool{}
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				mu.Lock()
+				failedKinds[t.kind] = true
+				mu.Unlock()
+				return
+			}
+			defer func() { <-slots }()
+
+			remote, err := client.ListComments(ctx, owner, repo, t.kind, t.key)
+			truncated := errors.Is(err, provider.ErrTruncated)
+			if truncated {
+				// 截断的评论重试也拿不全，先存下已有的部分，只给出警告。
+				err = nil
+			}
+			if err == nil {
+				err = s.st.ReplaceComments(ctx, t.kind, repoFullName, t.key, toComments(remote))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logger.Warn("sync comments failed", "repo", repoFullName, "kind", t.kind, "key", t.key, "err", err)
+				warnings = append(warnings, fmt.Sprintf("comments for %s %s: %v", t.kind, t.key, err))
+				failedKinds[t.kind] = true
+				return
+			}
+			if truncated {
+				logger.Warn("sync comments truncated", "repo", repoFullName, "kind", t.kind, "key", t.key, "count", len(remote))
+				warnings = append(warnings, fmt.Sprintf("comments for %s %s truncated after %d", t.kind, t.key, len(remote)))
+			}
+			total += len(remote)
+		}()
+	}
+	wg.Wait()
+	return total, warnings, failedKinds
+}
+
+// fillPullDetails fetches the single-PR endpoint for PRs whose list entry has
+// no size fields and copies the PR metadata over. Failures become warnings;
+// the list entry is kept and the store keeps any size it already had.
+func (s *Syncer) fillPullDetails(ctx context.Context, client provider.Provider, owner, repo string, prs []provider.Item, slots chan struct{}) []string {
+	logger := slog.Default().With("component", "syncer", "op", "pull-details")
+	repoFullName := owner + "/" + repo
+
+	var mu sync.Mutex
+	var warnings []string
+	var wg sync.WaitGroup
+	for k := range prs {
+		if prs[k].PR != nil && prs[k].PR.HasSize {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-slots }()
+
+			detail, err := client.GetPull(ctx, owner, repo, prs[k].Key)
+			if err == nil {
+				// 每个 goroutine 只写自己的下标，不需要加锁。
+				prs[k].PR = detail.PR
+				// GitHub 的列表不返回评论数，详情里才有。
+				prs[k].Comments = max(prs[k].Comments, detail.Comments)
+				return
+			}
+			logger.Warn("sync pull detail failed", "repo", repoFullName, "key", prs[k].Key, "err", err)
+			mu.Lock()
+			warnings = append(warnings, fmt.Sprintf("details for pr %s: %v", prs[k].Key, err))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return warnings
+}
+
+// fetch runs list once a slot is free and reports how long the request itself took.
+func fetch(ctx context.Context, slots chan struct{}, list func(context.Context) (provider.ListResult, error)) (provider.ListResult, int64, error) {
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return provider.ListResult{}, 0, ctx.Err()
+	}
+	defer func() { <-slots }()
+
+	start := time.Now()
+	items, err := list(ctx)
+	return items, time.Since(start).Milliseconds(), err
+}
+
+// paceQuota waits for the rate-limit window to reset when the remaining quota
+// is at or below reserve, so the sync slows down before the provider throttles it.
+func paceQuota(ctx context.Context, client provider.Provider, reserve int) error {
+	q := client.Quota()
+	if !q.Known || q.Remaining > reserve {
+		return nil
+	}
+	wait := time.Until(q.Reset)
+	if wait <= 0 {
+		return nil
+	}
+	slog.Default().With("component", "syncer").Warn("provider quota low, pausing", "provider", client.Name(), "remaining", q.Remaining, "reserve", reserve, "wait_ms", wait.Milliseconds())
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// toCore maps a provider item to the store's upstream columns. The upstream
+// id is prefixed with the provider, since GitCode and GitHub ids overlap.
+func toCore(providerName, kind, repoFullName string, it provider.Item) store.CoreItem {
+	labels := make([]store.Label, 0, len(it.Labels))
+	for _, l := range it.Labels {
+		labels = append(labels, store.Label{Name: l.Name, Color: l.Color, Description: l.Description})
+	}
+	var closes []store.ItemRef
+	if kind == "pr" {
+		closes = parseClosingRefs(repoFullName, hostOf(it.URL), it.Title+"\n"+it.Body)
+	}
+	upstreamID := ""
+	if it.ID != "" {
+		upstreamID = providerName + ":" + it.ID
+	}
+	return store.CoreItem{
+		Kind:              kind,
+		UpstreamID:        upstreamID,
+		RepoFullName:      repoFullName,
+		ExternalKey:       it.Key,
+		Title:             it.Title,
+		State:             it.State,
+		URL:               it.URL,
+		Author:            it.Author,
+		CreatedAt:         it.CreatedAt,
+		UpdatedAt:         it.UpdatedAt,
+		Body:              it.Body,
+		Labels:            labels,
+		UpstreamAssignees: it.Assignees,
+		UpstreamReviewers: it.Reviewers,
+		Milestone:         it.Milestone,
+		ClosedAt:          it.ClosedAt,
+		MergedAt:          it.MergedAt,
+		CommentsCount:     it.Comments,
+		IssueType:         it.IssueType,
+		PR:                toPRInfo(it.PR),
+		Closes:            closes,
+	}
+}
+
+func toComments(remote []provider.Comment) []store.Comment {
+	comments := make([]store.Comment, 0, len(remote))
+	for _, c := range remote {
+		comments = append(comments, store.Comment{ID: c.ID, Author: c.Author, Body: c.Body, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt})
+	}
+	return comments
+}
+
+func toPRInfo(m *provider.PRMeta) *store.PRInfo {
+	if m == nil {
+		return nil
+	}
+	pr := &store.PRInfo{
+		MergedBy:   m.MergedBy,
+		Draft:      m.Draft,
+		HeadBranch: m.HeadBranch,
+		BaseBranch: m.BaseBranch,
+		Mergeable:  m.Mergeable,
+	}
+	if m.HasSize {
+		pr.Additions, pr.Deletions, pr.ChangedFiles = &m.Additions, &m.Deletions, &m.ChangedFiles
+	}
+	return pr
+}
+
+func splitCSV(s string) []string {
+	out := []string{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func envInt(logger *slog.Logger, key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		logger.Warn("ignore invalid env", "key", key, "value", v)
+		return def
+	}
+	return n
+}
+
+func envDuration(logger *slog.Logger, key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		logger.Warn("ignore invalid env", "key", key, "value", v)
+		return def
+	}
+	return d
+}
+
+func envBool(logger *slog.Logger, key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		logger.Warn("ignore invalid env", "key", key, "value", v)
+		return def
+	}
+	return b
+}
+
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
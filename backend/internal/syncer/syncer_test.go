@@ -0,0 +1,506 @@
+package syncer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"tracker/internal/gitcode/gitcodetest"
+	"tracker/internal/provider"
+	"tracker/internal/store"
+)
+
+// testSyncer wires a Syncer to a fresh SQLite store and a fake GitCode
+// serving the given repos, all tracked with comments and PR details on.
+func testSyncer(t *testing.T, repos ...string) (*Syncer, *store.Store, *gitcodetest.Server) {
+	t.Helper()
+	srv := gitcodetest.NewServer()
+	t.Cleanup(srv.Close)
+	t.Setenv("GITCODE_BASE_URL", srv.URL)
+	t.Setenv("GITCODE_TOKEN", "tok")
+	t.Setenv("GITCODE_MAX_RETRIES", "0")
+	t.Setenv("SYNC_DISCOVER_INCLUDE", "")
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tracker.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	st := store.New(db)
+	ctx := context.Background()
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var seed []store.Repo
+	for _, name := range repos {
+		srv.AddRepo(gitcodetest.Repo{Owner: "o", Name: name})
+		seed = append(seed, store.Repo{Provider: "gitcode", Owner: "o", Name: name, Enabled: true, SyncComments: true, SyncPRDetails: true})
+	}
+	if _, err := st.SeedRepos(ctx, seed); err != nil {
+		t.Fatal(err)
+	}
+	sy := New(st)
+	t.Cleanup(sy.Close)
+	return sy, st, srv
+}
+
+func listItems(t *testing.T, st *store.Store, kind string) map[string]store.Item {
+	t.Helper()
+	items, err := st.ListItems(context.Background(), store.ListFilter{Kind: kind})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]store.Item{}
+	for _, it := range items {
+		out[it.ExternalKey] = it
+	}
+	return out
+}
+
+func TestFullSync(t *testing.T) {
+	sy, st, srv := testSyncer(t, "r")
+	srv.SetPaging(gitcodetest.PagingLink, 1)
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "crash", Labels: []string{"kind/bug"},
+		Comments: []gitcodetest.Comment{{ID: 1, Author: "ann", Body: "repro"}, {ID: 2, Author: "bo", Body: "ack"}}})
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 2, Title: "docs"})
+	srv.AddPull("o/r", gitcodetest.Pull{Number: 3, Title: "fix crash", Body: "fixes #1", State: "merged", MergedBy: "bo",
+		Head: "fix", Base: "master", Additions: 5, Deletions: 1, ChangedFiles: 1,
+		MergedAt: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Comments: []gitcodetest.Comment{{ID: 3, Author: "cy", Body: "lgtm"}}})
+
+	job, err := sy.RunWait(context.Background(), Options{Full: true}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != "succeeded" || job.Fetched != 3 {
+		t.Fatalf("job = %+v", job)
+	}
+	if r := job.Repos[0]; r.Status != "ok" || r.Issues != 2 || r.PRs != 1 || r.Comments != 3 || len(r.Warnings) != 0 {
+		t.Errorf("repo result = %+v", r)
+	}
+
+	issues := listItems(t, st, "issue")
+	if len(issues) != 2 || issues["1"].Title != "crash" || len(issues["1"].Labels) != 1 {
+		t.Fatalf("issues = %+v", issues)
+	}
+	if l := issues["1"].Links; len(l) != 1 || l[0].Key != "3" || l[0].Relation != "closedBy" {
+		t.Errorf("issue 1 links = %+v", l)
+	}
+	pr := listItems(t, st, "pr")["3"]
+	if pr.PR == nil || !pr.PR.Merged || pr.PR.MergedBy != "bo" || pr.PR.Additions == nil || *pr.PR.Additions != 5 {
+		t.Errorf("pr = %+v meta = %+v", pr, pr.PR)
+	}
+	comments, err := st.ListComments(context.Background(), "issue", "o/r", "1")
+	if err != nil || len(comments) != 2 || comments[0].Body != "repro" {
+		t.Errorf("comments = %+v err = %v", comments, err)
+	}
+}
+
+func TestIncrementalSyncUsesWatermark(t *testing.T) {
+	sy, st, srv := testSyncer(t, "r")
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "old"})
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 2, Title: "before"})
+	ctx := context.Background()
+	if _, err := sy.RunWait(ctx, Options{}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 2, Title: "after", UpdatedAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)})
+	job, err := sy.RunWait(ctx, Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Repos[0].Issues != 1 {
+		t.Errorf("incremental run fetched %d issues, want only the updated one", job.Repos[0].Issues)
+	}
+	reqs := srv.Requests()
+	var last gitcodetest.Request
+	for _, r := range reqs {
+		if r.Path == "/api/v5/repos/o/r/issues" {
+			last = r
+		}
+	}
+	if last.Query.Get("since") == "" {
+		t.Errorf("second run did not send since: %v", last.Query)
+	}
+	if got := listItems(t, st, "issue"); got["2"].Title != "after" || got["1"].Title != "old" {
+		t.Errorf("issues = %+v", got)
+	}
+}
+
+func TestFullSyncTombstonesMissing(t *testing.T) {
+	sy, st, srv := testSyncer(t, "r")
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "kept"})
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 2, Title: "deleted"})
+	ctx := context.Background()
+	if _, err := sy.RunWait(ctx, Options{Full: true}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.RemoveIssue("o/r", 2)
+	job, err := sy.RunWait(ctx, Options{Full: true}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Repos[0].Tombstoned != 1 {
+		t.Errorf("tombstoned = %d, want 1", job.Repos[0].Tombstoned)
+	}
+	if got := listItems(t, st, "issue"); len(got) != 1 || got["1"].Title != "kept" {
+		t.Errorf("live issues = %+v", got)
+	}
+}
+
+func TestTruncatedFullSyncSkipsTombstones(t *testing.T) {
+	sy, st, srv := testSyncer(t, "r")
+	for n := 1; n <= 3; n++ {
+		srv.AddIssue("o/r", gitcodetest.Issue{Number: n, Title: fmt.Sprintf("issue %d", n)})
+	}
+	ctx := context.Background()
+	if _, err := sy.RunWait(ctx, Options{Full: true}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只拉到一页时看不到其余条目，不能据此判定它们被删除。
+	srv.SetPaging(gitcodetest.PagingLink, 1)
+	t.Setenv("GITCODE_MAX_PAGES", "1")
+	job, err := sy.RunWait(ctx, Options{Full: true}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := job.Repos[0]; r.Tombstoned != 0 || len(r.Warnings) == 0 {
+		t.Errorf("repo = %+v, want a truncation warning and nothing tombstoned", r)
+	}
+	if got := listItems(t, st, "issue"); len(got) != 3 {
+		t.Errorf("live issues = %d, want 3", len(got))
+	}
+}
+
+func TestDiscoveryAddsNewRepos(t *testing.T) {
+	sy, st, srv := testSyncer(t, "r")
+	t.Setenv("SYNC_DISCOVER_OWNER", "o")
+	t.Setenv("SYNC_DISCOVER_INCLUDE", "r,new-*")
+	t.Setenv("SYNC_DISCOVER_EXCLUDE", "*-old")
+	srv.AddRepo(gitcodetest.Repo{Owner: "o", Name: "new-a"})
+	srv.AddRepo(gitcodetest.Repo{Owner: "o", Name: "new-b", Archived: true})
+	srv.AddRepo(gitcodetest.Repo{Owner: "o", Name: "new-old"})
+	srv.AddRepo(gitcodetest.Repo{Owner: "o", Name: "other"})
+	srv.AddIssue("o/new-a", gitcodetest.Issue{Number: 1, Title: "found"})
+
+	job, err := sy.RunWait(context.Background(), Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := job.Discovery
+	if d == nil || d.Owner != "o" || d.Matched != 2 || !slices.Equal(d.Added, []string{"o/new-a"}) || d.Truncated || d.Error != "" {
+		t.Fatalf("discovery = %+v", d)
+	}
+	// 不限定仓库的同步在本次就同步新仓库。
+	if job.Status != "succeeded" || len(job.Repos) != 2 || job.Repos[1].Repo != "o/new-a" || job.Repos[1].Issues != 1 {
+		t.Errorf("job = %+v", job)
+	}
+
+	// 删掉的仓库不会被再次发现。
+	if err := st.DeleteRepo(context.Background(), "o", "new-a"); err != nil {
+		t.Fatal(err)
+	}
+	job, err = sy.RunWait(context.Background(), Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := job.Discovery; d == nil || d.Matched != 2 || len(d.Added) != 0 || len(job.Repos) != 1 {
+		t.Errorf("second run discovery = %+v repos = %+v", d, job.Repos)
+	}
+}
+
+func TestFailingRepoMakesJobPartial(t *testing.T) {
+	sy, st, srv := testSyncer(t, "good", "bad")
+	srv.AddIssue("o/good", gitcodetest.Issue{Number: 1, Title: "a"})
+	srv.AddIssue("o/bad", gitcodetest.Issue{Number: 1, Title: "b"})
+	srv.Inject(gitcodetest.Fault{Path: "/api/v5/repos/o/bad/issues", Status: http.StatusInternalServerError, Body: "boom"})
+
+	job, err := sy.RunWait(context.Background(), Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != "partial" {
+		t.Fatalf("job status = %q, want partial", job.Status)
+	}
+	for _, r := range job.Repos {
+		switch r.Repo {
+		case "o/good":
+			if r.Status != "ok" {
+				t.Errorf("o/good = %+v", r)
+			}
+		case "o/bad":
+			if r.Status != "failed" || r.Error == "" {
+				t.Errorf("o/bad = %+v", r)
+			}
+		}
+	}
+	saved, err := st.GetSyncJob(context.Background(), job.ID)
+	if err != nil || saved.Status != "partial" {
+		t.Errorf("stored job = %+v err = %v", saved, err)
+	}
+}
+
+func TestCommentFailureKeepsWatermark(t *testing.T) {
+	sy, st, srv := testSyncer(t, "r")
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a", Comments: []gitcodetest.Comment{{ID: 1, Author: "ann", Body: "hi"}}})
+	srv.AddPull("o/r", gitcodetest.Pull{Number: 2, Title: "b", Head: "b", Base: "master"})
+	srv.Inject(gitcodetest.Fault{Path: "/api/v5/repos/o/r/issues/1/comments", Status: http.StatusInternalServerError, Body: "boom", Times: 1})
+	ctx := context.Background()
+
+	job, err := sy.RunWait(ctx, Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := job.Repos[0]; len(r.Warnings) != 1 {
+		t.Fatalf("first run = %+v, want the comment failure as a warning", r)
+	}
+	if wm, err := st.GetWatermark(ctx, "o/r", "issue"); err != nil || wm != "" {
+		t.Errorf("issue watermark = %q err = %v, want it kept back", wm, err)
+	}
+	if wm, err := st.GetWatermark(ctx, "o/r", "pr"); err != nil || wm == "" {
+		t.Errorf("pr watermark = %q err = %v, want it advanced", wm, err)
+	}
+
+	// 下一次增量同步重新拉到这个 issue，评论随之补上。
+	job, err = sy.RunWait(ctx, Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := job.Repos[0]; r.Issues != 1 || r.Comments != 1 || len(r.Warnings) != 0 {
+		t.Errorf("second run = %+v", r)
+	}
+	comments, err := st.ListComments(ctx, "issue", "o/r", "1")
+	if err != nil || len(comments) != 1 {
+		t.Errorf("comments = %+v err = %v", comments, err)
+	}
+}
+
+func TestTruncatedIncrementalSyncResumes(t *testing.T) {
+	sy, st, srv := testSyncer(t, "r")
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "seen"})
+	ctx := context.Background()
+	if _, err := sy.RunWait(ctx, Options{}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(d int) time.Time { return time.Date(2024, 6, d, 0, 0, 0, 0, time.UTC) }
+	for n := 2; n <= 4; n++ {
+		srv.AddIssue("o/r", gitcodetest.Issue{Number: n, Title: "new", UpdatedAt: day(n)})
+	}
+	srv.SetPaging(gitcodetest.PagingLink, 1)
+	t.Setenv("GITCODE_MAX_PAGES", "2")
+
+	// 每次只能拉两页，但水位逐次推进，最终所有更新都会入库。
+	var truncated int
+	for run := 0; run < 3; run++ {
+		job, err := sy.RunWait(ctx, Options{}, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(job.Repos[0].Warnings) > 0 {
+			truncated++
+		}
+	}
+	if truncated == 0 {
+		t.Error("no run was truncated")
+	}
+	if got := listItems(t, st, "issue"); len(got) != 4 || got["4"].Title != "new" {
+		t.Errorf("issues = %+v", got)
+	}
+	wm, err := st.GetWatermark(ctx, "o/r", "issue")
+	if at, ok := provider.ParseTime(wm); err != nil || !ok || !at.Equal(day(4)) {
+		t.Errorf("issue watermark = %q err = %v, want the newest update", wm, err)
+	}
+}
+
+func TestConfigFromEnvParsesBools(t *testing.T) {
+	t.Setenv("SYNC_COMMENTS", "0")
+	t.Setenv("SYNC_PR_DETAILS", "nope")
+	cfg := ConfigFromEnv()
+	if cfg.SyncComments || !cfg.PRDetails {
+		t.Errorf("SyncComments = %v PRDetails = %v, want false and the default", cfg.SyncComments, cfg.PRDetails)
+	}
+}
+
+func TestConfigFromEnvRetriesPerProvider(t *testing.T) {
+	t.Setenv("GITCODE_MAX_RETRIES", "3")
+	t.Setenv("GITCODE_RETRY_BUDGET", "30")
+	t.Setenv("GITHUB_MAX_RETRIES", "1")
+	t.Setenv("GITHUB_RETRY_BUDGET", "")
+	cfg := ConfigFromEnv()
+	if cfg.Retry.MaxRetries != 3 || cfg.Retry.Budget != 30 {
+		t.Errorf("gitcode retry = %+v", cfg.Retry)
+	}
+	if cfg.GitHubRetry.MaxRetries != 1 || cfg.GitHubRetry.Budget != provider.DefaultRetryPolicy().Budget {
+		t.Errorf("github retry = %+v, want its own settings", cfg.GitHubRetry)
+	}
+}
+
+func TestSyncChecksTokensPerProvider(t *testing.T) {
+	sy, st, _ := testSyncer(t, "r")
+	t.Setenv("GITCODE_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "")
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("github request sent a token")
+		}
+		fmt.Fprint(w, `[{"id":1,"number":1,"title":"gh","state":"open","updated_at":"2024-01-01T00:00:00Z"}]`)
+	}))
+	t.Cleanup(gh.Close)
+	t.Setenv("GITHUB_BASE_URL", gh.URL)
+	ctx := context.Background()
+	if _, _, err := st.SaveRepo(ctx, store.Repo{Provider: "github", Owner: "gh", Name: "r", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有任何令牌时 GitHub 仓库照常匿名同步，只有 GitCode 仓库失败。
+	job, err := sy.RunWait(ctx, Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != "partial" {
+		t.Errorf("job status = %q, want partial", job.Status)
+	}
+	for _, r := range job.Repos {
+		switch r.Repo {
+		case "gh/r":
+			if r.Status != "ok" || r.Issues != 1 {
+				t.Errorf("github repo = %+v", r)
+			}
+		case "o/r":
+			if r.Status != "failed" || !strings.Contains(r.Error, "GITCODE_TOKEN") {
+				t.Errorf("gitcode repo = %+v", r)
+			}
+		}
+	}
+}
+
+func TestSyncWaitsOutRateLimit(t *testing.T) {
+	sy, st, srv := testSyncer(t, "r")
+	t.Setenv("GITCODE_MAX_RETRIES", "2")
+	t.Setenv("SYNC_QUOTA_RESERVE", "0")
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a"})
+	srv.SetRateLimit(60, 0, time.Now().Add(time.Second))
+
+	job, err := sy.RunWait(context.Background(), Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != "succeeded" || len(listItems(t, st, "issue")) != 1 {
+		t.Errorf("job = %+v", job)
+	}
+}
+
+func TestIncrementalSyncUsesPageCache(t *testing.T) {
+	sy, st, srv := testSyncer(t, "r")
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a"})
+	srv.AddPull("o/r", gitcodetest.Pull{Number: 2, Title: "b", Head: "b", Base: "master"})
+	ctx := context.Background()
+
+	first, err := sy.RunWait(ctx, Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := first.Repos[0]; r.CacheHits != 0 || r.CacheMisses != 2 {
+		t.Fatalf("first run = %+v", r)
+	}
+
+	// 第二次带上了新的 since，URL 变了；第三次 URL 相同，第一页命中 304，
+	// 再看一眼之后的空页；第四次两页都是 304。
+	if _, err := sy.RunWait(ctx, Options{}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	third, err := sy.RunWait(ctx, Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := third.Repos[0]; r.CacheHits != 2 || r.CacheMisses != 2 || r.Issues != 0 || r.PRs != 0 {
+		t.Errorf("third run = %+v, want the first pages answered 304", r)
+	}
+	fourth, err := sy.RunWait(ctx, Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := fourth.Repos[0]; r.CacheHits != 4 || r.CacheMisses != 0 || r.Issues != 0 || r.PRs != 0 {
+		t.Errorf("fourth run = %+v, want every page answered 304", r)
+	}
+	if got := listItems(t, st, "issue"); got["1"].Title != "a" {
+		t.Errorf("issues = %+v", got)
+	}
+
+	// 全量同步不发条件请求。
+	full, err := sy.RunWait(ctx, Options{Full: true}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := full.Repos[0]; r.CacheHits != 0 || r.CacheMisses != 0 || r.Issues != 1 || r.PRs != 1 {
+		t.Errorf("full run = %+v", r)
+	}
+}
+
+func TestSyncUsesTokenFile(t *testing.T) {
+	sy, _, srv := testSyncer(t, "r")
+	file := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(file, []byte("revoked\nvalid\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITCODE_TOKEN", "")
+	t.Setenv("GITCODE_TOKEN_FILE", file)
+	srv.RequireToken("valid")
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "a"})
+
+	job, err := sy.RunWait(context.Background(), Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != "succeeded" || job.Repos[0].Issues != 1 {
+		t.Errorf("job = %+v", job)
+	}
+	h := sy.TokenHealth()
+	if len(h) != 2 || h[0].Status != "unauthorized" || h[1].Status != "ok" || !h[1].Current {
+		t.Errorf("token health = %+v", h)
+	}
+}
+
+func TestDrainWebhooks(t *testing.T) {
+	sy, st, srv := testSyncer(t, "r", "down")
+	srv.AddIssue("o/r", gitcodetest.Issue{Number: 1, Title: "from webhook"})
+	srv.Inject(gitcodetest.Fault{Path: "/api/v5/repos/o/down/issues/*", Status: http.StatusBadGateway, Body: "bad gateway"})
+	ctx := context.Background()
+	for _, d := range []store.WebhookDelivery{
+		{ID: "ok", Event: "issue", RepoFullName: "o/r", Kind: "issue", Key: "1"},
+		{ID: "untracked", Event: "issue", RepoFullName: "o/other", Kind: "issue", Key: "1"},
+		{ID: "upstream-down", Event: "issue", RepoFullName: "o/down", Kind: "issue", Key: "1"},
+	} {
+		if _, err := st.BeginDelivery(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sy.DrainWebhooks(ctx)
+	if got := listItems(t, st, "issue"); len(got) != 1 || got["1"].Title != "from webhook" {
+		t.Errorf("issues = %+v", got)
+	}
+	// 上游出错的投递留在队列里等下次重试，其余的都已处理完。
+	if due, err := st.DueDeliveries(ctx, 10); err != nil || len(due) != 0 {
+		t.Errorf("due right after drain = %+v err = %v", due, err)
+	}
+	if fresh, err := st.BeginDelivery(ctx, store.WebhookDelivery{ID: "upstream-down"}); err != nil || fresh {
+		t.Errorf("upstream-down requeued = %v err = %v, want it still queued", fresh, err)
+	}
+	if fresh, err := st.BeginDelivery(ctx, store.WebhookDelivery{ID: "ok"}); err != nil || fresh {
+		t.Errorf("ok requeued = %v err = %v, want it handled", fresh, err)
+	}
+}
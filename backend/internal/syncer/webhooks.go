@@ -0,0 +1,133 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"tracker/internal/provider"
+	"tracker/internal/store"
+)
+
+const (
+	// webhookPoll is how often queued deliveries are looked for when no new
+	// one wakes the worker, e.g. for retries that have come due.
+	webhookPoll = 10 * time.Second
+	// webhookTimeout bounds the refetch of one delivery's item.
+	webhookTimeout = 30 * time.Second
+	// webhookMaxAttempts is how many times a delivery is tried before it is marked failed.
+	webhookMaxAttempts = 5
+)
+
+// webhookBackoff spaces out the attempts of one delivery (BaseDelay and MaxDelay only).
+var webhookBackoff = provider.RetryPolicy{BaseDelay: 30 * time.Second, MaxDelay: 30 * time.Minute}
+
+// WakeWebhooks tells RunWebhooks that a delivery was queued. It never blocks.
+func (s *Syncer) WakeWebhooks() {
+	select {
+	case s.webhooks <- struct{}{}:
+	default:
+	}
+}
+
+// RunWebhooks processes queued webhook deliveries until ctx is done, right
+// away when WakeWebhooks is called and every webhookPoll otherwise.
+func (s *Syncer) RunWebhooks(ctx context.Context) {
+	t := time.NewTicker(webhookPoll)
+	defer t.Stop()
+	for {
+		s.DrainWebhooks(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.webhooks:
+		case <-t.C:
+		}
+	}
+}
+
+// DrainWebhooks handles every delivery that is due now, each at most once
+// per call, and stops at the first one whose outcome could not be recorded.
+func (s *Syncer) DrainWebhooks(ctx context.Context) {
+	logger := slog.Default().With("component", "syncer", "op", "drain-webhooks")
+	handled := map[string]bool{}
+	for ctx.Err() == nil {
+		due, err := s.st.DueDeliveries(ctx, 20+len(handled))
+		if err != nil {
+			logger.Error("load deliveries failed", "err", err)
+			return
+		}
+		fresh := 0
+		for _, d := range due {
+			if ctx.Err() != nil {
+				return
+			}
+			if handled[d.ID] {
+				continue
+			}
+			handled[d.ID] = true
+			fresh++
+			if err := s.handleDelivery(ctx, d); err != nil {
+				logger.Error("delivery store write failed; waiting for next poll", "delivery", d.ID, "err", err)
+				return
+			}
+		}
+		if fresh == 0 {
+			return
+		}
+	}
+}
+
+// handleDelivery refetches the item d is about. The error is only about
+// recording the outcome; sync failures are recorded as attempts.
+func (s *Syncer) handleDelivery(ctx context.Context, d store.WebhookDelivery) error {
+	logger := slog.Default().With("component", "syncer", "op", "webhook", "delivery", d.ID, "event", d.Event,
+		"repo", d.RepoFullName, "kind", d.Kind, "key", d.Key, "attempt", d.Attempts+1)
+	start := time.Now()
+
+	syncCtx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	err := s.SyncItem(syncCtx, "gitcode", d.RepoFullName, d.Kind, d.Key, d.Event == "comment")
+	cancel()
+	switch {
+	case err == nil:
+		logger.Info("webhook ok", "elapsed_ms", time.Since(start).Milliseconds())
+		return s.st.FinishDelivery(ctx, d.ID, "ok", "")
+	case errors.Is(err, ErrUntracked):
+		logger.Info("webhook ignored", "reason", "repo not tracked")
+		return s.st.FinishDelivery(ctx, d.ID, "ignored", "")
+	case ctx.Err() != nil:
+		// 关停时被打断的投递不算一次失败，重启后再处理。
+		return nil
+	}
+
+	var next time.Time
+	if retryableDelivery(err) && d.Attempts+1 < webhookMaxAttempts {
+		next = time.Now().Add(max(webhookBackoff.Backoff(d.Attempts), webhookBackoff.BaseDelay))
+	}
+	if rerr := s.st.RetryDelivery(ctx, d.ID, err.Error(), next); rerr != nil {
+		return rerr
+	}
+	if next.IsZero() {
+		logger.Error("webhook failed", "err", err)
+		return nil
+	}
+	logger.Warn("webhook failed; will retry", "next", next.UTC().Format(time.RFC3339), "err", err)
+	return nil
+}
+
+// retryableDelivery is false when trying again cannot help: no token is
+// configured, or GitCode rejected the request outright.
+func retryableDelivery(err error) bool {
+	if errors.Is(err, ErrMissingToken) {
+		return false
+	}
+	switch status := provider.StatusCode(err); {
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	case status >= 400 && status < 500:
+		return false
+	}
+	return true
+}
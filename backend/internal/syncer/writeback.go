@@ -0,0 +1,158 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"tracker/internal/gitcode"
+	"tracker/internal/provider"
+	"tracker/internal/store"
+)
+
+var ErrWriteUnsupported = errors.New("write-back is only supported for gitcode repos")
+
+// Write is one change to push upstream. Action picks the fields used:
+// "comment" uses Body, "labels" uses Add and Remove, "assignees" uses Assignees.
+type Write struct {
+	Action    string   `json:"-"`
+	Body      string   `json:"body"`
+	Add       []string `json:"add"`
+	Remove    []string `json:"remove"`
+	Assignees []string `json:"assignees"`
+}
+
+// WriteBack applies w to an issue or PR on GitCode and records it in the
+// item's write history. On success the item (and, for comments, its
+// comments) is refetched so the store reflects the change; a failed refresh
+// only logs, since the write itself went through. An upstream rejection is
+// returned as an *UpstreamError wrapping the provider.HTTPError, and is
+// recorded too. A labels write tries every label and keeps the outcome of
+// each in the payload; it fails if any label did, and the item is still
+// refetched when some went through.
+func (s *Syncer) WriteBack(ctx context.Context, repoFullName, kind, key string, w Write) (store.ItemWrite, error) {
+	logger := slog.Default().With("component", "syncer", "op", "write-back", "repo", repoFullName, "kind", kind, "key", key, "action", w.Action)
+	start := time.Now()
+	switch w.Action {
+	case "comment", "labels", "assignees":
+	default:
+		return store.ItemWrite{}, fmt.Errorf("unknown write action %q", w.Action)
+	}
+	cfg := ConfigFromEnv()
+	owner, name, _ := strings.Cut(repoFullName, "/")
+	r, err := s.st.GetRepo(ctx, owner, name)
+	if store.IsNotFound(err) || (err == nil && !r.Enabled) {
+		return store.ItemWrite{}, ErrUntracked
+	}
+	if err != nil {
+		return store.ItemWrite{}, err
+	}
+	if r.Provider != "gitcode" {
+		return store.ItemWrite{}, ErrWriteUnsupported
+	}
+	client, err := s.gitcodeClient(cfg)
+	if err != nil {
+		return store.ItemWrite{}, err
+	}
+	repoFullName = r.FullName()
+
+	rec, err := s.st.BeginWrite(ctx, kind, repoFullName, key, w.Action, w.payload())
+	if err != nil {
+		return store.ItemWrite{}, err
+	}
+
+	// changed is whether anything reached GitCode, so the item needs a refresh.
+	var changed bool
+	switch w.Action {
+	case "comment":
+		_, err = client.CreateComment(ctx, r.Owner, r.Name, kind, key, w.Body)
+	case "labels":
+		var results []labelResult
+		results, err = writeLabels(ctx, client, r.Owner, r.Name, kind, key, w)
+		changed = slices.ContainsFunc(results, func(l labelResult) bool { return l.Status == "ok" })
+		payload := w.payload()
+		payload["results"] = results
+		if b, merr := json.Marshal(payload); merr == nil {
+			rec.Payload = b
+		}
+	case "assignees":
+		err = client.SetAssignees(ctx, r.Owner, r.Name, kind, key, w.Assignees)
+	}
+
+	rec.Status = "ok"
+	if err != nil {
+		rec.Status, rec.Error, rec.UpstreamStatus = "failed", strings.ReplaceAll(err.Error(), "\n", "; "), provider.StatusCode(err)
+		err = &UpstreamError{Repo: repoFullName, Err: err}
+	} else {
+		changed = true
+	}
+	rec, ferr := s.st.FinishWrite(ctx, rec)
+	if ferr != nil {
+		return rec, errors.Join(err, ferr)
+	}
+	if changed {
+		if rerr := s.SyncItem(ctx, "gitcode", repoFullName, kind, key, w.Action == "comment"); rerr != nil {
+			logger.Warn("write-back refresh failed", "err", rerr)
+		}
+	}
+	if err != nil {
+		logger.Warn("write-back failed", "status", rec.UpstreamStatus, "err", err)
+		return rec, err
+	}
+	logger.Info("write-back ok", "elapsed_ms", time.Since(start).Milliseconds())
+	return rec, nil
+}
+
+// labelResult is the outcome of one label of a "labels" write, kept in the
+// write's payload.
+type labelResult struct {
+	Label          string `json:"label"`
+	Op             string `json:"op"`     // add|remove
+	Status         string `json:"status"` // ok|failed
+	UpstreamStatus int    `json:"upstreamStatus,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// writeLabels adds w.Add in one request, then removes w.Remove one by one.
+// Every label is tried even after a failure, so the result says which ones
+// were applied; the error joins the failures.
+func writeLabels(ctx context.Context, client *gitcode.Client, owner, repo, kind, key string, w Write) ([]labelResult, error) {
+	var results []labelResult
+	var errs []error
+	record := func(op string, labels []string, err error) {
+		for _, l := range labels {
+			res := labelResult{Label: l, Op: op, Status: "ok"}
+			if err != nil {
+				res.Status, res.UpstreamStatus, res.Error = "failed", provider.StatusCode(err), err.Error()
+			}
+			results = append(results, res)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s label %s: %w", op, strings.Join(labels, ","), err))
+		}
+	}
+	if len(w.Add) > 0 {
+		record("add", w.Add, client.AddLabels(ctx, owner, repo, kind, key, w.Add))
+	}
+	for _, l := range w.Remove {
+		record("remove", []string{l}, client.RemoveLabel(ctx, owner, repo, kind, key, l))
+	}
+	return results, errors.Join(errs...)
+}
+
+// payload is what the write history keeps for w: only its action's fields.
+func (w Write) payload() map[string]any {
+	switch w.Action {
+	case "comment":
+		return map[string]any{"body": w.Body}
+	case "labels":
+		return map[string]any{"add": w.Add, "remove": w.Remove}
+	default:
+		return map[string]any{"assignees": w.Assignees}
+	}
+}
@@ -27,8 +27,14 @@ function App() {
     setLoading(true);
     setError(null);
     try {
-      await syncNow();
+      const job = await syncNow();
       await refresh();
+      if (job.status === "partial") {
+        const failed = job.repos
+          .filter((r) => r.status === "failed")
+          .map((r) => `${r.repo}: ${r.error ?? "unknown error"}`);
+        setError(`sync partially failed (${job.error}): ${failed.join("; ")}`);
+      }
     } catch (e) {
       setError(e instanceof Error ? e.message : String(e));
     } finally {
@@ -1,3 +1,31 @@
+export type Label = {
+  name: string
+  color: string
+  description: string
+}
+
+export type PRInfo = {
+  merged: boolean
+  mergedBy: string
+  draft: boolean
+  headBranch: string
+  baseBranch: string
+  additions: number | null
+  deletions: number | null
+  changedFiles: number | null
+  mergeable: boolean | null
+}
+
+export type ItemLink = {
+  kind: 'issue' | 'pr'
+  repoFullName: string
+  key: string
+  title: string
+  state: string
+  url: string
+  relation: 'closes' | 'closedBy'
+}
+
 export type Item = {
   kind: 'issue' | 'pr'
   repoFullName: string
@@ -8,6 +36,21 @@ export type Item = {
   author: string
   createdAt: string
   updatedAt: string
+  body: string
+  labels: Label[]
+  upstreamAssignees: string[]
+  upstreamReviewers: string[]
+  milestone: string
+  closedAt: string
+  mergedAt: string
+  commentsCount: number
+  issueType: string
+  lastCommentAt: string
+  lastActivityAt: string
+  tombstonedAt: string
+  transferredTo: string
+  links: ItemLink[]
+  pr?: PRInfo
   assignee: string
   assigneeGroup: string
   note: string
@@ -15,15 +58,39 @@ export type Item = {
   syncInternal: boolean
   priority: number
   dueAt: string
+  // set by the backend once the internal issue for syncInternal exists
+  internalIssueId: string
+  internalIssueUrl: string
+  internalState: '' | 'open' | 'closed'
+  internalCheckedAt: string
+  statusMismatch: boolean
   overdueDays: number
+  assigneeDrift: boolean
 }
 
 const API_BASE = import.meta.env.VITE_API_BASE ?? 'http://localhost:8080'
 
-export async function fetchItems(params?: { kind?: string; repo?: string }): Promise<Item[]> {
+export async function fetchItems(params?: {
+  kind?: string
+  repo?: string
+  labels?: string[]
+  assigneeDrift?: boolean
+  statusMismatch?: boolean
+  base?: string
+  merged?: boolean
+  includeTombstoned?: boolean
+  sort?: 'lastActivity'
+}): Promise<Item[]> {
   const url = new URL('/api/items', API_BASE)
   if (params?.kind) url.searchParams.set('kind', params.kind)
   if (params?.repo) url.searchParams.set('repo', params.repo)
+  for (const label of params?.labels ?? []) url.searchParams.append('label', label)
+  if (params?.assigneeDrift) url.searchParams.set('assigneeDrift', 'true')
+  if (params?.statusMismatch) url.searchParams.set('statusMismatch', 'true')
+  if (params?.base) url.searchParams.set('base', params.base)
+  if (params?.merged !== undefined) url.searchParams.set('merged', String(params.merged))
+  if (params?.includeTombstoned) url.searchParams.set('includeTombstoned', 'true')
+  if (params?.sort) url.searchParams.set('sort', params.sort)
 
   const res = await fetch(url)
   if (!res.ok) throw new Error(`fetchItems failed: ${res.status}`)
@@ -31,11 +98,178 @@ export async function fetchItems(params?: { kind?: string; repo?: string }): Pro
   return data.items ?? []
 }
 
-export async function syncNow(): Promise<{ fetched: number; upserted: number }> {
+export type SyncJobRepo = {
+  repo: string
+  status: 'pending' | 'running' | 'ok' | 'failed'
+  issues: number
+  prs: number
+  comments: number
+  upserted: number
+  tombstoned: number
+  cacheHits: number
+  cacheMisses: number
+  elapsedMs: number
+  warnings?: string[]
+  error?: string
+}
+
+export type SyncJob = {
+  id: string
+  trigger: string
+  status: 'running' | 'succeeded' | 'partial' | 'failed'
+  full: boolean
+  repos: SyncJobRepo[]
+  discovery?: {
+    owner: string
+    matched: number
+    added: string[]
+    truncated?: boolean
+    error?: string
+  }
+  fetched: number
+  upserted: number
+  error?: string
+  createdAt: string
+  finishedAt?: string
+}
+
+export async function fetchSyncJob(id: string): Promise<SyncJob> {
+  const url = new URL(`/api/sync/jobs/${encodeURIComponent(id)}`, API_BASE)
+  const res = await fetch(url)
+  // 207 (partial) and 502 (failed) still carry the job body.
+  if (!res.ok && res.status !== 502) throw new Error(`fetchSyncJob failed: ${res.status}`)
+  return (await res.json()) as SyncJob
+}
+
+// syncNow starts a sync job and polls it until it finishes.
+export async function syncNow(pollMs = 1000): Promise<SyncJob> {
   const url = new URL('/api/sync', API_BASE)
   const res = await fetch(url, { method: 'POST' })
   if (!res.ok) throw new Error(`sync failed: ${res.status}`)
-  return (await res.json()) as { fetched: number; upserted: number }
+  const { jobId } = (await res.json()) as { jobId: string }
+
+  for (;;) {
+    const job = await fetchSyncJob(jobId)
+    if (job.status === 'failed') throw new Error(`sync failed: ${job.error ?? 'unknown error'}`)
+    if (job.status !== 'running') return job
+    await new Promise((resolve) => setTimeout(resolve, pollMs))
+  }
+}
+
+export async function fetchLabels(repo?: string): Promise<Label[]> {
+  const url = new URL('/api/labels', API_BASE)
+  if (repo) url.searchParams.set('repo', repo)
+
+  const res = await fetch(url)
+  if (!res.ok) throw new Error(`fetchLabels failed: ${res.status}`)
+  const data = (await res.json()) as { labels: Label[] }
+  return data.labels ?? []
+}
+
+export type Repo = {
+  provider: string
+  owner: string
+  name: string
+  enabled: boolean
+  syncComments: boolean
+  syncPrDetails: boolean
+  interval: string
+  createdAt: string
+  updatedAt: string
+}
+
+export async function fetchRepos(): Promise<Repo[]> {
+  const url = new URL('/api/repos', API_BASE)
+  const res = await fetch(url)
+  if (!res.ok) throw new Error(`fetchRepos failed: ${res.status}`)
+  const data = (await res.json()) as { repos: Repo[] }
+  return data.repos ?? []
+}
+
+export async function saveRepo(
+  repo: Pick<Repo, 'owner' | 'name'> & Partial<Pick<Repo, 'provider' | 'enabled' | 'syncComments' | 'syncPrDetails' | 'interval'>>,
+): Promise<Repo> {
+  const url = new URL('/api/repos', API_BASE)
+  const res = await fetch(url, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(repo),
+  })
+  if (!res.ok) throw new Error(`saveRepo failed: ${res.status}`)
+  return (await res.json()) as Repo
+}
+
+export async function deleteRepo(owner: string, name: string): Promise<void> {
+  const url = new URL(`/api/repos/${encodeURIComponent(owner)}/${encodeURIComponent(name)}`, API_BASE)
+  const res = await fetch(url, { method: 'DELETE' })
+  if (!res.ok) throw new Error(`deleteRepo failed: ${res.status}`)
+}
+
+export type Comment = {
+  id: string
+  author: string
+  body: string
+  createdAt: string
+  updatedAt: string
+}
+
+export async function fetchComments(kind: Item['kind'], repoFullName: string, key: string): Promise<Comment[]> {
+  const [owner, repo] = splitRepo(repoFullName)
+  const url = new URL(
+    `/api/items/${kind}/${encodeURIComponent(owner)}/${encodeURIComponent(repo)}/${encodeURIComponent(key)}/comments`,
+    API_BASE
+  )
+  const res = await fetch(url)
+  if (!res.ok) throw new Error(`fetchComments failed: ${res.status}`)
+  const data = (await res.json()) as { comments: Comment[] }
+  return data.comments ?? []
+}
+
+export type ItemWrite = {
+  id: number
+  action: 'comment' | 'labels' | 'assignees'
+  payload: Record<string, unknown>
+  status: 'pending' | 'ok' | 'failed'
+  error?: string
+  upstreamStatus?: number
+  createdAt: string
+  finishedAt?: string
+}
+
+async function writeBack(
+  method: 'POST' | 'PUT',
+  kind: Item['kind'],
+  repoFullName: string,
+  key: string,
+  path: string,
+  body: unknown,
+): Promise<ItemWrite> {
+  const [owner, repo] = splitRepo(repoFullName)
+  const url = new URL(
+    `/api/items/${kind}/${encodeURIComponent(owner)}/${encodeURIComponent(repo)}/${encodeURIComponent(key)}/${path}`,
+    API_BASE
+  )
+  const res = await fetch(url, { method, headers: { 'Content-Type': 'application/json' }, body: JSON.stringify(body) })
+  const data = (await res.json()) as { write?: ItemWrite; error?: string }
+  if (!res.ok) throw new Error(data.error ?? `${path} failed: ${res.status}`)
+  return data.write as ItemWrite
+}
+
+export function postComment(kind: Item['kind'], repoFullName: string, key: string, body: string): Promise<ItemWrite> {
+  return writeBack('POST', kind, repoFullName, key, 'comments', { body })
+}
+
+export function editLabels(
+  kind: Item['kind'],
+  repoFullName: string,
+  key: string,
+  change: { add?: string[]; remove?: string[] },
+): Promise<ItemWrite> {
+  return writeBack('POST', kind, repoFullName, key, 'labels', change)
+}
+
+export function setUpstreamAssignees(kind: Item['kind'], repoFullName: string, key: string, assignees: string[]): Promise<ItemWrite> {
+  return writeBack('PUT', kind, repoFullName, key, 'assignees', { assignees })
 }
 
 export async function patchItem(
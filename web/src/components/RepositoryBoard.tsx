@@ -126,25 +126,6 @@ function filterOptions(opts: string[], query: string) {
   return opts.filter((o) => o.toLowerCase().includes(q));
 }
 
-async function notifyInternalIssue(item: Item) {
-  // 示例：同步到内部代码仓创建 issue，后续可替换为真实 API
-  try {
-    await fetch("https://internal.example.com/api/issues", {
-      method: "POST",
-      headers: { "Content-Type": "application/json" },
-      body: JSON.stringify({
-        repo: item.repoFullName,
-        title: item.title,
-        link: item.url,
-        priority: item.priority,
-        assignee: item.assignee,
-      }),
-    });
-  } catch {
-    // swallow demo call failures
-  }
-}
-
 function kindTone(kind: Item["kind"]) {
   if (kind === "pr") return { label: "PR", tone: "type-pr" };
   return { label: "Issue", tone: "type-issue" };
@@ -262,9 +243,6 @@ export function RepositoryBoard(props: Props) {
         setModalItem(null);
       }
       setFormError(null);
-      if (payload.syncInternal) {
-        void notifyInternalIssue(updated);
-      }
     } finally {
       setSavingKey(null);
     }
@@ -799,6 +777,13 @@ export function RepositoryBoard(props: Props) {
                   }
                 />
                 <span>是否同步黄区</span>
+                {modalItem?.internalIssueId ? (
+                  <a href={modalItem.internalIssueUrl || undefined} target="_blank" rel="noreferrer">
+                    内部单 #{modalItem.internalIssueId}
+                  </a>
+                ) : modalItem?.syncInternal ? (
+                  <span className="muted">内部单创建中</span>
+                ) : null}
               </label>
               {!modalDraft?.syncInternal ? (
                 <div className="fieldGroup">